@@ -0,0 +1,143 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+	"github.com/netsec-ethz/scion-apps/pkg/grpcscion"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+func main() {
+	var err error
+	// get local and remote addresses from program arguments:
+	port := flag.Uint("port", 0, "[Server] local port to listen on")
+	remoteAddr := flag.String("remote", "", "[Client] Remote (i.e. the server's) SCION Address (e.g. 17-ffaa:1:1,[127.0.0.1]:12345)")
+	message := flag.String("message", "hello world", "[Client] Message to send")
+	flag.Parse()
+
+	if (*port > 0) == (len(*remoteAddr) > 0) {
+		check(fmt.Errorf("Either specify -port for server or -remote for client"))
+	}
+
+	if *port > 0 {
+		err = runServer(uint16(*port))
+		check(err)
+	} else {
+		err = runClient(*remoteAddr, *message)
+		check(err)
+	}
+}
+
+func runServer(port uint16) error {
+	listener, err := grpcscion.NewListener(port)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer()
+	server.RegisterService(&echoServiceDesc, &echoService{})
+	return server.Serve(listener)
+}
+
+func runClient(address, message string) error {
+	conn, err := grpcscion.Dial(address,
+		grpcscion.WithPathSelector(func(dst addr.IA) (snet.Path, error) {
+			return appnet.ChoosePathByMetric(appnet.Shortest, dst)
+		}),
+	)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply := new(wrapperspb.StringValue)
+	err = conn.Invoke(ctx, echoMethod, &wrapperspb.StringValue{Value: message}, reply)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Reply: %s\n", reply.GetValue())
+	return nil
+}
+
+// The echo service is defined by hand here, to avoid generating code from a
+// protobuf service definition for this example. It corresponds to:
+//
+//   service Echo {
+//     rpc Echo(google.protobuf.StringValue) returns (google.protobuf.StringValue);
+//   }
+const echoMethod = "/scionapps.examples.Echo/Echo"
+
+type echoServer interface {
+	Echo(context.Context, *wrapperspb.StringValue) (*wrapperspb.StringValue, error)
+}
+
+type echoService struct{}
+
+func (s *echoService) Echo(ctx context.Context, in *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	fmt.Printf("Received: %s\n", in.GetValue())
+	return in, nil
+}
+
+var echoServiceDesc = grpc.ServiceDesc{
+	ServiceName: "scionapps.examples.Echo",
+	HandlerType: (*echoServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Echo",
+			Handler:    echoHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+func echoHandler(srv interface{}, ctx context.Context, dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+
+	in := new(wrapperspb.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(echoServer).Echo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: echoMethod,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(echoServer).Echo(ctx, req.(*wrapperspb.StringValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Check just ensures the error is nil, or complains and quits
+func check(e error) {
+	if e != nil {
+		fmt.Fprintln(os.Stderr, "Fatal error. Exiting.", "err", e)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build integration
+
+package main
+
+import (
+	"testing"
+
+	"github.com/netsec-ethz/scion-apps/pkg/integration"
+)
+
+const (
+	name = "grpc"
+	bin  = "example-grpc"
+)
+
+func TestGRPCEcho(t *testing.T) {
+	if err := integration.Init(name); err != nil {
+		t.Fatalf("Failed to init: %s\n", err)
+	}
+	cmd := integration.AppBinPath(bin)
+	serverPort := "12346"
+	serverArgs := []string{"-port", serverPort}
+	clientArgs := []string{"-remote", integration.DstAddrPattern + ":" + serverPort, "-message", "hello grpc"}
+
+	in := integration.NewAppsIntegration(name, "echo", cmd, cmd, clientArgs, serverArgs, true)
+	in.ServerStdout(integration.Contains("Received: hello grpc"))
+	in.ClientStdout(integration.Contains("Reply: hello grpc"))
+	in.ClientStderr(integration.NoPanic())
+
+	IAPairs := integration.IAPairs(integration.HostAddr)
+	IAPairs = IAPairs[:len(IAPairs)/2]
+	if err := integration.RunTests(in, IAPairs, integration.DefaultClientTimeout, 0); err != nil {
+		t.Fatalf("Error during tests err: %v", err)
+	}
+}
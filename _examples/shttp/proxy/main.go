@@ -9,8 +9,8 @@ import (
 	"net/http/httputil"
 	"net/url"
 
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
 	"github.com/netsec-ethz/scion-apps/pkg/shttp"
-	"github.com/scionproto/scion/go/lib/snet"
 )
 
 func main() {
@@ -22,9 +22,9 @@ func main() {
 
 	mux := http.NewServeMux()
 
-	// parseUDPAddr validates if the address is a SCION address
+	// ParseAddr validates if the address is a SCION address
 	// which we can use to proxy to SCION
-	if _, err := snet.ParseUDPAddr(*remote); err == nil {
+	if _, err := appnet.ParseAddr(*remote); err == nil {
 		proxyHandler, err := shttp.NewSingleSCIONHostReverseProxy(*remote, &tls.Config{InsecureSkipVerify: true})
 		if err != nil {
 			log.Fatalf("Failed to create SCION reverse proxy %s", err)
@@ -38,14 +38,17 @@ func main() {
 			log.Fatal(fmt.Sprintf("Failed parse remote %s, %s", *remote, err))
 		}
 		log.Printf("Proxy to HTTP remote %s\n", *remote)
-		mux.Handle("/", httputil.NewSingleHostReverseProxy(u))
+		proxyHandler := httputil.NewSingleHostReverseProxy(u)
+		// pass the SCION address of the client to the backend
+		shttp.ForwardSCIONAddr(proxyHandler)
+		mux.Handle("/", proxyHandler)
 	}
 
-	if lAddr, err := snet.ParseUDPAddr(*local); err == nil {
+	if lAddr, err := appnet.ParseAddr(*local); err == nil {
 		log.Printf("Listen on SCION %s\n", *local)
 		// ListenAndServe does not support listening on a complete SCION Address,
 		// Consequently, we only use the port (as seen in the server example)
-		log.Fatalf("%s", shttp.ListenAndServe(fmt.Sprintf(":%d", lAddr.Host.Port), mux, nil))
+		log.Fatalf("%s", shttp.ListenAndServe(fmt.Sprintf(":%d", lAddr.Port), mux, nil))
 	} else {
 		log.Printf("Listen on HTTP %s\n", *local)
 		log.Fatalf("%s", http.ListenAndServe(*local, mux))
@@ -252,10 +252,6 @@ func main() {
 	var (
 		serverCCAddrStr string
 		serverCCAddr    *snet.UDPAddr
-		// Control channel connection
-		CCConn *snet.Conn
-		// Data channel connection
-		DCConn *snet.Conn
 
 		clientBwpStr string
 		clientBwp    BwtestParameters
@@ -264,10 +260,12 @@ func main() {
 		interactive  bool
 		pathAlgo     string
 
-		err   error
-		tzero time.Time // initialized to "zero" time
+		continuous bool
+		count      int
+		jsonOutput bool
+		csvFile    string
 
-		receiveDone sync.Mutex // used to signal when the HandleDCConnReceive goroutine has completed
+		err error
 	)
 
 	flag.Usage = printUsage
@@ -276,6 +274,10 @@ func main() {
 	flag.StringVar(&clientBwpStr, "cs", DefaultBwtestParameters, "Client->Server test parameter")
 	flag.BoolVar(&interactive, "i", false, "Interactive path selection, prompt to choose path")
 	flag.StringVar(&pathAlgo, "pathAlgo", "", "Path selection algorithm / metric (\"shortest\", \"mtu\")")
+	flag.BoolVar(&continuous, "continuous", false, "Run tests continuously, reporting the results for each test interval")
+	flag.IntVar(&count, "count", 0, "Number of test intervals in continuous mode (0 for unlimited)")
+	flag.BoolVar(&jsonOutput, "json", false, "Report results of continuous mode as newline-delimited JSON")
+	flag.StringVar(&csvFile, "csv", "", "Append the results of each test as a row to this CSV file")
 
 	flag.Parse()
 	flagset := make(map[string]bool)
@@ -297,38 +299,14 @@ func main() {
 	}
 
 	var path snet.Path
+	metric := appnet.PathAlgoMetric(pathAlgo)
 	if interactive {
 		path, err = appnet.ChoosePathInteractive(serverCCAddr.IA)
 		Check(err)
 	} else {
-		var metric int
-		if pathAlgo == "mtu" {
-			metric = appnet.MTU
-		} else if pathAlgo == "shortest" {
-			metric = appnet.Shortest
-		}
 		path, err = appnet.ChoosePathByMetric(metric, serverCCAddr.IA)
 		Check(err)
 	}
-	if path != nil {
-		appnet.SetPath(serverCCAddr, path)
-	}
-
-	CCConn, err = appnet.DialAddr(serverCCAddr)
-	Check(err)
-
-	// get the port used by clientCC after it bound to the dispatcher (because it might be 0)
-	clientCCAddr := CCConn.LocalAddr().(*net.UDPAddr)
-	// Address of client data channel (DC)
-	clientDCAddr := &net.UDPAddr{IP: clientCCAddr.IP, Port: clientCCAddr.Port + 1}
-	// Address of server data channel (DC)
-	serverDCAddr := serverCCAddr.Copy()
-	serverDCAddr.Host.Port = serverCCAddr.Host.Port + 1
-
-	// Data channel connection
-	DCConn, err = appnet.DefNetwork().Dial(
-		context.TODO(), "udp", clientDCAddr, serverDCAddr, addr.SvcNone)
-	Check(err)
 
 	// update default packet size to max MTU on the selected path
 	if path != nil {
@@ -342,20 +320,116 @@ func main() {
 		fmt.Println("Only sc parameter set, using same values for cs")
 	}
 	clientBwp = parseBwtestParameters(clientBwpStr)
-	clientBwp.Port = uint16(clientDCAddr.Port)
 	if !flagset["sc"] && flagset["cs"] { // Only one direction set, used same for reverse
 		serverBwpStr = clientBwpStr
 		fmt.Println("Only cs parameter set, using same values for sc")
 	}
 	serverBwp = parseBwtestParameters(serverBwpStr)
-	serverBwp.Port = uint16(serverDCAddr.Host.Port)
+
+	if continuous {
+		// Re-select the path for each interval, unless the path was chosen interactively
+		choosePath := func() (snet.Path, error) {
+			return appnet.ChoosePathByMetric(metric, serverCCAddr.IA)
+		}
+		if interactive {
+			choosePath = func() (snet.Path, error) { return path, nil }
+		}
+		runContinuous(serverCCAddr, choosePath, clientBwp, serverBwp, count, jsonOutput, csvFile)
+		return
+	}
+
+	CCConn, DCConn, err := dialBwtest(serverCCAddr, path)
+	Check(err)
+	defer CCConn.Close()
+	setDCPorts(DCConn, &clientBwp, &serverBwp)
+
 	fmt.Println("\nTest parameters:")
-	fmt.Println("clientDCAddr -> serverDCAddr", clientDCAddr, "->", serverDCAddr)
+	fmt.Println("clientDCAddr -> serverDCAddr", DCConn.LocalAddr(), "->", DCConn.RemoteAddr())
 	fmt.Printf("client->server: %d seconds, %d bytes, %d packets\n",
 		int(clientBwp.BwtestDuration/time.Second), clientBwp.PacketSize, clientBwp.NumPackets)
 	fmt.Printf("server->client: %d seconds, %d bytes, %d packets\n",
 		int(serverBwp.BwtestDuration/time.Second), serverBwp.PacketSize, serverBwp.NumPackets)
 
+	clientRes, serverRes, err := runBwtest(CCConn, DCConn, clientBwp, serverBwp)
+	if clientRes == nil {
+		Check(err)
+	}
+	if csvFile != "" {
+		record := newCSVRecord(time.Now(), localAddr(CCConn), serverCCAddr, path)
+		record.setStats(clientBwp, serverBwp, clientRes, serverRes)
+		Check(appendCSV(csvFile, record))
+	}
+
+	fmt.Println("\nS->C results")
+	printBwtestResult(serverBwp, clientRes)
+
+	if serverRes == nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("\nC->S results")
+	printBwtestResult(clientBwp, serverRes)
+}
+
+// dialBwtest opens the control channel (CC) and data channel (DC) connections
+// to the server, using the given path (which may be nil in the local AS).
+func dialBwtest(serverCCAddr *snet.UDPAddr, path snet.Path) (CCConn, DCConn *snet.Conn, err error) {
+	serverCCAddr = serverCCAddr.Copy()
+	if path != nil {
+		appnet.SetPath(serverCCAddr, path)
+	}
+
+	CCConn, err = appnet.DialAddr(serverCCAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// get the port used by clientCC after it bound to the dispatcher (because it might be 0)
+	clientCCAddr := CCConn.LocalAddr().(*net.UDPAddr)
+	// Address of client data channel (DC)
+	clientDCAddr := &net.UDPAddr{IP: clientCCAddr.IP, Port: clientCCAddr.Port + 1}
+	// Address of server data channel (DC)
+	serverDCAddr := serverCCAddr.Copy()
+	serverDCAddr.Host.Port = serverCCAddr.Host.Port + 1
+
+	// Data channel connection
+	DCConn, err = appnet.DefNetwork().Dial(
+		context.TODO(), "udp", clientDCAddr, serverDCAddr, addr.SvcNone)
+	if err != nil {
+		CCConn.Close()
+		return nil, nil, err
+	}
+	return CCConn, DCConn, nil
+}
+
+// localAddr returns the local SCION address of the connection.
+func localAddr(conn *snet.Conn) *snet.UDPAddr {
+	return &snet.UDPAddr{IA: appnet.DefNetwork().IA, Host: conn.LocalAddr().(*net.UDPAddr)}
+}
+
+// setDCPorts sets the data channel ports in the test parameters for both
+// directions.
+func setDCPorts(DCConn *snet.Conn, clientBwp, serverBwp *BwtestParameters) {
+	clientBwp.Port = uint16(DCConn.LocalAddr().(*net.UDPAddr).Port)
+	serverBwp.Port = uint16(DCConn.RemoteAddr().(*snet.UDPAddr).Host.Port)
+}
+
+// runBwtest runs a single bandwidth test over the given CC and DC connections.
+// It returns the results of the server->client direction as measured by the
+// client and the results of the client->server direction, fetched from the
+// server.
+// If the test ran but the server results could not be fetched, the
+// client results are returned together with the error.
+func runBwtest(CCConn, DCConn *snet.Conn,
+	clientBwp, serverBwp BwtestParameters) (*BwtestResult, *BwtestResult, error) {
+
+	var (
+		err   error
+		tzero time.Time // initialized to "zero" time
+
+		receiveDone sync.Mutex // used to signal when the HandleDCConnReceive goroutine has completed
+	)
+
 	t := time.Now()
 	expFinishTimeSend := t.Add(serverBwp.BwtestDuration + MaxRTT + GracePeriodSend)
 	expFinishTimeReceive := t.Add(clientBwp.BwtestDuration + MaxRTT + StragglerWaitPeriod)
@@ -378,6 +452,15 @@ func main() {
 
 	receiveDone.Lock()
 	go HandleDCConnReceive(&serverBwp, DCConn, &res, &resLock, &receiveDone)
+	// stopReceive stops the receiver if the test cannot be started, and waits
+	// for it to return
+	stopReceive := func() {
+		resLock.Lock()
+		res.ExpectedFinishTime = time.Now()
+		resLock.Unlock()
+		DCConn.Close()
+		receiveDone.Lock()
+	}
 
 	pktbuf := make([]byte, 2000)
 	pktbuf[0] = 'N' // Request for new bwtest
@@ -389,10 +472,16 @@ func main() {
 	var numtries int64 = 0
 	for numtries < MaxTries {
 		_, err = CCConn.Write(pktbuf[:l])
-		Check(err)
+		if err != nil {
+			stopReceive()
+			return nil, nil, err
+		}
 
 		err = CCConn.SetReadDeadline(time.Now().Add(MaxRTT))
-		Check(err)
+		if err != nil {
+			stopReceive()
+			return nil, nil, err
+		}
 		n, err = CCConn.Read(pktbuf)
 		if err != nil {
 			// A timeout likely happened, see if we should adjust the expected finishing time
@@ -408,7 +497,10 @@ func main() {
 		}
 		// Remove read deadline
 		err = CCConn.SetReadDeadline(tzero)
-		Check(err)
+		if err != nil {
+			stopReceive()
+			return nil, nil, err
+		}
 
 		if n != 2 {
 			fmt.Println("Incorrect server response, trying again")
@@ -416,6 +508,11 @@ func main() {
 			numtries++
 			continue
 		}
+		if pktbuf[0] == RejectResponse {
+			// The server refuses this test, retrying does not help
+			stopReceive()
+			return nil, nil, RejectionError(pktbuf[1])
+		}
 		if pktbuf[0] != 'N' {
 			fmt.Println("Incorrect server response, trying again")
 			time.Sleep(Timeout)
@@ -434,36 +531,28 @@ func main() {
 	}
 
 	if numtries == MaxTries {
-		Check(fmt.Errorf("Error, could not receive a server response, MaxTries attempted without success."))
+		stopReceive()
+		return nil, nil, fmt.Errorf("Error, could not receive a server response, MaxTries attempted without success.")
 	}
 
 	go HandleDCConnSend(&clientBwp, DCConn)
 
 	receiveDone.Lock()
 
-	fmt.Println("\nS->C results")
-	att := 8 * serverBwp.PacketSize * serverBwp.NumPackets / int64(serverBwp.BwtestDuration/time.Second)
-	ach := 8 * serverBwp.PacketSize * res.CorrectlyReceived / int64(serverBwp.BwtestDuration/time.Second)
-	fmt.Printf("Attempted bandwidth: %d bps / %.2f Mbps\n", att, float64(att)/1000000)
-	fmt.Printf("Achieved bandwidth: %d bps / %.2f Mbps\n", ach, float64(ach)/1000000)
-	fmt.Println("Loss rate:", (serverBwp.NumPackets-res.CorrectlyReceived)*100/serverBwp.NumPackets, "%")
-	variance := res.IPAvar
-	average := res.IPAavg
-	fmt.Printf("Interarrival time variance: %dms, average interarrival time: %dms\n",
-		variance/1e6, average/1e6)
-	fmt.Printf("Interarrival time min: %dms, interarrival time max: %dms\n",
-		res.IPAmin/1e6, res.IPAmax/1e6)
-
 	// Fetch results from server
 	numtries = 0
 	for numtries < MaxTries {
 		pktbuf[0] = 'R'
 		copy(pktbuf[1:], clientBwp.PrgKey)
 		_, err = CCConn.Write(pktbuf[:1+len(clientBwp.PrgKey)])
-		Check(err)
+		if err != nil {
+			return &res, nil, err
+		}
 
 		err = CCConn.SetReadDeadline(time.Now().Add(MaxRTT))
-		Check(err)
+		if err != nil {
+			return &res, nil, err
+		}
 		n, err = CCConn.Read(pktbuf)
 		if err != nil {
 			numtries++
@@ -471,7 +560,9 @@ func main() {
 		}
 		// Remove read deadline
 		err = CCConn.SetReadDeadline(tzero)
-		Check(err)
+		if err != nil {
+			return &res, nil, err
+		}
 
 		if n < 2 {
 			numtries++
@@ -484,7 +575,7 @@ func main() {
 		if pktbuf[1] != byte(0) {
 			// Error case
 			if pktbuf[1] == byte(127) {
-				Check(fmt.Errorf("Results could not be found or PRG key was incorrect, abort"))
+				return &res, nil, fmt.Errorf("Results could not be found or PRG key was incorrect, abort")
 			}
 			// pktbuf[1] contains number of seconds to wait for results
 			fmt.Println("We need to sleep for", pktbuf[1], "seconds before we can get the results")
@@ -510,20 +601,44 @@ func main() {
 			numtries++
 			continue
 		}
-		fmt.Println("\nC->S results")
-		att = 8 * clientBwp.PacketSize * clientBwp.NumPackets / int64(clientBwp.BwtestDuration/time.Second)
-		ach = 8 * clientBwp.PacketSize * sres.CorrectlyReceived / int64(clientBwp.BwtestDuration/time.Second)
-		fmt.Printf("Attempted bandwidth: %d bps / %.2f Mbps\n", att, float64(att)/1000000)
-		fmt.Printf("Achieved bandwidth: %d bps / %.2f Mbps\n", ach, float64(ach)/1000000)
-		fmt.Println("Loss rate:", (clientBwp.NumPackets-sres.CorrectlyReceived)*100/clientBwp.NumPackets, "%")
-		variance := sres.IPAvar
-		average := sres.IPAavg
-		fmt.Printf("Interarrival time variance: %dms, average interarrival time: %dms\n",
-			variance/1e6, average/1e6)
-		fmt.Printf("Interarrival time min: %dms, interarrival time max: %dms\n",
-			sres.IPAmin/1e6, sres.IPAmax/1e6)
-		return
+		return &res, sres, nil
 	}
 
-	fmt.Println("Error, could not fetch server results, MaxTries attempted without success.")
+	return &res, nil, fmt.Errorf("Error, could not fetch server results, MaxTries attempted without success.")
+}
+
+// bwtestStats summarizes the result of one direction of a bandwidth test
+type bwtestStats struct {
+	AttemptedBps int64   `json:"attempted_bps"`
+	AchievedBps  int64   `json:"achieved_bps"`
+	LossRate     float64 `json:"loss_percent"`
+}
+
+func computeStats(bwp BwtestParameters, res *BwtestResult) bwtestStats {
+	seconds := int64(bwp.BwtestDuration / time.Second)
+	return bwtestStats{
+		AttemptedBps: 8 * bwp.PacketSize * bwp.NumPackets / seconds,
+		AchievedBps:  8 * bwp.PacketSize * res.CorrectlyReceived / seconds,
+		LossRate:     float64(bwp.NumPackets-res.CorrectlyReceived) * 100 / float64(bwp.NumPackets),
+	}
+}
+
+func printBwtestResult(bwp BwtestParameters, res *BwtestResult) {
+	stats := computeStats(bwp, res)
+	fmt.Printf("Attempted bandwidth: %d bps / %.2f Mbps\n", stats.AttemptedBps, float64(stats.AttemptedBps)/1000000)
+	fmt.Printf("Achieved bandwidth: %d bps / %.2f Mbps\n", stats.AchievedBps, float64(stats.AchievedBps)/1000000)
+	fmt.Println("Loss rate:", (bwp.NumPackets-res.CorrectlyReceived)*100/bwp.NumPackets, "%")
+	if res.Lost > 0 {
+		fmt.Printf("Lost packets: %d\n", res.Lost)
+	}
+	if res.Reordered > 0 || res.Duplicates > 0 {
+		fmt.Printf("Reordered packets: %d (%d beyond reorder window), duplicate packets: %d\n",
+			res.Reordered, res.Late, res.Duplicates)
+	}
+	variance := res.IPAvar
+	average := res.IPAavg
+	fmt.Printf("Interarrival time variance: %dms, average interarrival time: %dms\n",
+		variance/1e6, average/1e6)
+	fmt.Printf("Interarrival time min: %dms, interarrival time max: %dms\n",
+		res.IPAmin/1e6, res.IPAmax/1e6)
 }
@@ -61,6 +61,15 @@ func TestIntegrationBwtestclient(t *testing.T) {
 			integration.RegExp("^Achieved bandwidth: \\d+ bps / \\d+.\\d+ [Mk]bps$"),
 			nil,
 		},
+		{
+			"bandwidth_client_continuous",
+			append([]string{"-s", integration.DstAddrPattern + ":" + serverPort, "-cs", "1,?,?,1Mbps",
+				"-continuous", "-count", "2"}, cmnArgs...),
+			nil,
+			nil,
+			integration.RegExp("^\\[  2\\] .* s->c: .* c->s: .* avg s->c: \\d+.\\d+ Mbps, c->s: \\d+.\\d+ Mbps$"),
+			nil,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,155 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	. "github.com/netsec-ethz/scion-apps/bwtester/bwtestlib"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+// intervalReport is the record emitted for each test interval in continuous
+// mode with -json.
+type intervalReport struct {
+	Interval    int          `json:"interval"`
+	Time        time.Time    `json:"time"`
+	Path        string       `json:"path,omitempty"`
+	Fingerprint string       `json:"fingerprint,omitempty"`
+	SC          *bwtestStats `json:"sc,omitempty"`
+	CS          *bwtestStats `json:"cs,omitempty"`
+	AvgSC       int64        `json:"avg_sc_bps"`
+	AvgCS       int64        `json:"avg_cs_bps"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// runContinuous runs back-to-back bandwidth tests and reports the results of
+// each test interval, along with the running averages of the achieved
+// bandwidth. The path is re-selected for every interval using choosePath.
+// Failed intervals are reported and the tests continue.
+// If count is 0, runs until the process is interrupted.
+// If csvFile is not empty, a row is appended to this file for each interval.
+func runContinuous(serverCCAddr *snet.UDPAddr, choosePath func() (snet.Path, error),
+	clientBwp, serverBwp BwtestParameters, count int, jsonOutput bool, csvFile string) {
+
+	var (
+		start    = time.Now()
+		lastFp   snet.PathFingerprint
+		sumSC    int64
+		sumCS    int64
+		numSC    int64
+		numCS    int64
+		encoder  = json.NewEncoder(os.Stdout)
+		interval int
+	)
+
+	if !jsonOutput {
+		fmt.Printf("Running continuous test, %d seconds per interval (s->c), %d seconds (c->s)\n",
+			int(serverBwp.BwtestDuration/time.Second), int(clientBwp.BwtestDuration/time.Second))
+	}
+	for interval = 1; count == 0 || interval <= count; interval++ {
+		report := intervalReport{
+			Interval: interval,
+			Time:     time.Now(),
+		}
+
+		// Fresh keys for every test, the server identifies the results by key
+		clientBwp.PrgKey = prepareAESKey()
+		serverBwp.PrgKey = prepareAESKey()
+
+		path, err := choosePath()
+		if err == nil && path != nil {
+			fp := snet.Fingerprint(path)
+			report.Path = fmt.Sprint(path)
+			report.Fingerprint = fp.String()
+			if fp != lastFp && !jsonOutput {
+				fmt.Println("Using path:", path)
+			}
+			lastFp = fp
+		}
+
+		var clientRes, serverRes *BwtestResult
+		var local *snet.UDPAddr
+		if err == nil {
+			local, clientRes, serverRes, err = runInterval(serverCCAddr, path, clientBwp, serverBwp)
+		}
+		if clientRes != nil {
+			stats := computeStats(serverBwp, clientRes)
+			report.SC = &stats
+			sumSC += stats.AchievedBps
+			numSC++
+		}
+		if serverRes != nil {
+			stats := computeStats(clientBwp, serverRes)
+			report.CS = &stats
+			sumCS += stats.AchievedBps
+			numCS++
+		}
+		if numSC > 0 {
+			report.AvgSC = sumSC / numSC
+		}
+		if numCS > 0 {
+			report.AvgCS = sumCS / numCS
+		}
+		if err != nil {
+			report.Error = err.Error()
+		}
+
+		if csvFile != "" {
+			record := newCSVRecord(report.Time, local, serverCCAddr, path)
+			record.SC, record.CS = report.SC, report.CS
+			Check(appendCSV(csvFile, record))
+		}
+		if jsonOutput {
+			Check(encoder.Encode(report))
+		} else {
+			printIntervalReport(report, time.Since(start))
+		}
+	}
+}
+
+// runInterval runs a single test interval and returns the local address used,
+// and the results as returned by runBwtest.
+func runInterval(serverCCAddr *snet.UDPAddr, path snet.Path,
+	clientBwp, serverBwp BwtestParameters) (*snet.UDPAddr, *BwtestResult, *BwtestResult, error) {
+
+	CCConn, DCConn, err := dialBwtest(serverCCAddr, path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer CCConn.Close()
+	setDCPorts(DCConn, &clientBwp, &serverBwp)
+	clientRes, serverRes, err := runBwtest(CCConn, DCConn, clientBwp, serverBwp)
+	return localAddr(CCConn), clientRes, serverRes, err
+}
+
+func printIntervalReport(r intervalReport, elapsed time.Duration) {
+	fmtStats := func(s *bwtestStats) string {
+		if s == nil {
+			return "        -"
+		}
+		return fmt.Sprintf("%6.2f Mbps %3.0f%% loss", float64(s.AchievedBps)/1e6, s.LossRate)
+	}
+	fmt.Printf("[%3d] %6.1fs  s->c: %s  c->s: %s  avg s->c: %.2f Mbps, c->s: %.2f Mbps",
+		r.Interval, elapsed.Seconds(), fmtStats(r.SC), fmtStats(r.CS),
+		float64(r.AvgSC)/1e6, float64(r.AvgCS)/1e6)
+	if r.Error != "" {
+		fmt.Printf("  error: %s", r.Error)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	. "github.com/netsec-ethz/scion-apps/bwtester/bwtestlib"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+var csvHeader = []string{
+	"timestamp", "source", "destination", "path_fingerprint",
+	"sc_attempted_bps", "sc_achieved_bps", "sc_loss_percent",
+	"cs_attempted_bps", "cs_achieved_bps", "cs_loss_percent",
+}
+
+// csvRecord is a row in the CSV log written with -csv. The stats are nil for
+// a direction without results; the corresponding fields are left empty.
+type csvRecord struct {
+	Time        time.Time
+	Source      string
+	Destination string
+	Fingerprint string
+	SC          *bwtestStats
+	CS          *bwtestStats
+}
+
+func newCSVRecord(t time.Time, src, dst *snet.UDPAddr, path snet.Path) csvRecord {
+	r := csvRecord{Time: t, Destination: dst.String()}
+	if src != nil {
+		r.Source = src.String()
+	}
+	if path != nil {
+		r.Fingerprint = snet.Fingerprint(path).String()
+	}
+	return r
+}
+
+// setStats sets the stats from the results of the server->client and
+// client->server direction, either of which may be nil.
+func (r *csvRecord) setStats(clientBwp, serverBwp BwtestParameters, clientRes, serverRes *BwtestResult) {
+	if clientRes != nil {
+		stats := computeStats(serverBwp, clientRes)
+		r.SC = &stats
+	}
+	if serverRes != nil {
+		stats := computeStats(clientBwp, serverRes)
+		r.CS = &stats
+	}
+}
+
+func (r csvRecord) fields() []string {
+	statsFields := func(s *bwtestStats) []string {
+		if s == nil {
+			return []string{"", "", ""}
+		}
+		return []string{
+			strconv.FormatInt(s.AttemptedBps, 10),
+			strconv.FormatInt(s.AchievedBps, 10),
+			strconv.FormatFloat(s.LossRate, 'f', 2, 64),
+		}
+	}
+	fields := []string{r.Time.UTC().Format(time.RFC3339), r.Source, r.Destination, r.Fingerprint}
+	fields = append(fields, statsFields(r.SC)...)
+	return append(fields, statsFields(r.CS)...)
+}
+
+// appendCSV appends the record to the CSV file, creating the file and writing
+// the header first if it does not exist yet or is empty.
+// The file is locked while writing, so that the log can safely be shared by
+// concurrently running clients.
+func appendCSV(filename string, r csvRecord) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// the lock is released when the file is closed
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if fi.Size() == 0 {
+		_ = w.Write(csvHeader)
+	}
+	_ = w.Write(r.fields())
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return f.Sync()
+}
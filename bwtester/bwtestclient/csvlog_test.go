@@ -0,0 +1,111 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func readCSV(t *testing.T, filename string) [][]string {
+	t.Helper()
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestAppendCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bwtester-csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "log.csv")
+
+	ts := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+	first := csvRecord{
+		Time:        ts,
+		Source:      "1-ff00:0:111,127.0.0.1:40001",
+		Destination: "1-ff00:0:112,127.0.0.2:40002",
+		Fingerprint: "abc",
+		SC:          &bwtestStats{AttemptedBps: 1000000, AchievedBps: 900000, LossRate: 10},
+		CS:          &bwtestStats{AttemptedBps: 2000000, AchievedBps: 2000000, LossRate: 0},
+	}
+	// second test failed to fetch the client->server results
+	second := first
+	second.Time = ts.Add(time.Minute)
+	second.CS = nil
+
+	if err := appendCSV(filename, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendCSV(filename, second); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{
+		csvHeader,
+		{"2021-07-01T12:00:00Z", first.Source, first.Destination, "abc",
+			"1000000", "900000", "10.00", "2000000", "2000000", "0.00"},
+		{"2021-07-01T12:01:00Z", first.Source, first.Destination, "abc",
+			"1000000", "900000", "10.00", "", "", ""},
+	}
+	if actual := readCSV(t, filename); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestAppendCSVConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bwtester-csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "log.csv")
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := appendCSV(filename, csvRecord{Time: time.Now()}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	rows := readCSV(t, filename)
+	if len(rows) != n+1 {
+		t.Fatalf("expected %d rows, got %d", n+1, len(rows))
+	}
+	if !reflect.DeepEqual(rows[0], csvHeader) {
+		t.Errorf("expected header first, got %v", rows[0])
+	}
+}
@@ -43,6 +43,9 @@ const (
 	MaxPacketSize int64 = 66000
 	// Make sure the port number is a port the server application can connect to
 	MinPort uint16 = 1024
+	// Max packet rate, to bound the number of packets of a test (and the memory
+	// needed to track them) by the duration of the test
+	MaxPacketRate int64 = 1000000
 
 	MaxTries int64         = 5 // Number of times to try to reach server
 	Timeout  time.Duration = time.Millisecond * 500
@@ -64,6 +67,16 @@ type BwtestResult struct {
 	IPAmin             int64
 	IPAavg             int64
 	IPAmax             int64
+	// Number of packets received out of order, included in CorrectlyReceived
+	Reordered int64
+	// Number of reordered packets that arrived more than ReorderWindow packets
+	// late, included in Reordered
+	Late int64
+	// Number of packets that have not been received, i.e. that were
+	// overtaken by more than ReorderWindow packets and did not arrive later
+	Lost int64
+	// Number of duplicate packets, not included in CorrectlyReceived
+	Duplicates int64
 	// Contains the client's sending PRG key, so that the result can be uniquely identified
 	// Only requests that contain the correct key can obtain the result
 	PrgKey             []byte
@@ -156,9 +169,39 @@ func DecodeBwtestParameters(buf []byte) (*BwtestParameters, int, error) {
 	if v.Port < MinPort {
 		v.Port = MinPort
 	}
+	if v.NumPackets < 0 {
+		v.NumPackets = 0
+	}
+	if max := maxNumPackets(v.BwtestDuration, v.PacketSize); v.NumPackets > max {
+		v.NumPackets = max
+	}
 	return &v, is - bb.Len(), err
 }
 
+// maxNumPackets returns the maximum number of packets of a test with the given
+// duration and packet size: at most MaxPacketRate packets per second (but at
+// least one packet), and at most as many packets as can be numbered by the
+// 32-bit byte offset at the beginning of each packet.
+func maxNumPackets(duration time.Duration, packetSize int64) int64 {
+	max := int64(duration.Seconds() * float64(MaxPacketRate))
+	if max < 1 {
+		max = 1
+	}
+	if seqMax := maxSeqPackets(packetSize); max > seqMax {
+		max = seqMax
+	}
+	return max
+}
+
+// maxSeqPackets returns the number of packets that can be numbered by the
+// 32-bit byte offset at the beginning of each packet.
+func maxSeqPackets(packetSize int64) int64 {
+	if packetSize < MinPacketSize {
+		packetSize = MinPacketSize
+	}
+	return (1 << 32) / packetSize
+}
+
 func HandleDCConnSend(bwp *BwtestParameters, udpConnection *snet.Conn) {
 	sb := make([]byte, bwp.PacketSize)
 	var i int64 = 0
@@ -197,6 +240,7 @@ func HandleDCConnReceive(bwp *BwtestParameters, udpConnection *snet.Conn, res *B
 	resLock.Unlock()
 	var numPacketsReceived, correctlyReceived int64 = 0, 0
 	InterPacketArrivalTime := make(map[int]int64)
+	seqs := newSeqTracker(bwp.NumPackets, bwp.PacketSize, ReorderWindow)
 	_ = udpConnection.SetReadDeadline(finish)
 	// Make the receive buffer a bit larger to enable detection of packets that are too large
 	recBuf := make([]byte, bwp.PacketSize+1000)
@@ -227,11 +271,16 @@ func HandleDCConnReceive(bwp *BwtestParameters, udpConnection *snet.Conn, res *B
 		// so that a discrepancy is noticed immediately without generating the
 		// entire packet
 		iv := int64(binary.LittleEndian.Uint32(recBuf))
-		seqNo := int(iv / bwp.PacketSize)
-		InterPacketArrivalTime[seqNo] = time.Now().UnixNano()
+		seqNo := iv / bwp.PacketSize
+		arrival := time.Now().UnixNano()
 		PrgFill(bwp.PrgKey, int(iv), cmpBuf)
 		binary.LittleEndian.PutUint32(cmpBuf, uint32(iv))
 		if bytes.Equal(recBuf[:bwp.PacketSize], cmpBuf) {
+			// Count each packet only once, even if it was duplicated
+			if !seqs.add(seqNo) {
+				continue
+			}
+			InterPacketArrivalTime[int(seqNo)] = arrival
 			if correctlyReceived == 0 {
 				// Adjust finish time after first correctly received packet
 				// Note that we should check that we're not too far away from the beginning of the
@@ -259,6 +308,8 @@ func HandleDCConnReceive(bwp *BwtestParameters, udpConnection *snet.Conn, res *B
 	resLock.Lock()
 	res.NumPacketsReceived = numPacketsReceived
 	res.CorrectlyReceived = correctlyReceived
+	seqs.finish()
+	seqs.setResult(res)
 	res.IPAvar, res.IPAmin, res.IPAavg, res.IPAmax = aggrInterArrivalTime(InterPacketArrivalTime)
 
 	// We're done here, let's see if we need to wait for the send function to complete so we can close the connection
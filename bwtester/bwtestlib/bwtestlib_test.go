@@ -0,0 +1,67 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bwtestlib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeBwtestParametersNumPackets(t *testing.T) {
+	cases := []struct {
+		name       string
+		duration   time.Duration
+		packetSize int64
+		numPackets int64
+		expected   int64
+	}{
+		{"valid", 3 * time.Second, 1000, 3000, 3000},
+		{"negative", 3 * time.Second, 1000, -1000, 0},
+		{"most negative", 3 * time.Second, 1000, -1 << 63, 0},
+		{"above packet rate", 3 * time.Second, 1000, 1 << 40, 3 * MaxPacketRate},
+		{"above duration limit", time.Hour, 100, 1 << 62, int64(MaxDuration.Seconds()) * MaxPacketRate},
+		{"above sequence numbers", 10 * time.Second, MaxPacketSize, 1 << 62, (1 << 32) / MaxPacketSize},
+		{"zero duration", 0, 1000, 1000, 1},
+	}
+	for _, c := range cases {
+		bwp := BwtestParameters{
+			BwtestDuration: c.duration,
+			PacketSize:     c.packetSize,
+			NumPackets:     c.numPackets,
+			Port:           2000,
+		}
+		buf := make([]byte, 1000)
+		n := EncodeBwtestParameters(&bwp, buf)
+		decoded, _, err := DecodeBwtestParameters(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.NumPackets != c.expected {
+			t.Errorf("%s: expected %d packets, got %d", c.name, c.expected, decoded.NumPackets)
+		}
+		// the receiver can track the decoded number of packets
+		seqs := newSeqTracker(decoded.NumPackets, decoded.PacketSize, ReorderWindow)
+		if seqs.n != decoded.NumPackets {
+			t.Errorf("%s: expected tracker for %d packets, got %d", c.name, decoded.NumPackets, seqs.n)
+		}
+	}
+}
+
+func TestSeqTrackerSizeCapped(t *testing.T) {
+	seqs := newSeqTracker(1<<62, MaxPacketSize, ReorderWindow)
+	if expected := int64(1<<32) / MaxPacketSize; seqs.n != expected {
+		t.Errorf("expected tracker for %d packets, got %d", expected, seqs.n)
+	}
+}
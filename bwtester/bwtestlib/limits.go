@@ -0,0 +1,93 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bwtestlib
+
+import (
+	"fmt"
+	"time"
+)
+
+// RejectResponse is the type of the server response to a new bwtest request
+// ('N') that was rejected because it exceeds the limits of the server. The
+// second byte of the response is one of the Reject* codes. The client must
+// not retry the same request.
+// A distinct response type is used, as servers without limits may send any
+// number of seconds to wait in the second byte of an 'N' response.
+const RejectResponse byte = 'X'
+
+// Codes in the RejectResponse, indicating the exceeded limit.
+const (
+	RejectDuration  byte = 1
+	RejectBandwidth byte = 2
+	RejectSize      byte = 3
+)
+
+// RejectionError returns the error reported to the user for a rejected
+// request.
+func RejectionError(code byte) error {
+	var reason string
+	switch code {
+	case RejectDuration:
+		reason = "test duration exceeds the server's limit"
+	case RejectBandwidth:
+		reason = "test bandwidth exceeds the server's limit"
+	case RejectSize:
+		reason = "total test size exceeds the server's limit"
+	default:
+		reason = fmt.Sprintf("unknown reason %d", code)
+	}
+	return fmt.Errorf("server rejected the bwtest: %s", reason)
+}
+
+// BwtestLimits are the limits a server enforces on the requested tests.
+// A zero value means no limit (other than MaxDuration, which always applies).
+type BwtestLimits struct {
+	// Maximum duration of each direction of the test
+	MaxDuration time.Duration
+	// Maximum bandwidth in bps, of each direction of the test
+	MaxBandwidth int64
+	// Maximum number of bytes sent in both directions of the test together
+	MaxBytes int64
+}
+
+// Check returns 0 if the test with the given parameters is within the
+// limits, or the code with which to reject the request otherwise.
+func (l BwtestLimits) Check(clientBwp, serverBwp *BwtestParameters) byte {
+	for _, bwp := range []*BwtestParameters{clientBwp, serverBwp} {
+		if l.MaxDuration > 0 && bwp.BwtestDuration > l.MaxDuration {
+			return RejectDuration
+		}
+	}
+	for _, bwp := range []*BwtestParameters{clientBwp, serverBwp} {
+		// For a test of duration 0, any non-zero size exceeds the limit
+		bps := 8 * testBytes(bwp) / bwp.BwtestDuration.Seconds()
+		if l.MaxBandwidth > 0 && bps > float64(l.MaxBandwidth) {
+			return RejectBandwidth
+		}
+	}
+	if l.MaxBytes > 0 && testBytes(clientBwp)+testBytes(serverBwp) > float64(l.MaxBytes) {
+		return RejectSize
+	}
+	return 0
+}
+
+// testBytes returns the number of bytes sent in the test direction. Computed
+// as float, as the number of packets is not bounded.
+func testBytes(bwp *BwtestParameters) float64 {
+	if bwp.NumPackets <= 0 {
+		return 0
+	}
+	return float64(bwp.PacketSize) * float64(bwp.NumPackets)
+}
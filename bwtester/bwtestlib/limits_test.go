@@ -0,0 +1,82 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bwtestlib
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBwtestLimitsCheck(t *testing.T) {
+	limits := BwtestLimits{
+		MaxDuration:  5 * time.Second,
+		MaxBandwidth: 1000000,     // 1Mbps
+		MaxBytes:     1000 * 1000, // 1MB
+	}
+	// 3 seconds, 1000 bytes, 300 packets: 800kbps, 300KB
+	ok := BwtestParameters{BwtestDuration: 3 * time.Second, PacketSize: 1000, NumPackets: 300}
+
+	cases := []struct {
+		name     string
+		client   BwtestParameters
+		server   BwtestParameters
+		expected byte
+	}{
+		{"within limits", ok, ok, 0},
+		{"duration", ok, BwtestParameters{BwtestDuration: 8 * time.Second, PacketSize: 1000, NumPackets: 10}, RejectDuration},
+		{"bandwidth", BwtestParameters{BwtestDuration: 3 * time.Second, PacketSize: 1000, NumPackets: 400}, ok, RejectBandwidth},
+		{"zero duration", BwtestParameters{PacketSize: 1000, NumPackets: 1}, ok, RejectBandwidth},
+		{"size", BwtestParameters{BwtestDuration: 5 * time.Second, PacketSize: 1000, NumPackets: 600}, BwtestParameters{BwtestDuration: 5 * time.Second, PacketSize: 1000, NumPackets: 600}, RejectSize},
+		{"overflow", ok, BwtestParameters{BwtestDuration: 3 * time.Second, PacketSize: MaxPacketSize, NumPackets: math.MaxInt64}, RejectBandwidth},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := limits.Check(&c.client, &c.server)
+			if actual != c.expected {
+				t.Errorf("expected %d, got %d", c.expected, actual)
+			}
+		})
+	}
+
+	if code := (BwtestLimits{}).Check(&ok, &BwtestParameters{BwtestDuration: 3 * time.Second, PacketSize: 1000, NumPackets: math.MaxInt64}); code != 0 {
+		t.Errorf("expected no limits for zero value, got %d", code)
+	}
+}
+
+// TestBwtestLimitsDecoded checks the limits on requests as received by the
+// server, i.e. after encoding and decoding the parameters.
+func TestBwtestLimitsDecoded(t *testing.T) {
+	limits := BwtestLimits{MaxDuration: MaxDuration, MaxBandwidth: 10000000}
+	request := func(bwp BwtestParameters) *BwtestParameters {
+		buf := make([]byte, 1000)
+		n := EncodeBwtestParameters(&bwp, buf)
+		decoded, _, err := DecodeBwtestParameters(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decoded
+	}
+	small := request(BwtestParameters{BwtestDuration: 3 * time.Second, PacketSize: 1000, NumPackets: 30, Port: 2000})
+	// the duration is capped to MaxDuration when decoding, but the number of
+	// packets is not, so the bandwidth is too high
+	huge := request(BwtestParameters{BwtestDuration: time.Hour, PacketSize: 1000, NumPackets: 1000000, Port: 2000})
+	if code := limits.Check(small, small); code != 0 {
+		t.Errorf("expected request within limits to be accepted, got %d", code)
+	}
+	if code := limits.Check(small, huge); code != RejectBandwidth {
+		t.Errorf("expected request to be rejected with %d, got %d", RejectBandwidth, code)
+	}
+}
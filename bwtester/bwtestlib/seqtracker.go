@@ -0,0 +1,109 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bwtestlib
+
+// ReorderWindow is the number of packets by which a packet may be overtaken by
+// later packets before it is considered lost. A packet arriving after that is
+// still counted as received (and reordered).
+var ReorderWindow int64 = 64
+
+// seqTracker keeps track of the received sequence numbers of a bandwidth test,
+// to count each packet only once and to detect reordering.
+type seqTracker struct {
+	window   int64
+	n        int64    // number of packets in the test
+	received []uint64 // bitmap of received sequence numbers
+	highest  int64    // highest sequence number received, -1 if none
+	checked  int64    // sequence numbers below this have been checked for loss
+
+	unique     int64 // number of distinct packets received
+	reordered  int64 // packets received after a packet with higher sequence number
+	duplicates int64 // packets received more than once
+	lost       int64 // packets not received within the reorder window
+	late       int64 // packets received after the reorder window had passed
+}
+
+// newSeqTracker returns a seqTracker for a test with numPackets packets of
+// packetSize bytes. The number of packets is capped to the sequence numbers
+// that can occur, regardless of the parameters requested.
+func newSeqTracker(numPackets, packetSize, window int64) *seqTracker {
+	if numPackets < 0 {
+		numPackets = 0
+	}
+	if max := maxSeqPackets(packetSize); numPackets > max {
+		numPackets = max
+	}
+	return &seqTracker{
+		window:   window,
+		n:        numPackets,
+		received: make([]uint64, (numPackets+63)/64),
+		highest:  -1,
+	}
+}
+
+func (t *seqTracker) has(seq int64) bool {
+	return t.received[seq/64]&(1<<uint(seq%64)) != 0
+}
+
+// add records the arrival of the packet with sequence number seq.
+// Returns false if seq is out of range or if the packet was already received.
+func (t *seqTracker) add(seq int64) bool {
+	if seq < 0 || seq >= t.n {
+		return false
+	}
+	if t.has(seq) {
+		t.duplicates++
+		return false
+	}
+	t.received[seq/64] |= 1 << uint(seq%64)
+	t.unique++
+
+	if seq < t.highest {
+		t.reordered++
+		if seq < t.checked {
+			// was already counted as lost, but arrived after all
+			t.lost--
+			t.late++
+		}
+		return true
+	}
+	t.highest = seq
+	// all packets that have now been overtaken by more than window packets
+	// and have not arrived are lost
+	for ; t.checked < t.highest-t.window; t.checked++ {
+		if !t.has(t.checked) {
+			t.lost++
+		}
+	}
+	return true
+}
+
+// finish counts all packets that have not been received as lost, at the end
+// of the test.
+func (t *seqTracker) finish() {
+	for ; t.checked < t.n; t.checked++ {
+		if !t.has(t.checked) {
+			t.lost++
+		}
+	}
+}
+
+// setResult stores the counters in res.
+func (t *seqTracker) setResult(res *BwtestResult) {
+	res.Reordered = t.reordered
+	res.Late = t.late
+	res.Duplicates = t.duplicates
+	res.Lost = t.lost
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bwtestlib
+
+import (
+	"testing"
+)
+
+func TestSeqTrackerReordered(t *testing.T) {
+	// all 10 packets arrive, pairwise swapped and with one straggler
+	seqs := []int64{1, 0, 3, 2, 5, 4, 7, 6, 9, 8}
+	tracker := newSeqTracker(10, 100, 4)
+	for _, s := range seqs {
+		if !tracker.add(s) {
+			t.Errorf("packet %d not counted", s)
+		}
+	}
+	if tracker.unique != 10 || tracker.lost != 0 || tracker.duplicates != 0 {
+		t.Errorf("expected no loss, got unique=%d lost=%d duplicates=%d",
+			tracker.unique, tracker.lost, tracker.duplicates)
+	}
+	if tracker.reordered != 5 {
+		t.Errorf("expected 5 reordered packets, got %d", tracker.reordered)
+	}
+	tracker.finish()
+	var res BwtestResult
+	tracker.setResult(&res)
+	if res.Lost != 0 || res.Reordered != 5 || res.Late != 0 || res.Duplicates != 0 {
+		t.Errorf("expected only reordered packets in result, got %+v", res)
+	}
+}
+
+func TestSeqTrackerResult(t *testing.T) {
+	// packet 0 arrives after the window, 3 arrives twice, 8 and 9 never
+	seqs := []int64{1, 2, 3, 4, 5, 6, 0, 3, 7}
+	tracker := newSeqTracker(10, 100, 4)
+	for _, s := range seqs {
+		tracker.add(s)
+	}
+	tracker.finish()
+	var res BwtestResult
+	tracker.setResult(&res)
+	expected := BwtestResult{Reordered: 1, Late: 1, Duplicates: 1, Lost: 2}
+	if res.Reordered != expected.Reordered || res.Late != expected.Late ||
+		res.Duplicates != expected.Duplicates || res.Lost != expected.Lost {
+		t.Errorf("expected %+v, got %+v", expected, res)
+	}
+}
+
+func TestSeqTrackerWindow(t *testing.T) {
+	tracker := newSeqTracker(100, 100, 4)
+	for s := int64(1); s <= 4; s++ {
+		tracker.add(s)
+	}
+	// packet 0 has been overtaken by 4 packets, still within the window
+	if tracker.lost != 0 {
+		t.Errorf("packet declared lost within reorder window, lost=%d", tracker.lost)
+	}
+	tracker.add(5)
+	if tracker.lost != 1 {
+		t.Errorf("packet not declared lost after reorder window, lost=%d", tracker.lost)
+	}
+	// late arrival is not lost after all
+	tracker.add(0)
+	if tracker.lost != 0 || tracker.reordered != 1 || tracker.unique != 6 {
+		t.Errorf("late packet not accounted correctly: lost=%d reordered=%d unique=%d",
+			tracker.lost, tracker.reordered, tracker.unique)
+	}
+	// jump ahead, everything in between is lost
+	tracker.add(50)
+	// 6..45 are now out of the window
+	if tracker.lost != 40 {
+		t.Errorf("expected %d lost packets, got %d", 40, tracker.lost)
+	}
+}
+
+func TestSeqTrackerDuplicatesAndRange(t *testing.T) {
+	tracker := newSeqTracker(3, 100, 4)
+	for _, s := range []int64{0, 1, 1, 2, 0} {
+		tracker.add(s)
+	}
+	if tracker.unique != 3 || tracker.duplicates != 2 {
+		t.Errorf("duplicates counted wrong: unique=%d duplicates=%d", tracker.unique, tracker.duplicates)
+	}
+	for _, s := range []int64{-1, 3, 1000} {
+		if tracker.add(s) {
+			t.Errorf("out of range sequence number %d accepted", s)
+		}
+	}
+}
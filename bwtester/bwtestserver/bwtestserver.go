@@ -41,6 +41,7 @@ var (
 	resultsMap     map[string]*BwtestResult
 	resultsMapLock sync.Mutex
 	currentBwtest  string // Contains connection parameters, in case server's ack packet was lost
+	limits         BwtestLimits
 )
 
 // Deletes the old entries in resultsMap
@@ -67,9 +68,19 @@ func main() {
 	serverPort := flag.Uint("p", 40002, "Port")
 	id := flag.String("id", "bwtester", "Element ID")
 	logDir := flag.String("log_dir", "./logs", "Log directory")
+	maxDuration := flag.Uint("max_duration", uint(MaxDuration/time.Second),
+		"Maximum duration of each test direction in seconds")
+	maxBandwidth := flag.Int64("max_bw", 0, "Maximum bandwidth of each test direction in bps (0 for no limit)")
+	maxBytes := flag.Int64("max_bytes", 0, "Maximum number of bytes sent per test, in both directions (0 for no limit)")
 
 	flag.Parse()
 
+	limits = BwtestLimits{
+		MaxDuration:  time.Duration(*maxDuration) * time.Second,
+		MaxBandwidth: *maxBandwidth,
+		MaxBytes:     *maxBytes,
+	}
+
 	// Setup logging
 	if _, err := os.Stat(*logDir); os.IsNotExist(err) {
 		err := os.Mkdir(*logDir, 0744)
@@ -164,7 +175,7 @@ func handleClients(CCConn *snet.Conn, receivePacketBuffer []byte, sendPacketBuff
 				resultsMapLock.Unlock()
 
 				// Compute for how much longer the current test is running
-				remTime := t.Sub(v.ExpectedFinishTime)
+				remTime := v.ExpectedFinishTime.Sub(t)
 				sendPacketBuffer[0] = 'N'
 				sendPacketBuffer[1] = byte(remTime/time.Second) + 1
 				_, _ = CCConn.WriteTo(sendPacketBuffer[:2], clientCCAddr)
@@ -190,6 +201,14 @@ func handleClients(CCConn *snet.Conn, receivePacketBuffer []byte, sendPacketBuff
 				// Do not send a response packet for malformed request
 				continue
 			}
+			if code := limits.Check(clientBwp, serverBwp); code != 0 {
+				fmt.Println("Rejected request:", RejectionError(code))
+				sendPacketBuffer[0] = RejectResponse
+				sendPacketBuffer[1] = code
+				_, _ = CCConn.WriteTo(sendPacketBuffer[:2], clientCCAddr)
+				// Ignore error
+				continue
+			}
 
 			// Address of client Data Connection (DC)
 			clientDCAddr := clientCCAddr.Copy()
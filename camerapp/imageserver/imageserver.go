@@ -46,8 +46,15 @@ const (
 
 	// Interval after which the file system is read to check for new images
 	imageReadInterval time.Duration = time.Second * 59
+
+	// Minimum time since the last modification of an image file, before it
+	// is read. Avoids serving images while they are being captured.
+	captureSettleTime time.Duration = time.Second * 2
 )
 
+// imageFileType is an image as served to the clients. It is an immutable
+// snapshot of the file content, taken when the file was read; neither the
+// struct nor the content are modified after it has been added to the store.
 type imageFileType struct {
 	name     string
 	size     uint32
@@ -61,13 +68,90 @@ func check(e error) {
 	}
 }
 
-var (
-	currentFiles     map[string]*imageFileType
-	mostRecentFile   string
-	currentFilesLock sync.Mutex
-)
+// imageStore holds the images available for download. It is safe for
+// concurrent use.
+type imageStore struct {
+	mutex      sync.Mutex
+	files      map[string]*imageFileType
+	mostRecent *imageFileType
+}
+
+func newImageStore() *imageStore {
+	return &imageStore{files: make(map[string]*imageFileType)}
+}
+
+func (s *imageStore) has(name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, ok := s.files[name]
+	return ok
+}
+
+// add adds the image and makes it the most recent image. The image must not
+// be modified afterwards.
+func (s *imageStore) add(img *imageFileType) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.files[img.name] = img
+	s.mostRecent = img
+}
+
+func (s *imageStore) get(name string) (*imageFileType, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	img, ok := s.files[name]
+	return img, ok
+}
+
+// latest returns the most recent image, or nil if there is none.
+func (s *imageStore) latest() *imageFileType {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.mostRecent
+}
+
+// removeOlderThan removes the images read before t from the store and returns
+// their names.
+func (s *imageStore) removeOlderThan(t time.Time) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var removed []string
+	for k, v := range s.files {
+		if v.readTime.Before(t) {
+			delete(s.files, k)
+			removed = append(removed, k)
+			if v == s.mostRecent {
+				s.mostRecent = nil
+			}
+		}
+	}
+	return removed
+}
+
+// readImageFile reads the image file into a new snapshot. If the file is
+// still being written, e.g. by the camera capturing a new image, it returns
+// nil and the file should be read again later.
+func readImageFile(dir string, entry os.FileInfo) (*imageFileType, error) {
+	filename := path.Join(dir, entry.Name())
+	fileContents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	// The file is considered complete if it has not changed since it was
+	// listed and while it was read.
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if stat.Size() != entry.Size() || !stat.ModTime().Equal(entry.ModTime()) ||
+		int64(len(fileContents)) != stat.Size() ||
+		time.Since(stat.ModTime()) < captureSettleTime {
+		return nil, nil
+	}
+	return &imageFileType{entry.Name(), uint32(len(fileContents)), fileContents, time.Now()}, nil
+}
 
-func handleImageFiles(dir string, keepFiles bool) {
+func handleImageFiles(store *imageStore, dir string, keepFiles bool) {
 	for {
 		// Read the directory and look for new .jpg images
 		direntries, err := ioutil.ReadDir(dir)
@@ -84,40 +168,72 @@ func handleImageFiles(dir string, keepFiles bool) {
 				continue
 			}
 			// Check if we've already read in the image
-			currentFilesLock.Lock()
-			if _, ok := currentFiles[entry.Name()]; !ok {
-				fileContents, err := ioutil.ReadFile(path.Join(dir, entry.Name()))
-				check(err)
-				newFile := imageFileType{entry.Name(), uint32(entry.Size()), fileContents, time.Now()}
-				currentFiles[newFile.name] = &newFile
-				mostRecentFile = newFile.name
+			if store.has(entry.Name()) {
+				continue
+			}
+			newFile, err := readImageFile(dir, entry)
+			check(err)
+			if newFile != nil {
+				store.add(newFile)
 			}
-			currentFilesLock.Unlock()
 		}
 		if !keepFiles {
 			// Check if an image should be deleted
-			now := time.Now()
-			currentFilesLock.Lock()
-			for k, v := range currentFiles {
-				if now.Sub(v.readTime) > MaxFileAge+MaxFileAgeGracePeriod {
-					err = os.Remove(path.Join(dir, k))
-					check(err)
-					delete(currentFiles, k)
-					if k == mostRecentFile {
-						mostRecentFile = ""
-					}
-				}
+			for _, k := range store.removeOlderThan(time.Now().Add(-MaxFileAge - MaxFileAgeGracePeriod)) {
+				err = os.Remove(path.Join(dir, k))
+				check(err)
 			}
-			currentFilesLock.Unlock()
 		}
 
 		time.Sleep(imageReadInterval)
 	}
 }
 
-func main() {
-	currentFiles = make(map[string]*imageFileType)
+// handleRequest handles a request packet and writes the response into
+// sendPacketBuffer. Returns the length of the response, or 0 if no response
+// should be sent.
+// The response is built from the immutable image snapshot, so concurrent
+// requests and newly read images do not interfere.
+func handleRequest(store *imageStore, request []byte, sendPacketBuffer []byte) int {
+	n := len(request)
+	if n == 0 {
+		return 0
+	}
+	if request[0] == 'L' {
+		img := store.latest()
+		if img == nil {
+			return 0
+		}
+		sendLen := len(img.name)
+		sendPacketBuffer[0] = 'L'
+		sendPacketBuffer[1] = byte(sendLen)
+		copy(sendPacketBuffer[2:], []byte(img.name))
+		sendLen = sendLen + 2
+		binary.LittleEndian.PutUint32(sendPacketBuffer[sendLen:], img.size)
+		return sendLen + 4
+	} else if request[0] == 'G' && n > 1 {
+		filenameLen := int(request[1])
+		if n >= (2 + filenameLen + 8) {
+			v, ok := store.get(string(request[2 : filenameLen+2]))
+			if !ok {
+				return 0
+			}
+			startByte := binary.LittleEndian.Uint32(request[filenameLen+2:])
+			endByte := binary.LittleEndian.Uint32(request[filenameLen+6:])
+			if endByte > startByte && endByte <= v.size && int(9+endByte-startByte) <= len(sendPacketBuffer) {
+				sendPacketBuffer[0] = 'G'
+				// Copy startByte and endByte from request packet
+				copy(sendPacketBuffer[1:], request[filenameLen+2:filenameLen+10])
+				// Copy image contents
+				copy(sendPacketBuffer[9:], v.content[startByte:endByte])
+				return int(9 + endByte - startByte)
+			}
+		}
+	}
+	return 0
+}
 
+func main() {
 	// Fetch arguments from command line
 	port := flag.Uint("p", 40002, "Server Port")
 	dir := flag.String("d", ".", "Directory to serve images from")
@@ -127,7 +243,8 @@ func main() {
 	udpConnection, err := appnet.ListenPort(uint16(*port))
 	check(err)
 
-	go handleImageFiles(*dir, *keepFiles)
+	store := newImageStore()
+	go handleImageFiles(store, *dir, *keepFiles)
 
 	receivePacketBuffer := make([]byte, 2500)
 	sendPacketBuffer := make([]byte, 2500)
@@ -146,50 +263,10 @@ func main() {
 			// If it's not an snet SCMP error, then it's something more serious and fail
 			// check(err)
 		}
-		if n > 0 {
-			if receivePacketBuffer[0] == 'L' {
-				// We also need to lock access to mostRecentFile, otherwise a race condition is possible
-				// where the file is deleted after the initial check
-				currentFilesLock.Lock()
-				sendLen := len(mostRecentFile)
-				if sendLen == 0 {
-					currentFilesLock.Unlock()
-					continue
-				}
-				sendPacketBuffer[0] = 'L'
-				sendPacketBuffer[1] = byte(sendLen)
-				copy(sendPacketBuffer[2:], []byte(mostRecentFile))
-				sendLen = sendLen + 2
-				binary.LittleEndian.PutUint32(sendPacketBuffer[sendLen:], currentFiles[mostRecentFile].size)
-				currentFilesLock.Unlock()
-				sendLen = sendLen + 4
-				_, err = udpConnection.WriteTo(sendPacketBuffer[:sendLen], remoteUDPaddress)
-				check(err)
-			} else if receivePacketBuffer[0] == 'G' && n > 1 {
-				filenameLen := int(receivePacketBuffer[1])
-				if n >= (2 + filenameLen + 8) {
-					currentFilesLock.Lock()
-					v, ok := currentFiles[string(receivePacketBuffer[2:filenameLen+2])]
-					// We don't need to lock any more, since we now have a pointer to the image structure
-					// which does not get changed once set up.
-					currentFilesLock.Unlock()
-					if !ok {
-						continue
-					}
-					startByte := binary.LittleEndian.Uint32(receivePacketBuffer[filenameLen+2:])
-					endByte := binary.LittleEndian.Uint32(receivePacketBuffer[filenameLen+6:])
-					if endByte > startByte && endByte <= v.size+1 {
-						sendPacketBuffer[0] = 'G'
-						// Copy startByte and endByte from request packet
-						copy(sendPacketBuffer[1:], receivePacketBuffer[filenameLen+2:filenameLen+10])
-						// Copy image contents
-						copy(sendPacketBuffer[9:], v.content[startByte:endByte])
-						sendLen := 9 + endByte - startByte
-						_, err = udpConnection.WriteTo(sendPacketBuffer[:sendLen], remoteUDPaddress)
-						check(err)
-					}
-				}
-			}
+		sendLen := handleRequest(store, receivePacketBuffer[:n], sendPacketBuffer)
+		if sendLen > 0 {
+			_, err = udpConnection.WriteTo(sendPacketBuffer[:sendLen], remoteUDPaddress)
+			check(err)
 		}
 	}
 }
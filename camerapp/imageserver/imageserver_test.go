@@ -0,0 +1,180 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testBlockSize = 1000
+
+// TestConcurrentFetch fetches the most recent image from many clients
+// concurrently, while new images are captured. Each fetched image must be
+// complete and identical to one of the captured images.
+func TestConcurrentFetch(t *testing.T) {
+	store := newImageStore()
+	var checksums sync.Map // image name -> sha256 of content
+
+	capture := func(i int) {
+		content := make([]byte, 5000+rand.Intn(5000))
+		rand.Read(content)
+		name := fmt.Sprintf("image-%d.jpg", i)
+		checksums.Store(name, sha256.Sum256(content))
+		store.add(&imageFileType{name, uint32(len(content)), content, time.Now()})
+	}
+	capture(0)
+
+	done := make(chan struct{})
+	var captures sync.WaitGroup
+	captures.Add(1)
+	go func() {
+		defer captures.Done()
+		for i := 1; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+				capture(i)
+			}
+		}
+	}()
+
+	var fetches sync.WaitGroup
+	for c := 0; c < 20; c++ {
+		fetches.Add(1)
+		go func() {
+			defer fetches.Done()
+			for f := 0; f < 20; f++ {
+				name, content, err := fetchImage(store)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				expected, ok := checksums.Load(name)
+				if !ok {
+					t.Errorf("fetched unknown image %s", name)
+					return
+				}
+				if sha256.Sum256(content) != expected.([sha256.Size]byte) {
+					t.Errorf("fetched image %s is corrupt", name)
+					return
+				}
+			}
+		}()
+	}
+	fetches.Wait()
+	close(done)
+	captures.Wait()
+}
+
+// fetchImage fetches the most recent image like the imagefetcher, in blocks.
+func fetchImage(store *imageStore) (string, []byte, error) {
+	response := make([]byte, 2500)
+	n := handleRequest(store, []byte{'L'}, response)
+	if n < 2 || response[0] != 'L' || n != 2+int(response[1])+4 {
+		return "", nil, fmt.Errorf("invalid list response")
+	}
+	name := string(response[2 : 2+response[1]])
+	size := binary.LittleEndian.Uint32(response[n-4:])
+
+	content := make([]byte, size)
+	request := append([]byte{'G', byte(len(name))}, name...)
+	request = append(request, make([]byte, 8)...)
+	for start := uint32(0); start < size; start += testBlockSize {
+		end := start + testBlockSize
+		if end > size {
+			end = size
+		}
+		binary.LittleEndian.PutUint32(request[len(request)-8:], start)
+		binary.LittleEndian.PutUint32(request[len(request)-4:], end)
+		n := handleRequest(store, request, response)
+		if n != int(9+end-start) || response[0] != 'G' {
+			return "", nil, fmt.Errorf("invalid response for block %d-%d of %s", start, end, name)
+		}
+		copy(content[start:], response[9:n])
+	}
+	return name, content, nil
+}
+
+func TestHandleRequestOutOfRange(t *testing.T) {
+	store := newImageStore()
+	// the capacity of the content is larger than the image; nothing beyond
+	// the image may be served
+	content := make([]byte, 10, 100)
+	store.add(&imageFileType{"a.jpg", uint32(len(content)), content, time.Now()})
+
+	request := []byte{'G', 5, 'a', '.', 'j', 'p', 'g', 0, 0, 0, 0, 0, 0, 0, 0}
+	response := make([]byte, 2500)
+	for _, r := range [][2]uint32{{0, 11}, {5, 5}, {6, 5}} {
+		binary.LittleEndian.PutUint32(request[7:], r[0])
+		binary.LittleEndian.PutUint32(request[11:], r[1])
+		if n := handleRequest(store, request, response); n != 0 {
+			t.Errorf("expected no response for range %d-%d, got %d bytes", r[0], r[1], n)
+		}
+	}
+}
+
+func TestReadImageFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imageserver-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := path.Join(dir, "capture.jpg")
+	if err := ioutil.WriteFile(filename, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the file has just been written, it may still be incomplete
+	img, err := readImageFile(dir, entry)
+	if err != nil || img != nil {
+		t.Fatalf("expected recently written file to be skipped, got %v, %v", img, err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(filename, past, past); err != nil {
+		t.Fatal(err)
+	}
+	// the file was modified since it was listed
+	img, err = readImageFile(dir, entry)
+	if err != nil || img != nil {
+		t.Fatalf("expected modified file to be skipped, got %v, %v", img, err)
+	}
+
+	entry, err = os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = readImageFile(dir, entry)
+	if err != nil || img == nil {
+		t.Fatalf("expected file to be read, got %v, %v", img, err)
+	}
+	if img.name != "capture.jpg" || img.size != 7 || string(img.content) != "partial" {
+		t.Errorf("unexpected image %v", img)
+	}
+}
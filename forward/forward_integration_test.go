@@ -0,0 +1,91 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build integration
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/netsec-ethz/scion-apps/pkg/integration"
+)
+
+const (
+	name       = "forward"
+	forwardBin = "scion-forward"
+	serverBin  = "scion-netcat"
+)
+
+func TestIntegrationScionForward(t *testing.T) {
+	if err := integration.Init(name); err != nil {
+		t.Fatalf("Failed to init: %s\n", err)
+	}
+
+	// Server: echo server, scion-netcat relaying each connection to cat
+	serverPort := "1235"
+	serverArgs := []string{"-l", "-K", "-c", "cat", serverPort}
+	serverCmd := integration.AppBinPath(serverBin)
+
+	// Client: runs scion-forward in the background and sends a message to the
+	// forwarded local TCP port, printing the echoed reply
+	testMessage := "Hello forwarded World!"
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	clientCmd, err := forwardClientCommand(tmpDir, testMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientArgs := []string{integration.DstAddrPattern + ":" + serverPort}
+
+	in := integration.NewAppsIntegration(name, "forward_echo", clientCmd, serverCmd, clientArgs, serverArgs, true)
+	in.ClientStdout(integration.RegExp(fmt.Sprintf("^%s$", testMessage)))
+	in.ClientStderr(integration.NoPanic())
+
+	IAPairs := integration.IAPairs(integration.HostAddr)
+	IAPairs = IAPairs[:3]
+
+	if err := integration.RunTests(in, IAPairs, integration.DefaultClientTimeout, 250*time.Millisecond); err != nil {
+		t.Fatalf("Error during tests err: %v", err)
+	}
+}
+
+// forwardClientCommand creates a script that starts scion-forward, forwarding a
+// local port to the remote address given as first argument, and then sends
+// the message through the forwarded port, using bash's /dev/tcp.
+func forwardClientCommand(tmpDir string, message string) (string, error) {
+	localPort := 40123
+	script := fmt.Sprintf(`#!/bin/bash
+%s -L %d:"$1" &
+pid=$!
+trap "kill $pid" EXIT
+sleep 1
+exec 3<>/dev/tcp/localhost/%d
+echo '%s' >&3
+timeout 5 head -n 1 <&3
+`, integration.AppBinPath(forwardBin), localPort, localPort, message)
+	cmd := path.Join(tmpDir, "scion-forward_wrapper.sh")
+	if err := ioutil.WriteFile(cmd, []byte(script), 0777); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", cmd, err)
+	}
+	return cmd, nil
+}
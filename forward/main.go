@@ -0,0 +1,99 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
+)
+
+func main() {
+	forwards := kingpin.Flag("local-forward", "Forward connections to the local TCP port to the remote "+
+		"SCION address. Format: localport:remote_address:port. Can be repeated.").
+		Short('L').Required().Strings()
+	bindHost := kingpin.Flag("bind", "Local address to bind on").Default("localhost").String()
+	pathAlgo := kingpin.Flag("path-algo", "Path selection algorithm / metric").Default("").Enum("", "shortest", "mtu")
+	nextProto := kingpin.Flag("alpn", "Application protocol negotiated with the remote QUIC server. "+
+		"The default is compatible with scion-netcat").Default("netcat").String()
+	kingpin.Parse()
+
+	d := &appquic.StreamDialer{
+		PathAlgo: appnet.PathAlgoMetric(*pathAlgo),
+		TLSConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			NextProtos:         []string{*nextProto},
+		},
+	}
+
+	errs := make(chan error)
+	for _, f := range *forwards {
+		localPort, remote, err := parseForward(f)
+		if err != nil {
+			kingpin.Fatalf("invalid forward %q: %s", f, err)
+		}
+		listener, err := net.Listen("tcp", net.JoinHostPort(*bindHost, strconv.Itoa(int(localPort))))
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Forwarding %s to %s", listener.Addr(), remote)
+		go func() {
+			errs <- serve(listener, remote, d)
+		}()
+	}
+	log.Fatal(<-errs)
+}
+
+// parseForward parses a forwarding specification of the form
+// localport:remote_address:port
+func parseForward(s string) (uint16, string, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return 0, "", fmt.Errorf("expected localport:remote_address:port")
+	}
+	port, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid local port: %w", err)
+	}
+	return uint16(port), parts[1], nil
+}
+
+// serve accepts connections on listener and forwards each of them over a
+// new QUIC stream to remote.
+func serve(listener net.Listener, remote string, d *appquic.StreamDialer) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			stream, err := d.Dial(remote)
+			if err != nil {
+				log.Printf("Failed to connect to %s: %s", remote, err)
+				conn.Close()
+				return
+			}
+			appquic.Pipe(conn, stream)
+		}()
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+// nextProto is the application protocol of the load generator and its echo
+// server.
+const nextProto = "loadgen"
+
+// runLoad issues the given number of requests with the given number of
+// concurrent workers and returns the results of all requests. The requests
+// are made by calling request with the index of the worker.
+func runLoad(workers, requests int, request func(worker int) error) ([]result, time.Duration) {
+	jobs := make(chan struct{}, requests)
+	for i := 0; i < requests; i++ {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+
+	results := make([]result, 0, requests)
+	var resultsMutex sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for range jobs {
+				t := time.Now()
+				err := request(worker)
+				r := result{latency: time.Since(t), err: err}
+				resultsMutex.Lock()
+				results = append(results, r)
+				resultsMutex.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+	return results, time.Since(start)
+}
+
+// doRequest opens a new stream on the session, sends the payload, and reads
+// the response until the server closes the stream. The response must be the
+// echoed payload.
+func doRequest(ctx context.Context, session quic.Session, payload []byte) error {
+	stream, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetDeadline(deadline)
+	}
+	if _, err := stream.Write(payload); err != nil {
+		stream.CancelRead(0)
+		return err
+	}
+	if err := stream.Close(); err != nil {
+		return err
+	}
+	response, err := ioutil.ReadAll(stream)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(response, payload) {
+		return fmt.Errorf("unexpected response of %d bytes", len(response))
+	}
+	return nil
+}
+
+// serveEcho accepts sessions and echoes the data of each stream back on the
+// same stream.
+func serveEcho(listener quic.Listener) error {
+	for {
+		session, err := listener.Accept(context.Background())
+		if err != nil {
+			return err
+		}
+		go func() {
+			for {
+				stream, err := session.AcceptStream(context.Background())
+				if err != nil {
+					return
+				}
+				go func() {
+					if _, err := io.Copy(stream, stream); err != nil {
+						stream.CancelRead(0)
+						stream.CancelWrite(0)
+						return
+					}
+					stream.Close()
+				}()
+			}
+		}()
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
+)
+
+// TestLoadEcho runs a fixed number of requests against the echo server. As
+// there is no SCION network in unit tests, the QUIC sessions use plain UDP on
+// loopback.
+func TestLoadEcho(t *testing.T) {
+	listener, err := quic.ListenAddr("127.0.0.1:0",
+		&tls.Config{Certificates: appquic.GetDummyTLSCerts(), NextProtos: []string{nextProto}},
+		&quic.Config{MaxIncomingStreams: maxStreams})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		_ = serveEcho(listener)
+	}()
+
+	var sessions []quic.Session
+	for i := 0; i < 2; i++ {
+		session, err := quic.DialAddr(listener.Addr().String(),
+			&tls.Config{InsecureSkipVerify: true, NextProtos: []string{nextProto}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer session.CloseWithError(0, "")
+		sessions = append(sessions, session)
+	}
+
+	const requests = 200
+	payload := []byte("ping")
+	results, total := runLoad(8, requests, func(worker int) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return doRequest(ctx, sessions[worker%len(sessions)], payload)
+	})
+
+	s := summarize(results, total)
+	if s.Requests != requests || s.Errors != 0 {
+		t.Fatalf("expected %d successful requests, got %+v", requests, s)
+	}
+	if s.RequestsPerSecond <= 0 || s.Latency.P50 <= 0 || s.Latency.P50 > s.Latency.P99 {
+		t.Errorf("implausible summary %+v", s)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/lucas-clemente/quic-go"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+// maxStreams is the number of concurrent streams per session accepted by the
+// echo server.
+const maxStreams = 1000
+
+func main() {
+	listenPort := kingpin.Flag("listen", "Run the echo server on this port, instead of the load generator").
+		Short('l').Uint16()
+	remote := kingpin.Arg("remote", "Address of the echo server, ISD-AS,[IP]:port or hostname:port").String()
+	connections := kingpin.Flag("connections", "Number of QUIC connections").Default("1").Short('c').Int()
+	concurrency := kingpin.Flag("concurrency", "Number of concurrent requests, distributed over the connections").
+		Default("10").Short('n').Int()
+	requests := kingpin.Flag("requests", "Total number of requests").Default("1000").Short('r').Int()
+	size := kingpin.Flag("size", "Request size in bytes").Default("64").Short('s').Int()
+	timeout := kingpin.Flag("timeout", "Timeout for each request").Default("5s").Duration()
+	pathAlgo := kingpin.Flag("path-algo", "Path selection algorithm / metric").Default("").Enum("", "shortest", "mtu")
+	interactive := kingpin.Flag("interactive", "Prompt user for interactive path selection").Short('i').Bool()
+	jsonOutput := kingpin.Flag("json", "Print the summary as JSON").Bool()
+	kingpin.Parse()
+
+	if *listenPort != 0 {
+		log.Fatal(runServer(*listenPort))
+	}
+	if *remote == "" {
+		kingpin.Fatalf("required argument 'remote' not provided")
+	}
+	if *connections < 1 || *concurrency < 1 || *requests < 0 || *size < 0 {
+		kingpin.Fatalf("invalid arguments")
+	}
+
+	sessions, err := dialSessions(*remote, *connections, *pathAlgo, *interactive)
+	if err != nil {
+		log.Fatal(err)
+	}
+	payload := make([]byte, *size)
+	results, total := runLoad(*concurrency, *requests, func(worker int) error {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		return doRequest(ctx, sessions[worker%len(sessions)], payload)
+	})
+	for _, s := range sessions {
+		_ = s.CloseWithError(0, "")
+	}
+
+	s := summarize(results, total)
+	if *jsonOutput {
+		_ = json.NewEncoder(os.Stdout).Encode(s)
+	} else {
+		s.print(os.Stdout)
+	}
+}
+
+func runServer(port uint16) error {
+	tlsConf := &tls.Config{
+		Certificates: appquic.GetDummyTLSCerts(),
+		NextProtos:   []string{nextProto},
+	}
+	listener, err := appquic.ListenPort(port, tlsConf, &quic.Config{MaxIncomingStreams: maxStreams})
+	if err != nil {
+		return err
+	}
+	return serveEcho(listener)
+}
+
+// dialSessions opens the given number of QUIC sessions to the remote, all on
+// the same path.
+func dialSessions(remote string, n int, pathAlgo string, interactive bool) ([]quic.Session, error) {
+	raddr, err := appnet.ResolveUDPAddr(remote)
+	if err != nil {
+		return nil, err
+	}
+	var path snet.Path
+	if interactive {
+		path, err = appnet.ChoosePathInteractive(raddr.IA)
+	} else {
+		path, err = appnet.ChoosePathByMetric(appnet.PathAlgoMetric(pathAlgo), raddr.IA)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if path != nil {
+		appnet.SetPath(raddr, path)
+		fmt.Fprintf(os.Stderr, "Using path: %s\n", path)
+	}
+
+	tlsConf := &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{nextProto},
+	}
+	sessions := make([]quic.Session, 0, n)
+	for i := 0; i < n; i++ {
+		session, err := appquic.DialAddr(raddr, remote, tlsConf, &quic.Config{KeepAlive: true})
+		if err != nil {
+			for _, s := range sessions {
+				_ = s.CloseWithError(0, "")
+			}
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
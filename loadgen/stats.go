@@ -0,0 +1,127 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// result is the outcome of a single request.
+type result struct {
+	latency time.Duration
+	err     error
+}
+
+// summary summarizes the results of a load test.
+type summary struct {
+	Requests          int            `json:"requests"`
+	Errors            int            `json:"errors"`
+	ErrorRate         float64        `json:"error_rate"`
+	Duration          float64        `json:"duration_s"`
+	RequestsPerSecond float64        `json:"requests_per_second"`
+	Latency           latencySummary `json:"latency_ms"`
+	// Number of occurrences of each error message
+	ErrorDist map[string]int `json:"error_dist,omitempty"`
+}
+
+// latencySummary contains the latency statistics of the successful requests,
+// in milliseconds.
+type latencySummary struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+func summarize(results []result, total time.Duration) summary {
+	s := summary{
+		Requests:  len(results),
+		Duration:  total.Seconds(),
+		ErrorDist: make(map[string]int),
+	}
+	var latencies []time.Duration
+	for _, r := range results {
+		if r.err != nil {
+			s.Errors++
+			s.ErrorDist[r.err.Error()]++
+		} else {
+			latencies = append(latencies, r.latency)
+		}
+	}
+	if s.Requests > 0 {
+		s.ErrorRate = float64(s.Errors) / float64(s.Requests)
+	}
+	if total > 0 {
+		s.RequestsPerSecond = float64(len(latencies)) / total.Seconds()
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	if len(latencies) > 0 {
+		var sum time.Duration
+		for _, l := range latencies {
+			sum += l
+		}
+		s.Latency = latencySummary{
+			Min:  milliseconds(latencies[0]),
+			Mean: milliseconds(sum / time.Duration(len(latencies))),
+			P50:  milliseconds(percentile(latencies, 50)),
+			P90:  milliseconds(percentile(latencies, 90)),
+			P99:  milliseconds(percentile(latencies, 99)),
+			Max:  milliseconds(latencies[len(latencies)-1]),
+		}
+	}
+	return s
+}
+
+// percentile returns the p-th percentile (0 < p <= 100) of the sorted
+// values, using the nearest-rank method, or 0 if there are no values.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	} else if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (s summary) print(w io.Writer) {
+	fmt.Fprintf(w, "Requests:      %d\n", s.Requests)
+	fmt.Fprintf(w, "Errors:        %d (%.2f%%)\n", s.Errors, 100*s.ErrorRate)
+	fmt.Fprintf(w, "Duration:      %.3fs\n", s.Duration)
+	fmt.Fprintf(w, "Requests/sec:  %.2f\n", s.RequestsPerSecond)
+	fmt.Fprintf(w, "Latency:       min %.3fms, mean %.3fms, max %.3fms\n",
+		s.Latency.Min, s.Latency.Mean, s.Latency.Max)
+	fmt.Fprintf(w, "Percentiles:   p50 %.3fms, p90 %.3fms, p99 %.3fms\n",
+		s.Latency.P50, s.Latency.P90, s.Latency.P99)
+	if len(s.ErrorDist) > 0 {
+		fmt.Fprintln(w, "Error distribution:")
+		for msg, n := range s.ErrorDist {
+			fmt.Fprintf(w, "  [%d] %s\n", n, msg)
+		}
+	}
+}
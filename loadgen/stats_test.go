@@ -0,0 +1,69 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	values := make([]time.Duration, 100)
+	for i := range values {
+		values[i] = time.Duration(i + 1)
+	}
+	cases := []struct {
+		values   []time.Duration
+		p        float64
+		expected time.Duration
+	}{
+		{values, 50, 50},
+		{values, 90, 90},
+		{values, 99, 99},
+		{values, 100, 100},
+		{values, 0.1, 1},
+		{[]time.Duration{1, 2, 3}, 50, 2},
+		{[]time.Duration{1, 2, 3, 4}, 50, 2},
+		{[]time.Duration{1, 2, 3, 4}, 99, 4},
+		{[]time.Duration{7}, 50, 7},
+		{nil, 50, 0},
+	}
+	for _, c := range cases {
+		if actual := percentile(c.values, c.p); actual != c.expected {
+			t.Errorf("p%v of %d values: expected %v, got %v", c.p, len(c.values), c.expected, actual)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var results []result
+	for i := 1; i <= 10; i++ {
+		results = append(results, result{latency: time.Duration(i) * time.Millisecond})
+	}
+	results = append(results, result{err: errors.New("boom")}, result{err: errors.New("boom")})
+
+	s := summarize(results, 2*time.Second)
+	if s.Requests != 12 || s.Errors != 2 || s.ErrorDist["boom"] != 2 {
+		t.Errorf("unexpected counts %+v", s)
+	}
+	if s.RequestsPerSecond != 5 {
+		t.Errorf("expected 5 requests/sec, got %v", s.RequestsPerSecond)
+	}
+	expected := latencySummary{Min: 1, Mean: 5.5, P50: 5, P90: 9, P99: 10, Max: 10}
+	if s.Latency != expected {
+		t.Errorf("expected latency %+v, got %+v", expected, s.Latency)
+	}
+}
@@ -22,7 +22,9 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/netsec-ethz/scion-apps/netcat/modes"
 	scionlog "github.com/scionproto/scion/go/lib/log"
@@ -43,11 +45,15 @@ var (
 
 	verboseMode     bool
 	veryVerboseMode bool
+
+	probeMode    bool
+	probeTimeout int
 )
 
 func printUsage() {
 	fmt.Println("netcat [flags] host-address:port")
 	fmt.Println("netcat [flags] -l port")
+	fmt.Println("netcat [flags] -z host-address:port[-port]")
 	fmt.Println("")
 	fmt.Println("The host address is specified as ISD-AS,[IP Address]")
 	fmt.Println("Example SCION address: 17-ffaa:1:bfd,[127.0.0.1]")
@@ -61,6 +67,8 @@ func printUsage() {
 	fmt.Println("  -c: Instead of piping the connection to stdin/stdout, run the given command using /bin/sh")
 	fmt.Println("  -u: UDP mode")
 	fmt.Println("  -b: Send or expect an extra (throw-away) byte before the actual data")
+	fmt.Println("  -z: Only check whether the remote is reachable, without sending data, and report the path used. A range of ports can be given. Exits with 0 if any port is reachable")
+	fmt.Println("  -w: Timeout in seconds for -z (default 5). In UDP mode, the time to wait for a response")
 	fmt.Println("  -v: Enable verbose mode")
 	fmt.Println("  -vv: Enable very verbose mode")
 }
@@ -76,6 +84,8 @@ func main() {
 	flag.StringVar(&commandString, "c", "", "Command")
 	flag.BoolVar(&verboseMode, "v", false, "Verbose mode")
 	flag.BoolVar(&veryVerboseMode, "vv", false, "Very verbose mode")
+	flag.BoolVar(&probeMode, "z", false, "Probe mode")
+	flag.IntVar(&probeTimeout, "w", 5, "Timeout for probe mode, in seconds")
 	flag.Parse()
 
 	if veryVerboseMode {
@@ -110,6 +120,13 @@ func main() {
 	if repeatDuring && commandString == "" {
 		golog.Panicf("-K flag requires -c flag!")
 	}
+	if probeMode && listen {
+		golog.Panicf("-z and -l flags are exclusive!")
+	}
+
+	if probeMode {
+		os.Exit(doProbe(tail[0], time.Duration(probeTimeout)*time.Second))
+	}
 
 	log.Info("Launching netcat")
 
@@ -234,6 +251,67 @@ func pipeConn(conn io.ReadWriteCloser) {
 	log.Info("Connection closed", "conn", conn)
 }
 
+// doProbe probes each port of the target and reports the result. Returns the
+// exit code, 0 if any port could be reached.
+func doProbe(target string, timeout time.Duration) int {
+	host, ports, err := parsePortRange(target)
+	if err != nil {
+		printUsage()
+		golog.Panicf("Invalid address %s: %v", target, err)
+	}
+	exitCode := 1
+	for _, port := range ports {
+		remoteAddr := fmt.Sprintf("%s:%d", host, port)
+		var result modes.ProbeResult
+		if udpMode {
+			result, err = modes.ProbeUDP(remoteAddr, timeout)
+		} else {
+			result, err = modes.ProbeQUIC(remoteAddr, timeout)
+		}
+		if err != nil {
+			fmt.Printf("Connection to %s failed: %v\n", remoteAddr, err)
+			continue
+		}
+		exitCode = 0
+		if result.NoResponse {
+			fmt.Printf("Probe sent to %s (no response), path: %s\n", remoteAddr, result.Path)
+		} else {
+			fmt.Printf("Connection to %s succeeded, path: %s\n", remoteAddr, result.Path)
+		}
+	}
+	return exitCode
+}
+
+// parsePortRange splits an address of the form host:port or host:port-port
+// into the host and the list of ports.
+func parsePortRange(address string) (string, []uint16, error) {
+	i := strings.LastIndex(address, ":")
+	if i < 0 {
+		return "", nil, fmt.Errorf("missing port")
+	}
+	host, portRange := address[:i], address[i+1:]
+	first, last := portRange, portRange
+	if j := strings.Index(portRange, "-"); j >= 0 {
+		first, last = portRange[:j], portRange[j+1:]
+	}
+	from, err := strconv.ParseUint(first, 10, 16)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid port %q", first)
+	}
+	to, err := strconv.ParseUint(last, 10, 16)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid port %q", last)
+	}
+	if from > to {
+		return "", nil, fmt.Errorf("invalid port range %s", portRange)
+	}
+	ports := make([]uint16, 0, to-from+1)
+	for p := from; p <= to; p++ {
+		ports = append(ports, uint16(p))
+	}
+	return host, ports, nil
+}
+
 func doDial(remoteAddr string) io.ReadWriteCloser {
 	var conn io.ReadWriteCloser
 	if udpMode {
@@ -0,0 +1,47 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortRange(t *testing.T) {
+	cases := []struct {
+		address string
+		host    string
+		ports   []uint16
+		valid   bool
+	}{
+		{"1-ff00:0:1,[127.0.0.1]:80", "1-ff00:0:1,[127.0.0.1]", []uint16{80}, true},
+		{"1-ff00:0:1,[::1]:80-82", "1-ff00:0:1,[::1]", []uint16{80, 81, 82}, true},
+		{"server:65535", "server", []uint16{65535}, true},
+		{"server:82-80", "", nil, false},
+		{"server:65536", "", nil, false},
+		{"server:80-", "", nil, false},
+		{"server", "", nil, false},
+	}
+	for _, c := range cases {
+		host, ports, err := parsePortRange(c.address)
+		if (err == nil) != c.valid {
+			t.Errorf("%s: expected valid: %v, got error %v", c.address, c.valid, err)
+			continue
+		}
+		if host != c.host || !reflect.DeepEqual(ports, c.ports) {
+			t.Errorf("%s: expected %s %v, got %s %v", c.address, c.host, c.ports, host, ports)
+		}
+	}
+}
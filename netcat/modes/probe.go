@@ -0,0 +1,107 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modes
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/scionproto/scion/go/lib/snet"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
+)
+
+// ProbeResult is the outcome of probing a remote address.
+type ProbeResult struct {
+	// Path is a description of the path used for the probe.
+	Path string
+	// NoResponse is set for a successful UDP probe to which the remote did
+	// not respond; as for UDP in netcat, this does not mean that the remote is
+	// not listening.
+	NoResponse bool
+}
+
+// ProbeQUIC checks whether a netcat QUIC server is listening on the remote
+// address, by establishing a QUIC session with it, without sending any data.
+func ProbeQUIC(remoteAddr string, timeout time.Duration) (ProbeResult, error) {
+	raddr, result, err := resolveProbeAddr(remoteAddr)
+	if err != nil {
+		return result, err
+	}
+	sess, err := appquic.DialAddr(
+		raddr,
+		remoteAddr,
+		&tls.Config{
+			InsecureSkipVerify: true,
+			NextProtos:         []string{nextProto},
+		},
+		&quic.Config{HandshakeTimeout: timeout},
+	)
+	if err != nil {
+		return result, err
+	}
+	return result, sess.CloseWithError(quic.ErrorCode(0), "")
+}
+
+// ProbeUDP sends an empty datagram to the remote address and waits for a
+// response, at most for the timeout. A missing response is not an error.
+func ProbeUDP(remoteAddr string, timeout time.Duration) (ProbeResult, error) {
+	raddr, result, err := resolveProbeAddr(remoteAddr)
+	if err != nil {
+		return result, err
+	}
+	conn, err := appnet.DialAddr(raddr)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte{}); err != nil {
+		return result, err
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return result, err
+	}
+	buf := make([]byte, 1)
+	_, err = conn.Read(buf)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		result.NoResponse = true
+		return result, nil
+	}
+	return result, err
+}
+
+// resolveProbeAddr resolves the remote address and sets the path to the
+// first available path, which is what appnet and appquic use by default.
+func resolveProbeAddr(remoteAddr string) (*snet.UDPAddr, ProbeResult, error) {
+	raddr, err := appnet.ResolveUDPAddr(remoteAddr)
+	if err != nil {
+		return nil, ProbeResult{}, err
+	}
+	paths, err := appnet.QueryPaths(raddr.IA)
+	if err != nil {
+		return nil, ProbeResult{}, err
+	}
+	if len(paths) == 0 {
+		return raddr, ProbeResult{Path: "(local AS)"}, nil
+	}
+	appnet.SetPath(raddr, paths[0])
+	return raddr, ProbeResult{Path: fmt.Sprintf("%s", paths[0])}, nil
+}
@@ -166,6 +166,89 @@ func TestIntegrationScionNetcatUDP(t *testing.T) {
 	}
 }
 
+func TestIntegrationScionNetcatProbe(t *testing.T) {
+	// Probe mode
+	// Common arguments
+	cmnArgs := []string{"-z", "-w", "2"}
+
+	// Server
+	serverPort := "1234"
+	closedPort := "1235"
+	serverArgs := []string{"-l", serverPort}
+
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	// the probe of a closed port exits with non-zero status, which would fail
+	// the test; report the exit status on stdout instead.
+	clientCmd, err := exitStatusWrapperCommand(tmpDir, integration.AppBinPath(clientBin))
+	if err != nil {
+		t.Fatalf("Failed to wrap scion-netcat: %s\n", err)
+	}
+	serverCmd := integration.AppBinPath(serverBin)
+
+	testCases := []struct {
+		Name              string
+		Args              []string
+		ServerOutMatchFun func(bool, string) bool
+		ServerErrMatchFun func(bool, string) bool
+		ClientOutMatchFun func(bool, string) bool
+		ClientErrMatchFun func(bool, string) bool
+	}{
+		{
+			"client_probe_open",
+			append(cmnArgs, integration.DstAddrPattern+":"+serverPort),
+			nil,
+			nil,
+			integration.RegExp("^exit status 0$"),
+			nil,
+		},
+		{
+			"client_probe_open_path",
+			append(cmnArgs, integration.DstAddrPattern+":"+serverPort),
+			nil,
+			nil,
+			integration.RegExp("^Connection to .* succeeded, path: .*$"),
+			nil,
+		},
+		{
+			"client_probe_closed",
+			append(cmnArgs, integration.DstAddrPattern+":"+closedPort),
+			nil,
+			nil,
+			integration.RegExp("^exit status 1$"),
+			nil,
+		},
+		{
+			"client_probe_range",
+			append(cmnArgs, integration.DstAddrPattern+":"+serverPort+"-"+closedPort),
+			nil,
+			nil,
+			integration.RegExp("^exit status 0$"),
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		in := integration.NewAppsIntegration(name, tc.Name, clientCmd, serverCmd, tc.Args, serverArgs, true)
+		in.ServerStdout(tc.ServerOutMatchFun)
+		in.ServerStderr(tc.ServerErrMatchFun)
+		in.ClientStdout(tc.ClientOutMatchFun)
+		in.ClientStderr(tc.ClientErrMatchFun)
+
+		hostAddr := integration.HostAddr
+
+		IAPairs := integration.IAPairs(hostAddr)
+		IAPairs = IAPairs[:5]
+
+		if err := integration.RunTests(in, IAPairs, integration.DefaultClientTimeout, 250*time.Millisecond); err != nil {
+			t.Fatalf("Error during tests err: %v", err)
+		}
+	}
+}
+
 func wrapperCommand(tmpDir string, inputSource string, command string) (wrapperCmd string, err error) {
 	wrapperCmd = path.Join(tmpDir, fmt.Sprintf("%s_wrapper.sh", serverBin))
 	f, err := os.OpenFile(wrapperCmd, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
@@ -179,3 +262,16 @@ func wrapperCommand(tmpDir string, inputSource string, command string) (wrapperC
 		inputSource, command))
 	return wrapperCmd, nil
 }
+
+func exitStatusWrapperCommand(tmpDir string, command string) (wrapperCmd string, err error) {
+	wrapperCmd = path.Join(tmpDir, fmt.Sprintf("%s_exit_wrapper.sh", clientBin))
+	f, err := os.OpenFile(wrapperCmd, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("failed to create %s: %v", wrapperCmd, err))
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	defer w.Flush()
+	_, _ = w.WriteString(fmt.Sprintf("#!/bin/bash\n%s \"$@\"\necho \"exit status $?\"\n", command))
+	return wrapperCmd, nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+// Addr is a parsed SCION address, as returned by ParseAddr.
+type Addr struct {
+	IA addr.IA
+	// Host is either an IP address (addr.HostIPv4/addr.HostIPv6) or a service
+	// address (addr.HostSVC).
+	Host addr.HostAddr
+	// Zone is the IPv6 scoped addressing zone, if any.
+	Zone string
+	// Port is the port number, or 0 if the address did not include a port.
+	Port uint16
+}
+
+// AddrParseError is returned by ParseAddr for addresses that cannot be parsed.
+type AddrParseError struct {
+	Addr string
+	Msg  string
+}
+
+func (e *AddrParseError) Error() string {
+	return fmt.Sprintf("invalid SCION address %q: %s", e.Addr, e.Msg)
+}
+
+// ParseAddr parses a SCION address of the form "ISD-AS,host" or
+// "ISD-AS,host:port".
+// The host can be an IPv4 address, an IPv6 address or a service address
+// (e.g. "CS"). Brackets around the host are optional, except for IPv6
+// addresses followed by a port. IPv6 addresses may include a zone, e.g.
+// "1-ff00:0:110,[fe80::1%eth0]:80".
+func ParseAddr(s string) (Addr, error) {
+	fail := func(msg string) (Addr, error) {
+		return Addr{}, &AddrParseError{Addr: s, Msg: msg}
+	}
+
+	comma := strings.IndexByte(s, ',')
+	if comma < 0 {
+		return fail("missing ',' between ISD-AS and host")
+	}
+	ia, err := addr.IAFromString(s[:comma])
+	if err != nil {
+		return fail(fmt.Sprintf("invalid ISD-AS %q", s[:comma]))
+	}
+	hostPort := s[comma+1:]
+
+	var host, port string
+	hasPort := false
+	if strings.HasPrefix(hostPort, "[") {
+		end := strings.IndexByte(hostPort, ']')
+		if end < 0 {
+			return fail("missing ']'")
+		}
+		host = hostPort[1:end]
+		rest := hostPort[end+1:]
+		if rest != "" {
+			if rest[0] != ':' {
+				return fail(fmt.Sprintf("unexpected %q after ']'", rest))
+			}
+			port, hasPort = rest[1:], true
+		}
+		if strings.ContainsAny(host, "[]") {
+			return fail("unexpected bracket in host")
+		}
+	} else {
+		if strings.ContainsAny(hostPort, "[]") {
+			return fail("unexpected bracket in host")
+		}
+		host = hostPort
+		// A host that parses as a whole is taken as is; this allows for IPv6
+		// addresses without brackets, if there is no port.
+		if net.ParseIP(stripZone(host)) == nil {
+			if colon := strings.LastIndexByte(hostPort, ':'); colon >= 0 {
+				host, port, hasPort = hostPort[:colon], hostPort[colon+1:], true
+				if strings.IndexByte(host, ':') >= 0 {
+					return fail("IPv6 address with port must be enclosed in brackets")
+				}
+			}
+		}
+	}
+	if host == "" {
+		return fail("missing host")
+	}
+
+	a := Addr{IA: ia}
+	if svc := addr.HostSVCFromString(host); svc != addr.SvcNone {
+		a.Host = svc
+	} else {
+		ipStr := host
+		if i := strings.IndexByte(host, '%'); i >= 0 {
+			ipStr, a.Zone = host[:i], host[i+1:]
+			if a.Zone == "" {
+				return fail("empty IPv6 zone")
+			}
+		}
+		ip := net.ParseIP(ipStr)
+		if ip == nil {
+			return fail(fmt.Sprintf("invalid host %q", host))
+		}
+		if a.Zone != "" && ip.To4() != nil {
+			return fail("zone is only allowed for IPv6 addresses")
+		}
+		a.Host = addr.HostFromIP(ip)
+	}
+
+	if hasPort {
+		if port == "" {
+			return fail("missing port after ':'")
+		}
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return fail(fmt.Sprintf("invalid port %q", port))
+		}
+		a.Port = uint16(p)
+	}
+	return a, nil
+}
+
+// String returns the address in the canonical form "ISD-AS,[host]:port", or
+// "ISD-AS,[host]" if the port is 0. The result can be parsed with ParseAddr.
+func (a Addr) String() string {
+	var host string
+	if svc, ok := a.Host.(addr.HostSVC); ok {
+		host = svc.BaseString()
+		if svc.IsMulticast() {
+			host += "_M"
+		}
+	} else if a.Host != nil {
+		host = a.Host.IP().String()
+		if a.Zone != "" {
+			host += "%" + a.Zone
+		}
+	}
+	if a.Port == 0 {
+		return fmt.Sprintf("%s,[%s]", a.IA, host)
+	}
+	return fmt.Sprintf("%s,[%s]:%d", a.IA, host, a.Port)
+}
+
+// SCIONAddress returns the address without the port as snet.SCIONAddress.
+func (a Addr) SCIONAddress() snet.SCIONAddress {
+	return snet.SCIONAddress{IA: a.IA, Host: a.Host}
+}
+
+// UDPAddr returns the address as snet.UDPAddr. This fails for service
+// addresses.
+func (a Addr) UDPAddr() (*snet.UDPAddr, error) {
+	if _, ok := a.Host.(addr.HostSVC); ok || a.Host == nil {
+		return nil, fmt.Errorf("not an IP address: %s", a)
+	}
+	return &snet.UDPAddr{
+		IA:   a.IA,
+		Host: &net.UDPAddr{IP: a.Host.IP(), Port: int(a.Port), Zone: a.Zone},
+	}, nil
+}
+
+// ParseUDPAddr parses a SCION UDP address with ParseAddr. In contrast to
+// snet.ParseUDPAddr, this accepts all the forms accepted by ParseAddr, in
+// particular IPv6 addresses with zones. Service addresses are rejected.
+func ParseUDPAddr(s string) (*snet.UDPAddr, error) {
+	a, err := ParseAddr(s)
+	if err != nil {
+		return nil, err
+	}
+	return a.UDPAddr()
+}
+
+// FormatUDPAddr formats a SCION UDP address in the canonical form of
+// Addr.String, "ISD-AS,[host]:port". The result can be parsed with
+// ParseUDPAddr, yielding the same address.
+func FormatUDPAddr(u *snet.UDPAddr) string {
+	return Addr{
+		IA:   u.IA,
+		Host: addr.HostFromIP(u.Host.IP),
+		Zone: u.Host.Zone,
+		Port: uint16(u.Host.Port),
+	}.String()
+}
+
+func stripZone(host string) string {
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		return host[:i]
+	}
+	return host
+}
@@ -0,0 +1,53 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build gofuzz
+
+package appnet
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Fuzz is the entry point for go-fuzz (github.com/dvyukov/go-fuzz):
+//
+//   go-fuzz-build -func Fuzz ./pkg/appnet && go-fuzz
+//
+// It checks that ParseAddr does not panic and that any successfully parsed
+// address can be formatted and parsed back to the same value, also via
+// ParseUDPAddr and FormatUDPAddr.
+func Fuzz(data []byte) int {
+	a, err := ParseAddr(string(data))
+	if err != nil {
+		return 0
+	}
+	b, err := ParseAddr(a.String())
+	if err != nil {
+		panic(fmt.Sprintf("cannot parse formatted address %q: %s", a, err))
+	}
+	if !reflect.DeepEqual(a, b) {
+		panic(fmt.Sprintf("round trip mismatch, %v != %v", a, b))
+	}
+	if u, err := a.UDPAddr(); err == nil {
+		v, err := ParseUDPAddr(FormatUDPAddr(u))
+		if err != nil {
+			panic(fmt.Sprintf("cannot parse formatted UDP address %q: %s", FormatUDPAddr(u), err))
+		}
+		if !reflect.DeepEqual(u, v) {
+			panic(fmt.Sprintf("UDP address round trip mismatch, %v != %v", u, v))
+		}
+	}
+	return 1
+}
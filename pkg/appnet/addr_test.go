@@ -0,0 +1,221 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"math/rand"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/scionproto/scion/go/lib/addr"
+)
+
+func TestParseAddr(t *testing.T) {
+	ia := mustParseIA("1-ff00:0:110")
+	ip := func(s string) addr.HostAddr { return addr.HostFromIP(net.ParseIP(s)) }
+	cases := []struct {
+		input    string
+		expected Addr
+		err      bool
+	}{
+		{input: "1-ff00:0:110,[192.0.2.1]", expected: Addr{IA: ia, Host: ip("192.0.2.1")}},
+		{input: "1-ff00:0:110,192.0.2.1", expected: Addr{IA: ia, Host: ip("192.0.2.1")}},
+		{input: "1-ff00:0:110,[192.0.2.1]:80", expected: Addr{IA: ia, Host: ip("192.0.2.1"), Port: 80}},
+		{input: "1-ff00:0:110,192.0.2.1:80", expected: Addr{IA: ia, Host: ip("192.0.2.1"), Port: 80}},
+		{input: "1-ff00:0:110,[2001:db8::1]", expected: Addr{IA: ia, Host: ip("2001:db8::1")}},
+		{input: "1-ff00:0:110,2001:db8::1", expected: Addr{IA: ia, Host: ip("2001:db8::1")}},
+		{input: "1-ff00:0:110,[2001:db8::1]:443", expected: Addr{IA: ia, Host: ip("2001:db8::1"), Port: 443}},
+		{input: "1-ff00:0:110,[::ffff:192.0.2.1]:1", expected: Addr{IA: ia, Host: ip("192.0.2.1"), Port: 1}},
+		{input: "1-ff00:0:110,[fe80::1%eth0]:80", expected: Addr{IA: ia, Host: ip("fe80::1"), Zone: "eth0", Port: 80}},
+		{input: "1-ff00:0:110,fe80::1%eth0", expected: Addr{IA: ia, Host: ip("fe80::1"), Zone: "eth0"}},
+		{input: "1-ff00:0:110,[CS]", expected: Addr{IA: ia, Host: addr.SvcCS}},
+		{input: "1-ff00:0:110,CS", expected: Addr{IA: ia, Host: addr.SvcCS}},
+		{input: "1-ff00:0:110,CS_M:30041", expected: Addr{IA: ia, Host: addr.SvcCS.Multicast(), Port: 30041}},
+		{input: "1-ff00:0:110,[192.0.2.1]:65535", expected: Addr{IA: ia, Host: ip("192.0.2.1"), Port: 65535}},
+
+		// malformed
+		{input: "", err: true},
+		{input: "192.0.2.1", err: true},
+		{input: "1-ff00:0:110", err: true},
+		{input: "1-ff00:0:110,", err: true},
+		{input: "1-ff00:0:110,[]", err: true},
+		{input: "1-ff00:0:110,[]:80", err: true},
+		{input: "foo,[192.0.2.1]", err: true},
+		{input: ",[192.0.2.1]", err: true},
+		{input: "1-ff00:0:110,foo", err: true},
+		{input: "1-ff00:0:110,192.0.2.1:", err: true},
+		{input: "1-ff00:0:110,[192.0.2.1]:", err: true},
+		{input: "1-ff00:0:110,[192.0.2.1]:65536", err: true},
+		{input: "1-ff00:0:110,[192.0.2.1]:-1", err: true},
+		{input: "1-ff00:0:110,[192.0.2.1]:http", err: true},
+		{input: "1-ff00:0:110,192.0.2.1:80:80", err: true},
+		{input: "1-ff00:0:110,2001:db8::1:80x", err: true},
+		{input: "1-ff00:0:110,0:0:0:80", err: true},
+		{input: "1-ff00:0:110,1-ff00:0:110,[192.0.2.1]", err: true},
+		// brackets
+		{input: "1-ff00:0:110,[192.0.2.1", err: true},
+		{input: "1-ff00:0:110,192.0.2.1]", err: true},
+		{input: "1-ff00:0:110,[[192.0.2.1]]", err: true},
+		{input: "1-ff00:0:110,[192.0.2.1]]", err: true},
+		{input: "1-ff00:0:110,[192.0.2.1]80", err: true},
+		{input: "1-ff00:0:110,[2001:db8::1]:[80]", err: true},
+		{input: "[1-ff00:0:110,192.0.2.1]:80", err: true},
+		// zones
+		{input: "1-ff00:0:110,[fe80::1%]", err: true},
+		{input: "1-ff00:0:110,[192.0.2.1%eth0]", err: true},
+		{input: "1-ff00:0:110,fe80::1%eth0:80", expected: Addr{IA: ia, Host: ip("fe80::1"), Zone: "eth0:80"}},
+		{input: "1-ff00:0:110,[CS%eth0]", err: true},
+	}
+	for _, c := range cases {
+		actual, err := ParseAddr(c.input)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected error, got %v", c.input, actual)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", c.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%q: expected %v, got %v", c.input, c.expected, actual)
+		}
+		checkParseAddrRoundtrip(t, c.input)
+	}
+}
+
+func TestAddrUDPAddr(t *testing.T) {
+	a, err := ParseAddr("1-ff00:0:110,[fe80::1%eth0]:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := a.UDPAddr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.IA != a.IA || !u.Host.IP.Equal(net.ParseIP("fe80::1")) || u.Host.Zone != "eth0" || u.Host.Port != 80 {
+		t.Errorf("unexpected result %s", u)
+	}
+
+	a, err = ParseAddr("1-ff00:0:110,CS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.UDPAddr(); err == nil {
+		t.Errorf("expected error for SVC address")
+	}
+}
+
+func TestUDPAddrRoundtrip(t *testing.T) {
+	cases := []struct {
+		input     string
+		formatted string
+	}{
+		{"1-ff00:0:110,192.0.2.1:80", "1-ff00:0:110,[192.0.2.1]:80"},
+		{"1-ff00:0:110,[192.0.2.1]", "1-ff00:0:110,[192.0.2.1]"},
+		{"1-ff00:0:110,2001:db8::1", "1-ff00:0:110,[2001:db8::1]"},
+		{"1-ff00:0:110,[2001:db8::1]:443", "1-ff00:0:110,[2001:db8::1]:443"},
+		{"1-ff00:0:110,[fe80::1%eth0]:80", "1-ff00:0:110,[fe80::1%eth0]:80"},
+		{"1-ff00:0:110,fe80::1%eth0", "1-ff00:0:110,[fe80::1%eth0]"},
+	}
+	for _, c := range cases {
+		u, err := ParseUDPAddr(c.input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", c.input, err)
+			continue
+		}
+		formatted := FormatUDPAddr(u)
+		if formatted != c.formatted {
+			t.Errorf("%q: expected %q, got %q", c.input, c.formatted, formatted)
+		}
+		v, err := ParseUDPAddr(formatted)
+		if err != nil {
+			t.Errorf("%q: cannot parse formatted address %q: %s", c.input, formatted, err)
+			continue
+		}
+		if !reflect.DeepEqual(u, v) {
+			t.Errorf("%q: round trip mismatch, %v != %v", c.input, u, v)
+		}
+	}
+
+	for _, input := range []string{"1-ff00:0:110,CS", "1-ff00:0:110,[192.0.2.1%eth0]", "1-ff00:0:110,[::1]:"} {
+		if u, err := ParseUDPAddr(input); err == nil {
+			t.Errorf("%q: expected error, got %v", input, u)
+		}
+	}
+}
+
+// TestParseAddrMutations feeds randomly mutated addresses to ParseAddr, to
+// check that it never panics and that successfully parsed addresses
+// round-trip. See also Fuzz, for use with go-fuzz.
+func TestParseAddrMutations(t *testing.T) {
+	seeds := []string{
+		"1-ff00:0:110,[192.0.2.1]:80",
+		"1-ff00:0:110,[2001:db8::1]:443",
+		"1-ff00:0:110,[fe80::1%eth0]:80",
+		"1-ff00:0:110,CS_M:30041",
+		"65535-ffff:ffff:ffff,::ffff:192.0.2.1",
+	}
+	alphabet := []byte("0123456789abcdefABCDEF:,.[]%-_ CSM")
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		b := []byte(seeds[rng.Intn(len(seeds))])
+		for n := rng.Intn(4) + 1; n > 0; n-- {
+			pos := rng.Intn(len(b) + 1)
+			switch rng.Intn(3) {
+			case 0: // insert
+				b = append(b[:pos], append([]byte{alphabet[rng.Intn(len(alphabet))]}, b[pos:]...)...)
+			case 1: // delete
+				if pos < len(b) {
+					b = append(b[:pos], b[pos+1:]...)
+				}
+			case 2: // replace
+				if pos < len(b) {
+					b[pos] = alphabet[rng.Intn(len(alphabet))]
+				}
+			}
+		}
+		checkParseAddrRoundtrip(t, string(b))
+	}
+}
+
+// checkParseAddrRoundtrip checks that, if s can be parsed, formatting and
+// parsing the result again yields the same address.
+func checkParseAddrRoundtrip(t *testing.T, s string) {
+	t.Helper()
+	a, err := ParseAddr(s)
+	if err != nil {
+		return
+	}
+	formatted := a.String()
+	b, err := ParseAddr(formatted)
+	if err != nil {
+		t.Errorf("%q: cannot parse formatted address %q: %s", s, formatted, err)
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("%q: round trip mismatch, %v != %v", s, a, b)
+	}
+}
+
+func mustParseIA(s string) addr.IA {
+	ia, err := addr.IAFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return ia
+}
@@ -0,0 +1,250 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+// AddressBookEntry maps an alias to a SCION address.
+type AddressBookEntry struct {
+	Alias string
+	// Address is a SCION address of the form "ISD-AS,[IP]", optionally with a
+	// port.
+	Address string
+	// PathPolicy is the preferred path policy for this destination, if any.
+	// It is not interpreted here, but left to the application.
+	PathPolicy string
+}
+
+// AddressBook is a user's list of aliases for SCION addresses.
+//
+// The address book file contains one entry per line, of the form
+//   alias address [path-policy]
+// Everything after a '#' is a comment.
+type AddressBook struct {
+	Entries []AddressBookEntry
+}
+
+// addressBookPath is the path of the address book used by DefaultResolver and
+// ResolveUDPAddr, or empty if the home directory is not known.
+var addressBookPath = defaultAddressBookPath()
+
+func defaultAddressBookPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".scion", "addressbook")
+}
+
+// DefaultAddressBookPath returns the path of the user's address book,
+// ~/.scion/addressbook.
+func DefaultAddressBookPath() (string, error) {
+	if addressBookPath == "" {
+		return "", fmt.Errorf("home directory not known")
+	}
+	return addressBookPath, nil
+}
+
+// LoadAddressBook reads the address book file. A non-existing file is
+// treated like an empty file.
+// Invalid lines are skipped, analogous to the hosts files.
+func LoadAddressBook(path string) (*AddressBook, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &AddressBook{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseAddressBook(file)
+}
+
+func parseAddressBook(r io.Reader) (*AddressBook, error) {
+	book := &AddressBook{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// ignore comments
+		cstart := strings.IndexRune(line, '#')
+		if cstart >= 0 {
+			line = line[:cstart]
+		}
+
+		// cut into fields: alias address [path-policy]
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			continue
+		}
+		entry := AddressBookEntry{Alias: fields[0], Address: fields[1]}
+		if len(fields) == 3 {
+			entry.PathPolicy = fields[2]
+		}
+		if entry.validate() != nil {
+			continue
+		}
+		// the first entry for an alias wins, like in the hosts files
+		if _, ok := book.Lookup(entry.Alias); !ok {
+			book.Entries = append(book.Entries, entry)
+		}
+	}
+	return book, scanner.Err()
+}
+
+// Save writes the address book to the file, creating the directory if
+// necessary. The file is replaced atomically.
+func (b *AddressBook) Save(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, ".addressbook")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	fmt.Fprintln(w, "# alias address [path-policy]")
+	for _, e := range b.Entries {
+		if e.PathPolicy != "" {
+			fmt.Fprintf(w, "%s %s %s\n", e.Alias, e.Address, e.PathPolicy)
+		} else {
+			fmt.Fprintf(w, "%s %s\n", e.Alias, e.Address)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Lookup returns the entry for the alias.
+func (b *AddressBook) Lookup(alias string) (AddressBookEntry, bool) {
+	for _, e := range b.Entries {
+		if e.Alias == alias {
+			return e, true
+		}
+	}
+	return AddressBookEntry{}, false
+}
+
+// Add adds the entry, replacing any existing entry with the same alias.
+// Returns an error if the alias or the address is invalid.
+func (b *AddressBook) Add(entry AddressBookEntry) error {
+	if err := entry.validate(); err != nil {
+		return err
+	}
+	for i, e := range b.Entries {
+		if e.Alias == entry.Alias {
+			b.Entries[i] = entry
+			return nil
+		}
+	}
+	b.Entries = append(b.Entries, entry)
+	return nil
+}
+
+// Remove removes the entry for the alias. Returns false if there was none.
+func (b *AddressBook) Remove(alias string) bool {
+	for i, e := range b.Entries {
+		if e.Alias == alias {
+			b.Entries = append(b.Entries[:i], b.Entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks that the alias can be used in place of a host name and
+// that the address is a valid SCION address.
+// An alias that could be mistaken for a literal address is not allowed;
+// literal addresses always take precedence.
+func (e AddressBookEntry) validate() error {
+	if e.Alias == "" || strings.ContainsAny(e.Alias, ",:[]#/ \t") {
+		return fmt.Errorf("invalid alias %q", e.Alias)
+	}
+	if net.ParseIP(e.Alias) != nil {
+		return fmt.Errorf("invalid alias %q: looks like an IP address", e.Alias)
+	}
+	if strings.ContainsAny(e.PathPolicy, "# \t") {
+		return fmt.Errorf("invalid path policy %q", e.PathPolicy)
+	}
+	_, err := ParseAddr(e.Address)
+	return err
+}
+
+// addressBookResolver is an implementation of the resolver interface, backed
+// by an address book file. Ports in the address book entries are ignored.
+type addressBookResolver struct {
+	path string
+}
+
+func (r *addressBookResolver) Resolve(name string) (*snet.SCIONAddress, error) {
+	if r.path == "" {
+		return nil, &HostNotFoundError{name}
+	}
+	book, err := LoadAddressBook(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s: %s", r.path, err)
+	}
+	entry, ok := book.Lookup(name)
+	if !ok {
+		return nil, &HostNotFoundError{name}
+	}
+	a, err := ParseAddr(entry.Address)
+	if err != nil {
+		return nil, err
+	}
+	addr := a.SCIONAddress()
+	return &addr, nil
+}
+
+// resolveAddressBookAlias returns the address for an alias used on its own,
+// i.e. without port, if its entry in the address book includes the port.
+func resolveAddressBookAlias(path, alias string) (*snet.UDPAddr, bool, error) {
+	if path == "" || strings.ContainsAny(alias, ",:") {
+		return nil, false, nil
+	}
+	book, err := LoadAddressBook(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("error loading %s: %s", path, err)
+	}
+	entry, ok := book.Lookup(alias)
+	if !ok {
+		return nil, false, nil
+	}
+	a, err := ParseAddr(entry.Address)
+	if err != nil || a.Port == 0 {
+		return nil, false, err
+	}
+	raddr, err := a.UDPAddr()
+	return raddr, err == nil, err
+}
@@ -0,0 +1,180 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+func TestParseAddressBook(t *testing.T) {
+	content := `
+# comment
+server 17-ffaa:0:1,[192.168.1.1]:8080 shortest
+db     18-ffaa:1:2,[10.0.8.10]   # trailing comment
+server 17-ffaa:0:1,[192.168.1.2]      # duplicate, ignored
+1.2.3.4 17-ffaa:0:1,[192.168.1.1]     # invalid alias
+bad    not-an-address
+a,b    17-ffaa:0:1,[192.168.1.1]
+`
+	book, err := parseAddressBook(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []AddressBookEntry{
+		{"server", "17-ffaa:0:1,[192.168.1.1]:8080", "shortest"},
+		{"db", "18-ffaa:1:2,[10.0.8.10]", ""},
+	}
+	if !reflect.DeepEqual(book.Entries, expected) {
+		t.Errorf("expected %v, got %v", expected, book.Entries)
+	}
+}
+
+func TestAddressBookRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "addressbook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sub", "addressbook")
+
+	// not existing file is empty
+	book, err := LoadAddressBook(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(book.Entries) != 0 {
+		t.Fatalf("expected empty address book, got %v", book.Entries)
+	}
+
+	for _, e := range []AddressBookEntry{
+		{"server", "17-ffaa:0:1,[192.168.1.1]:8080", "shortest"},
+		{"db", "18-ffaa:1:2,[10.0.8.10]", ""},
+		{"v6", "20-ffaa:c0ff:ee12,[::ff1:ce00:dead:10cc:baad:f00d]:22", ""},
+		{"server", "17-ffaa:0:1,[192.168.1.1]:8081", ""}, // replaces first entry
+	} {
+		if err := book.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range []AddressBookEntry{
+		{"", "17-ffaa:0:1,[192.168.1.1]", ""},
+		{"host:80", "17-ffaa:0:1,[192.168.1.1]", ""},
+		{"17-ffaa:0:1,[192.168.1.1]", "17-ffaa:0:1,[192.168.1.1]", ""},
+		{"::1", "17-ffaa:0:1,[192.168.1.1]", ""},
+		{"x", "192.168.1.1", ""},
+		{"x", "17-ffaa:0:1,[192.168.1.1]", "with space"},
+	} {
+		if err := book.Add(e); err == nil {
+			t.Errorf("expected error adding %v", e)
+		}
+	}
+	if !book.Remove("db") || book.Remove("db") {
+		t.Error("unexpected result of Remove")
+	}
+
+	if err := book.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadAddressBook(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []AddressBookEntry{
+		{"server", "17-ffaa:0:1,[192.168.1.1]:8081", ""},
+		{"v6", "20-ffaa:c0ff:ee12,[::ff1:ce00:dead:10cc:baad:f00d]:22", ""},
+	}
+	if !reflect.DeepEqual(loaded.Entries, expected) {
+		t.Errorf("expected %v, got %v", expected, loaded.Entries)
+	}
+}
+
+func TestAddressBookResolver(t *testing.T) {
+	path := writeTestAddressBook(t, `
+server 17-ffaa:0:1,[192.168.1.1]:8080
+db     18-ffaa:1:2,[10.0.8.10]
+`)
+	resolver := &addressBookResolver{path}
+	cases := []testCase{
+		{"server", mustParse("17-ffaa:0:1,[192.168.1.1]")},
+		{"db", mustParse("18-ffaa:1:2,[10.0.8.10]")},
+		{"foobar", nil},
+	}
+	testResolver(t, resolver, cases)
+	testResolver(t, &addressBookResolver{""}, []testCase{{"server", nil}})
+}
+
+func TestResolveUDPAddrAlias(t *testing.T) {
+	path := writeTestAddressBook(t, `
+server 17-ffaa:0:1,[192.168.1.1]:8080
+db     18-ffaa:1:2,[10.0.8.10]
+`)
+	defer func(p string) { addressBookPath = p }(addressBookPath)
+	addressBookPath = path
+	// only consult the address book, other resolvers are not available in
+	// the test environment.
+	resolver := ResolverList{&addressBookResolver{path}}
+
+	cases := []struct {
+		address  string
+		expected string
+	}{
+		// the port in the entry is used if none is given...
+		{"server", "17-ffaa:0:1,192.168.1.1:8080"},
+		// ... and overridden otherwise
+		{"server:22", "17-ffaa:0:1,192.168.1.1:22"},
+		{"db:5432", "18-ffaa:1:2,10.0.8.10:5432"},
+		// literal addresses take precedence
+		{"17-ffaa:0:2,[192.168.1.2]:80", "17-ffaa:0:2,192.168.1.2:80"},
+	}
+	for _, c := range cases {
+		var actual *snet.UDPAddr
+		var err error
+		if strings.ContainsRune(c.address, ':') {
+			actual, err = ResolveUDPAddrAt(c.address, resolver)
+		} else {
+			actual, err = ResolveUDPAddr(c.address)
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.address, err)
+		} else if actual.String() != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.address, c.expected, actual)
+		}
+	}
+
+	// an alias without port in the entry needs a port
+	if _, err := ResolveUDPAddr("db"); err == nil {
+		t.Error("expected error for alias without port")
+	}
+}
+
+func writeTestAddressBook(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "addressbook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "addressbook")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -32,6 +32,11 @@ single SCION AS. When running multiple local ASes, e.g. during development, the
 address of the sciond corresponding to the desired AS needs to be specified in
 the SCION_DAEMON_ADDRESS environment variable.
 
+By default, calls to sciond fail right away if sciond is not reachable, e.g.
+while it is restarted. Long-running applications can set
+SCION_DAEMON_RECONNECT=1 to reconnect and retry such calls with backoff, see
+ReconnectingConnector.
+
 
 Wildcard IP Addresses
 
@@ -54,6 +59,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
@@ -73,6 +79,7 @@ type Network struct {
 	IA            addr.IA
 	PathQuerier   snet.PathQuerier
 	hostInLocalAS net.IP
+	sciond        sciond.Connector
 }
 
 const (
@@ -109,18 +116,48 @@ func Dial(address string) (*snet.Conn, error) {
 // This is all that snet currently provides, we'll need to add a layer on top
 // that updates the paths in case they expire or are revoked.
 func DialAddr(raddr *snet.UDPAddr) (*snet.Conn, error) {
+	return DialAddrFrom(nil, raddr)
+}
+
+// DialAddrFrom is like DialAddr, but uses the given local address. If the IP
+// of local is not set, the local IP is determined as in DialAddr.
+// This can be used together with ChoosePathByMetricFrom, to control the egress
+// of the traffic on multi-homed hosts.
+func DialAddrFrom(local *net.UDPAddr, raddr *snet.UDPAddr) (*snet.Conn, error) {
 	if raddr.Path.IsEmpty() {
 		err := SetDefaultPath(raddr)
 		if err != nil {
 			return nil, err
 		}
 	}
-	localIP, err := resolveLocal(raddr)
+	laddr := &net.UDPAddr{}
+	if local != nil {
+		*laddr = *local
+	}
+	if laddr.IP == nil || laddr.IP.IsUnspecified() {
+		localIP, err := resolveLocal(raddr)
+		if err != nil {
+			return nil, err
+		}
+		laddr.IP = localIP
+	}
+	return DefNetwork().Dial(context.Background(), "udp", laddr, raddr, addr.SvcNone)
+}
+
+// DialAddrWithPath connects to the address over the given path, e.g. a path
+// obtained from an earlier query, without querying sciond for paths.
+// If the path has expired or does not lead to the destination, and fallback is
+// set, paths are queried and the path with the same interfaces as the given
+// path is used or, if there is none, the first available path. If fallback is
+// not set, an ErrPathExpired or ErrPathMismatch is returned instead.
+func DialAddrWithPath(raddr *snet.UDPAddr, path snet.Path, fallback bool) (*snet.Conn, error) {
+	path, err := validatePath(raddr.IA, path, fallback, time.Now(), QueryPaths)
 	if err != nil {
 		return nil, err
 	}
-	laddr := &net.UDPAddr{IP: localIP}
-	return DefNetwork().Dial(context.Background(), "udp", laddr, raddr, addr.SvcNone)
+	raddr = raddr.Copy()
+	SetPath(raddr, path)
+	return DialAddr(raddr)
 }
 
 // Listen acts like net.ListenUDP in a SCION network.
@@ -211,7 +248,7 @@ func initDefNetwork() error {
 		dispatcher,
 		sciond.RevHandler{Connector: sciondConn},
 	)
-	defNetwork = Network{Network: n, IA: localIA, PathQuerier: pathQuerier, hostInLocalAS: hostInLocalAS}
+	defNetwork = Network{Network: n, IA: localIA, PathQuerier: pathQuerier, hostInLocalAS: hostInLocalAS, sciond: sciondConn}
 	return nil
 }
 
@@ -220,6 +257,14 @@ func findSciond(ctx context.Context) (sciond.Connector, error) {
 	if !ok {
 		address = sciond.DefaultAPIAddress
 	}
+	if reconnect, _ := strconv.ParseBool(os.Getenv("SCION_DAEMON_RECONNECT")); reconnect {
+		sciondConn := NewReconnectingConnector(address)
+		// connect right away, to report an unreachable SCIOND at initialization
+		if _, err := sciondConn.connection(ctx); err != nil {
+			return nil, fmt.Errorf("unable to connect to SCIOND at %s (override with SCION_DAEMON_ADDRESS): %w", address, err)
+		}
+		return sciondConn, nil
+	}
 	sciondConn, err := sciond.NewService(address).Connect(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("unable to connect to SCIOND at %s (override with SCION_DAEMON_ADDRESS): %w", address, err)
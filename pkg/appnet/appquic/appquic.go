@@ -18,6 +18,7 @@
 package appquic
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"sync"
@@ -62,11 +63,18 @@ func (s *closerEarlySession) CloseWithError(code quic.ErrorCode, desc string) er
 // The address can be of the form of a SCION address (i.e. of the form "ISD-AS,[IP]:port")
 // or in the form of hostname:port.
 func Dial(remote string, tlsConf *tls.Config, quicConf *quic.Config) (quic.Session, error) {
+	return DialContext(context.Background(), remote, tlsConf, quicConf)
+}
+
+// DialContext is like Dial, but the handshake is aborted when ctx is done.
+func DialContext(ctx context.Context, remote string, tlsConf *tls.Config,
+	quicConf *quic.Config) (quic.Session, error) {
+
 	raddr, err := appnet.ResolveUDPAddr(remote)
 	if err != nil {
 		return nil, err
 	}
-	return DialAddr(raddr, remote, tlsConf, quicConf)
+	return DialAddrContext(ctx, raddr, remote, tlsConf, quicConf)
 }
 
 // DialAddr establishes a new QUIC connection to a server at the remote address.
@@ -76,6 +84,14 @@ func Dial(remote string, tlsConf *tls.Config, quicConf *quic.Config) (quic.Sessi
 // The host parameter is used for SNI.
 // The tls.Config must define an application protocol (using NextProtos).
 func DialAddr(raddr *snet.UDPAddr, host string, tlsConf *tls.Config, quicConf *quic.Config) (quic.Session, error) {
+	return DialAddrContext(context.Background(), raddr, host, tlsConf, quicConf)
+}
+
+// DialAddrContext is like DialAddr, but the handshake is aborted when ctx is
+// done.
+func DialAddrContext(ctx context.Context, raddr *snet.UDPAddr, host string, tlsConf *tls.Config,
+	quicConf *quic.Config) (quic.Session, error) {
+
 	err := ensurePathDefined(raddr)
 	if err != nil {
 		return nil, err
@@ -85,8 +101,9 @@ func DialAddr(raddr *snet.UDPAddr, host string, tlsConf *tls.Config, quicConf *q
 		return nil, err
 	}
 	host = appnet.MangleSCIONAddr(host)
-	session, err := quic.Dial(sconn, raddr, host, tlsConf, quicConf)
+	session, err := quic.DialContext(ctx, sconn, raddr, host, tlsConf, quicConf)
 	if err != nil {
+		sconn.Close()
 		return nil, err
 	}
 	return &closerSession{session, sconn}, nil
@@ -0,0 +1,341 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appquic
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/snet"
+	"github.com/scionproto/scion/go/lib/spath"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+)
+
+// StreamLingerTimeout is the maximum time a StreamConn keeps the QUIC session
+// open after Close, to allow the remaining data to be delivered.
+var StreamLingerTimeout = 2 * time.Second
+
+// StreamConn is a net.Conn backed by a single QUIC stream in its own session,
+// as returned by DialStream and StreamListener.Accept.
+type StreamConn struct {
+	session   quic.Session
+	stream    quic.Stream
+	closeOnce sync.Once
+}
+
+var _ net.Conn = (*StreamConn)(nil)
+
+// NewStreamConn returns a StreamConn for a stream in a session that is not
+// used for any other streams, e.g. one established with quic.DialAddr over
+// plain UDP in tests. The StreamConn takes ownership of the session.
+func NewStreamConn(session quic.Session, stream quic.Stream) *StreamConn {
+	return &StreamConn{session: session, stream: stream}
+}
+
+// DialStream establishes a new QUIC session to the remote address and opens a
+// single stream. See Dial for the address format.
+func DialStream(remote string, tlsConf *tls.Config, quicConf *quic.Config) (*StreamConn, error) {
+	session, err := Dial(remote, tlsConf, quicConf)
+	if err != nil {
+		return nil, err
+	}
+	return openStream(context.Background(), session)
+}
+
+// DialAddrStream establishes a new QUIC session to the remote address and
+// opens a single stream. See DialAddr.
+func DialAddrStream(raddr *snet.UDPAddr, host string, tlsConf *tls.Config,
+	quicConf *quic.Config) (*StreamConn, error) {
+
+	return DialAddrStreamContext(context.Background(), raddr, host, tlsConf, quicConf)
+}
+
+// DialAddrStreamContext is like DialAddrStream, but the handshake and opening
+// the stream are aborted when ctx is done.
+func DialAddrStreamContext(ctx context.Context, raddr *snet.UDPAddr, host string,
+	tlsConf *tls.Config, quicConf *quic.Config) (*StreamConn, error) {
+
+	session, err := DialAddrContext(ctx, raddr, host, tlsConf, quicConf)
+	if err != nil {
+		return nil, err
+	}
+	return openStream(ctx, session)
+}
+
+func openStream(ctx context.Context, session quic.Session) (*StreamConn, error) {
+	stream, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		_ = session.CloseWithError(0, "")
+		return nil, err
+	}
+	return NewStreamConn(session, stream), nil
+}
+
+// StreamDialer opens StreamConns, selecting the path for each of them with a
+// path selection metric.
+type StreamDialer struct {
+	// PathAlgo is the metric by which the path is chosen for each stream, see
+	// appnet.ChoosePathByMetric.
+	PathAlgo int
+	// TLSConfig must define an application protocol, see DialAddr.
+	TLSConfig *tls.Config
+	// QUICConfig is optional, see DialAddr.
+	QUICConfig *quic.Config
+}
+
+// PathError is returned by a StreamDialer if no path to the remote could be
+// chosen.
+type PathError struct {
+	IA  addr.IA
+	Err error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("no path to %s: %s", e.IA, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// Dial resolves the remote address, see appnet.ResolveUDPAddr, and opens a
+// stream to it with DialAddr.
+func (d *StreamDialer) Dial(remote string) (*StreamConn, error) {
+	raddr, err := appnet.ResolveUDPAddr(remote)
+	if err != nil {
+		return nil, err
+	}
+	return d.DialAddr(raddr, remote)
+}
+
+// DialAddr chooses a path to raddr and opens a stream to it over this path.
+// The path is chosen anew for every stream. The host parameter is used for
+// SNI.
+func (d *StreamDialer) DialAddr(raddr *snet.UDPAddr, host string) (*StreamConn, error) {
+	path, err := appnet.ChoosePathByMetric(d.PathAlgo, raddr.IA)
+	if err != nil {
+		return nil, &PathError{IA: raddr.IA, Err: err}
+	}
+	if path != nil {
+		raddr = raddr.Copy()
+		appnet.SetPath(raddr, path)
+	}
+	return DialAddrStream(raddr, host, d.TLSConfig, d.QUICConfig)
+}
+
+// halfCloser is a connection whose sending direction can be closed
+// separately, like a TCP connection, a StreamConn or an SSH channel.
+type halfCloser interface {
+	io.Writer
+	CloseWrite() error
+}
+
+// Pipe copies data in both directions between a and b, e.g. between a TCP
+// connection and a StreamConn, until both directions are done. When one side
+// has no more data to send, the sending direction of the other side is closed,
+// so that it sees the EOF, too. If the other side cannot close only its
+// sending direction, or if either direction fails, both are closed
+// immediately; otherwise they are closed once both directions are done.
+func Pipe(a, b io.ReadWriteCloser) {
+	closeBoth := func() {
+		a.Close()
+		b.Close()
+	}
+	var once sync.Once
+	var wg sync.WaitGroup
+	copyHalf := func(dst, src io.ReadWriteCloser) {
+		defer wg.Done()
+		_, err := io.Copy(dst, src)
+		if hc, ok := dst.(halfCloser); ok && err == nil {
+			_ = hc.CloseWrite()
+			return
+		}
+		once.Do(closeBoth)
+	}
+	wg.Add(2)
+	go copyHalf(a, b)
+	go copyHalf(b, a)
+	wg.Wait()
+	once.Do(closeBoth)
+}
+
+// Read reads data from the stream.
+func (c *StreamConn) Read(b []byte) (int, error) {
+	return c.stream.Read(b)
+}
+
+// Write writes data to the stream.
+func (c *StreamConn) Write(b []byte) (int, error) {
+	return c.stream.Write(b)
+}
+
+// CloseWrite closes the sending side of the stream. The peer will read
+// io.EOF after receiving all data, while this side can still read.
+func (c *StreamConn) CloseWrite() error {
+	return c.stream.Close()
+}
+
+// Close closes the stream and returns immediately. Any blocked Read will be
+// unblocked. The data already written is still delivered; the session is closed
+// in the background once the peer has closed it, or after StreamLingerTimeout.
+func (c *StreamConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		err = c.stream.Close()
+		c.stream.CancelRead(0)
+		go c.linger()
+	})
+	return err
+}
+
+func (c *StreamConn) linger() {
+	timer := time.NewTimer(StreamLingerTimeout)
+	defer timer.Stop()
+	select {
+	case <-c.session.Context().Done():
+	case <-timer.C:
+	}
+	_ = c.session.CloseWithError(0, "")
+}
+
+// LocalAddr returns the local network address.
+func (c *StreamConn) LocalAddr() net.Addr {
+	return c.session.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *StreamConn) RemoteAddr() net.Addr {
+	return c.session.RemoteAddr()
+}
+
+// Path returns the path used to send to the remote, or an empty path if the
+// remote is in the local AS or the session is not over SCION.
+func (c *StreamConn) Path() spath.Path {
+	if raddr, ok := c.session.RemoteAddr().(*snet.UDPAddr); ok {
+		return raddr.Path
+	}
+	return spath.Path{}
+}
+
+// SetDeadline sets the read and write deadlines of the stream.
+func (c *StreamConn) SetDeadline(t time.Time) error {
+	return c.stream.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the stream.
+func (c *StreamConn) SetReadDeadline(t time.Time) error {
+	return c.stream.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of the stream.
+func (c *StreamConn) SetWriteDeadline(t time.Time) error {
+	return c.stream.SetWriteDeadline(t)
+}
+
+// StreamListener is a net.Listener, accepting a QUIC session with a single
+// stream for each connection.
+type StreamListener struct {
+	listener quic.Listener
+	conns    chan *StreamConn
+	errs     chan error
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+var _ net.Listener = (*StreamListener)(nil)
+
+// ListenStream listens for QUIC sessions on a SCION/UDP port, see ListenPort.
+// The tls.Config must contain a certificate, e.g. from GetDummyTLSCerts, and
+// define an application protocol.
+func ListenStream(port uint16, tlsConf *tls.Config, quicConf *quic.Config) (*StreamListener, error) {
+	listener, err := ListenPort(port, tlsConf, quicConf)
+	if err != nil {
+		return nil, err
+	}
+	return newStreamListener(listener), nil
+}
+
+func newStreamListener(listener quic.Listener) *StreamListener {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &StreamListener{
+		listener: listener,
+		conns:    make(chan *StreamConn),
+		errs:     make(chan error, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	go l.acceptSessions()
+	return l
+}
+
+// acceptSessions accepts new sessions and waits for the first stream in each
+// session concurrently, so that a slow client does not block the others.
+func (l *StreamListener) acceptSessions() {
+	for {
+		session, err := l.listener.Accept(l.ctx)
+		if err != nil {
+			l.errs <- err
+			return
+		}
+		go func() {
+			stream, err := session.AcceptStream(l.ctx)
+			if err != nil {
+				_ = session.CloseWithError(0, "")
+				return
+			}
+			select {
+			case l.conns <- NewStreamConn(session, stream):
+			case <-l.ctx.Done():
+				_ = session.CloseWithError(0, "")
+			}
+		}()
+	}
+}
+
+// Accept waits for and returns the next connection.
+func (l *StreamListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errs:
+		// keep returning the error on subsequent calls
+		l.errs <- err
+		return nil, err
+	case <-l.ctx.Done():
+		return nil, errListenerClosed
+	}
+}
+
+// Close stops listening. Already accepted connections are not closed.
+func (l *StreamListener) Close() error {
+	l.cancel()
+	return l.listener.Close()
+}
+
+// Addr returns the listener's network address.
+func (l *StreamListener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+var errListenerClosed = errors.New("listener closed")
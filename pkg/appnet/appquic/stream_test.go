@@ -0,0 +1,164 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appquic
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+const testProto = "appquic-test"
+
+// TestStreamConnEcho copies a few megabytes through an echo server and checks
+// that they arrive unmodified. As there is no SCION network in unit tests, the
+// QUIC sessions use plain UDP on loopback.
+func TestStreamConnEcho(t *testing.T) {
+	quicListener, err := quic.ListenAddr("127.0.0.1:0",
+		&tls.Config{Certificates: GetDummyTLSCerts(), NextProtos: []string{testProto}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := newStreamListener(quicListener)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		if _, err := io.Copy(conn, conn); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	session, err := quic.DialAddr(listener.Addr().String(),
+		&tls.Config{InsecureSkipVerify: true, NextProtos: []string{testProto}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := openStream(context.Background(), session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	data := make([]byte, 4<<20)
+	_, _ = rand.Read(data)
+	go func() {
+		if _, err := conn.Write(data); err != nil {
+			t.Error(err)
+		}
+		if err := conn.CloseWrite(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	received, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received, data) {
+		t.Errorf("received data differs from sent data, sent %d bytes, received %d bytes", len(data), len(received))
+	}
+}
+
+func TestStreamListenerClose(t *testing.T) {
+	quicListener, err := quic.ListenAddr("127.0.0.1:0",
+		&tls.Config{Certificates: GetDummyTLSCerts(), NextProtos: []string{testProto}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := newStreamListener(quicListener)
+	if err := listener.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listener.Accept(); err == nil {
+		t.Error("Accept on closed listener did not fail")
+	}
+}
+
+// tcpConnPair returns the two ends of a TCP connection on loopback.
+func tcpConnPair(t *testing.T) (*net.TCPConn, *net.TCPConn) {
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	client, err := net.DialTCP("tcp", nil, listener.Addr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := listener.AcceptTCP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+// TestPipeHalfClose checks that Pipe passes on the end of the data in one
+// direction, while the other direction remains open.
+func TestPipeHalfClose(t *testing.T) {
+	a, aPiped := tcpConnPair(t)
+	b, bPiped := tcpConnPair(t)
+	defer a.Close()
+	defer b.Close()
+	done := make(chan struct{})
+	go func() {
+		Pipe(aPiped, bPiped)
+		close(done)
+	}()
+
+	request := []byte("request")
+	if _, err := a.Write(request); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	received, err := ioutil.ReadAll(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received, request) {
+		t.Fatalf("expected %q, got %q", request, received)
+	}
+
+	// the other direction is still open after the EOF
+	response := []byte("response")
+	if _, err := b.Write(response); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	received, err = ioutil.ReadAll(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received, response) {
+		t.Fatalf("expected %q, got %q", response, received)
+	}
+	<-done
+}
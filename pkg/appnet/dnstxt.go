@@ -0,0 +1,97 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+const txtRecordPrefix = "scion="
+
+// dnsTXTResolver is an implementation of the resolver interface, looking up
+// DNS TXT records of the form "scion=ISD-AS,[IP]".
+type dnsTXTResolver struct {
+	// lookupTXT is used to query the TXT records for a name, net.LookupTXT if nil.
+	lookupTXT func(name string) ([]string, error)
+	// reachable is used to check whether there are paths to an ISD-AS,
+	// isReachable if nil.
+	reachable func(ia addr.IA) bool
+}
+
+// Resolve implements Resolver. If there are multiple SCION TXT records for
+// the name, the candidates are tried in the order of the records, and the
+// first one that contains a valid address in a reachable ISD-AS is used. If
+// none of the ISD-ASes is reachable, the first valid address is returned, so
+// that dialing it reports the error.
+func (r *dnsTXTResolver) Resolve(name string) (*snet.SCIONAddress, error) {
+	lookupTXT := r.lookupTXT
+	if lookupTXT == nil {
+		lookupTXT = net.LookupTXT
+	}
+	records, err := lookupTXT(name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, &HostNotFoundError{name}
+		}
+		return nil, err
+	}
+	addrs := parseTXTRecords(records)
+	if len(addrs) == 0 {
+		return nil, &HostNotFoundError{name}
+	}
+	if len(addrs) == 1 {
+		return &addrs[0], nil
+	}
+	reachable := r.reachable
+	if reachable == nil {
+		reachable = isReachable
+	}
+	for i := range addrs {
+		if reachable(addrs[i].IA) {
+			return &addrs[i], nil
+		}
+	}
+	return &addrs[0], nil
+}
+
+// isReachable returns true if ia is the local ISD-AS or if there are paths to
+// it.
+func isReachable(ia addr.IA) bool {
+	_, err := QueryPaths(ia)
+	return err == nil
+}
+
+// parseTXTRecords returns the valid SCION addresses in the "scion=" TXT
+// records, in the order of the records. Other records are ignored.
+func parseTXTRecords(records []string) []snet.SCIONAddress {
+	var addrs []snet.SCIONAddress
+	for _, record := range records {
+		if !strings.HasPrefix(record, txtRecordPrefix) {
+			continue
+		}
+		addr, err := addrFromString(strings.TrimSpace(strings.TrimPrefix(record, txtRecordPrefix)))
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/scionproto/scion/go/lib/addr"
+)
+
+func TestParseTXTRecords(t *testing.T) {
+	records := []string{
+		"v=spf1 -all",
+		"scion=17-ffaa:0:1,[192.168.1.1]",
+		"scion=not-an-address",
+		"scion=18-ffaa:1:2,[10.0.8.10]",
+		"scion= 19-ffaa:1:3,[::1] ",
+		"xscion=20-ffaa:1:4,[10.0.0.1]",
+	}
+	addrs := parseTXTRecords(records)
+	expected := []string{
+		"17-ffaa:0:1,[192.168.1.1]",
+		"18-ffaa:1:2,[10.0.8.10]",
+		"19-ffaa:1:3,[::1]",
+	}
+	if len(addrs) != len(expected) {
+		t.Fatalf("wrong number of addresses, expected: %v, got: %v", len(expected), addrs)
+	}
+	for i, e := range expected {
+		exp := mustParse(e)
+		if addrs[i].IA != exp.IA || !addrs[i].Host.Equal(exp.Host) {
+			t.Errorf("wrong address %d, expected %v, got %v", i, exp, addrs[i])
+		}
+	}
+}
+
+func TestDNSTXTResolver(t *testing.T) {
+	zone := map[string][]string{
+		"single.example.com": {"scion=17-ffaa:0:1,[192.168.1.1]"},
+		"multiple.example.com": {
+			"some other record",
+			"scion=garbage",
+			"scion=18-ffaa:1:2,[10.0.8.10]",
+			"scion=17-ffaa:0:1,[192.168.1.1]",
+		},
+		"fallthrough.example.com": {
+			"scion=19-ffaa:0:1,[10.0.0.1]",
+			"scion=17-ffaa:0:1,[192.168.1.1]",
+		},
+		"unreachable.example.com": {
+			"scion=19-ffaa:0:1,[10.0.0.1]",
+			"scion=20-ffaa:0:1,[10.0.0.2]",
+		},
+		"noscion.example.com": {"v=spf1 -all"},
+	}
+	resolver := &dnsTXTResolver{
+		lookupTXT: func(name string) ([]string, error) {
+			if records, ok := zone[name]; ok {
+				return records, nil
+			}
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		},
+		reachable: func(ia addr.IA) bool {
+			return ia.I == 17 || ia.I == 18
+		},
+	}
+
+	cases := []testCase{
+		{"single.example.com", mustParse("17-ffaa:0:1,[192.168.1.1]")},
+		{"multiple.example.com", mustParse("18-ffaa:1:2,[10.0.8.10]")},
+		{"fallthrough.example.com", mustParse("17-ffaa:0:1,[192.168.1.1]")},
+		{"unreachable.example.com", mustParse("19-ffaa:0:1,[10.0.0.1]")},
+		{"noscion.example.com", nil},
+		{"missing.example.com", nil},
+	}
+	testResolver(t, resolver, cases)
+}
+
+func TestDNSTXTResolverError(t *testing.T) {
+	lookupErr := errors.New("server misbehaving")
+	resolver := &dnsTXTResolver{
+		lookupTXT: func(name string) ([]string, error) {
+			return nil, lookupErr
+		},
+	}
+	_, err := resolver.Resolve("foo.example.com")
+	if !errors.Is(err, lookupErr) {
+		t.Errorf("expected lookup error, got %v", err)
+	}
+}
+
+func TestHostsfilePrecedesDNSTXT(t *testing.T) {
+	dns := &dnsTXTResolver{
+		lookupTXT: func(name string) ([]string, error) {
+			return []string{"scion=1-ff00:0:1,[192.0.2.1]"}, nil
+		},
+	}
+	resolver := ResolverList{
+		&hostsfileResolver{hostsTestFile},
+		dns,
+	}
+	cases := []testCase{
+		{"host2", mustParse("18-ffaa:1:2,[10.0.8.10]")},
+		{"other.example.com", mustParse("1-ff00:0:1,[192.0.2.1]")},
+	}
+	testResolver(t, resolver, cases)
+}
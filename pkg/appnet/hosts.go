@@ -19,48 +19,74 @@ import (
 	"net"
 	"regexp"
 	"strconv"
+	"strings"
 
-	"github.com/scionproto/scion/go/lib/addr"
 	"github.com/scionproto/scion/go/lib/snet"
 )
 
 var (
+	resolveAddressBook   Resolver = &addressBookResolver{addressBookPath}
 	resolveEtcHosts      Resolver = &hostsfileResolver{"/etc/hosts"}
 	resolveEtcScionHosts Resolver = &hostsfileResolver{"/etc/scion/hosts"}
 	resolveRains         Resolver = nil
+	resolveDNSTXT        Resolver = &dnsTXTResolver{}
 )
 
 var (
-	addrRegexp     = regexp.MustCompile(`^(\d+-[\d:A-Fa-f]+),\[([^\]]+)\]$`)
-	hostPortRegexp = regexp.MustCompile(`^((?:[-.\da-zA-Z]+)|(?:\d+-[\d:A-Fa-f]+,(\[[^\]]+\]|[^\]:]+))):(\d+)$`)
-)
-
-const (
-	addrRegexpIaIndex = 1
-	addrRegexpL3Index = 2
-
-	hostPortRegexpHostIndex = 1
-	hostPortRegexpPortIndex = 2
+	hostnamePortRegexp = regexp.MustCompile(`^([-.\da-zA-Z]+):(\d+)$`)
 )
 
 // SplitHostPort splits a host:port string into host and port variables.
 // This is analogous to net.SplitHostPort, which however refuses to handle SCION addresses.
-// The address can be of the form of a SCION address (i.e. of the form "ISD-AS,[IP]:port")
-// or in the form of "hostname:port".
+// The address can be of the form of a SCION address (i.e. of the form "ISD-AS,[IP]:port"),
+// in any of the forms accepted by ParseAddr, or in the form of "hostname:port".
+// The port is required.
 func SplitHostPort(hostport string) (host, port string, err error) {
-	match := hostPortRegexp.FindStringSubmatch(hostport)
+	if strings.ContainsRune(hostport, ',') {
+		return splitSCIONHostPort(hostport)
+	}
+	match := hostnamePortRegexp.FindStringSubmatch(hostport)
 	if match != nil {
-		return match[hostPortRegexpHostIndex], match[hostPortRegexpPortIndex], nil
+		return match[1], match[2], nil
 	}
 	return "", "", fmt.Errorf("appnet.SplitHostPort: invalid address")
 }
 
+// splitSCIONHostPort splits a SCION address at the last ':' and checks that
+// this is consistent with ParseAddr, i.e. that the part before it is the same
+// address without a port.
+func splitSCIONHostPort(hostport string) (host, port string, err error) {
+	a, err := ParseAddr(hostport)
+	if err != nil {
+		return "", "", err
+	}
+	colon := strings.LastIndexByte(hostport, ':')
+	if colon < 0 {
+		return "", "", fmt.Errorf("appnet.SplitHostPort: missing port in address %q", hostport)
+	}
+	host, port = hostport[:colon], hostport[colon+1:]
+	h, err := ParseAddr(host)
+	if err != nil || h.Port != 0 {
+		return "", "", fmt.Errorf("appnet.SplitHostPort: missing port in address %q", hostport)
+	}
+	h.Port = a.Port
+	if h.String() != a.String() {
+		return "", "", fmt.Errorf("appnet.SplitHostPort: missing port in address %q", hostport)
+	}
+	return host, port, nil
+}
+
 // ResolveUDPAddr parses the address and resolves the hostname.
 // The address can be of the form of a SCION address (i.e. of the form "ISD-AS,[IP]:port")
 // or in the form of "hostname:port".
 // If the address is in the form of a hostname, the DefaultResolver is used to
 // resolve the name.
+// The address can also be an alias from the user's address book, without
+// port if the port is included in the address book entry.
 func ResolveUDPAddr(address string) (*snet.UDPAddr, error) {
+	if raddr, ok, err := resolveAddressBookAlias(addressBookPath, address); ok || err != nil {
+		return raddr, err
+	}
 	return ResolveUDPAddrAt(address, DefaultResolver())
 }
 
@@ -70,9 +96,14 @@ func ResolveUDPAddr(address string) (*snet.UDPAddr, error) {
 // If the address is in the form of a hostname, resolver is used to resolve the name.
 func ResolveUDPAddrAt(address string, resolver Resolver) (*snet.UDPAddr, error) {
 
-	raddr, err := snet.ParseUDPAddr(address)
-	if err == nil {
-		return raddr, nil
+	// Hostnames never contain a ',', so anything that does is expected to be
+	// a SCION address.
+	if strings.ContainsRune(address, ',') {
+		a, err := ParseAddr(address)
+		if err != nil {
+			return nil, err
+		}
+		return a.UDPAddr()
 	}
 	hostStr, portStr, err := net.SplitHostPort(address)
 	if err != nil {
@@ -94,15 +125,19 @@ func ResolveUDPAddrAt(address string, resolver Resolver) (*snet.UDPAddr, error)
 // It will use the following sources, in the given order of precedence, to
 // resolve a name:
 //
+//  - the user's address book, ~/.scion/addressbook
 //  - /etc/hosts
 //  - /etc/scion/hosts
 //  - RAINS, if a server is configured in /etc/scion/rains.cfg.
 //    Disabled if built with !norains.
+//  - DNS TXT records of the form "scion=ISD-AS,[IP]"
 func DefaultResolver() Resolver {
 	return ResolverList{
+		resolveAddressBook,
 		resolveEtcHosts,
 		resolveEtcScionHosts,
 		resolveRains,
+		resolveDNSTXT,
 	}
 }
 
@@ -110,16 +145,21 @@ func DefaultResolver() Resolver {
 // safely used in the host part of a URL.
 func MangleSCIONAddr(address string) string {
 
-	raddr, err := snet.ParseUDPAddr(address)
+	raddr, err := ParseUDPAddr(address)
 	if err != nil {
 		return address
 	}
 
 	// Turn this into [IA,IP]:port format. This is a valid host in a URI, as per
 	// the "IP-literal" case in RFC 3986, §3.2.2.
-	// Unfortunately, this is not currently compatible with snet.ParseUDPAddr,
+	// Unfortunately, this is not currently compatible with ParseUDPAddr,
 	// so this will have to be _unmangled_ before use.
-	mangledAddr := fmt.Sprintf("[%s,%s]", raddr.IA, raddr.Host.IP)
+	// A zone is escaped as in RFC 6874, so that net/url accepts it.
+	host := raddr.Host.IP.String()
+	if raddr.Host.Zone != "" {
+		host += "%25" + raddr.Host.Zone
+	}
+	mangledAddr := fmt.Sprintf("[%s,%s]", raddr.IA, host)
 	if raddr.Host.Port != 0 {
 		mangledAddr += fmt.Sprintf(":%d", raddr.Host.Port)
 	}
@@ -127,7 +167,7 @@ func MangleSCIONAddr(address string) string {
 }
 
 // UnmangleSCIONAddr returns a SCION address that can be parsed with
-// with snet.ParseUDPAddr.
+// with ParseUDPAddr.
 // If the input is not a SCION address (e.g. a hostname), the address is
 // returned unchanged.
 // This parses the address, so that it can safely join host and port, with the
@@ -142,7 +182,7 @@ func UnmangleSCIONAddr(address string) string {
 	}
 	// brackets are removed from [I-A,IP] part by SplitHostPort, so this can be
 	// parsed with ParseUDPAddr:
-	udpAddr, err := snet.ParseUDPAddr(host)
+	udpAddr, err := ParseUDPAddr(host)
 	if err != nil {
 		return address
 	}
@@ -154,28 +194,15 @@ func UnmangleSCIONAddr(address string) string {
 	return udpAddr.String()
 }
 
-// addrFromString parses a string to a snet.SCIONAddress
-// XXX(matzf) this would optimally be part of snet
+// addrFromString parses a string to a snet.SCIONAddress.
+// The address must not contain a port.
 func addrFromString(address string) (snet.SCIONAddress, error) {
-
-	parts := addrRegexp.FindStringSubmatch(address)
-	if parts == nil {
-		return snet.SCIONAddress{}, fmt.Errorf("no valid SCION address: %q", address)
-	}
-	ia, err := addr.IAFromString(parts[addrRegexpIaIndex])
+	a, err := ParseAddr(address)
 	if err != nil {
-		return snet.SCIONAddress{},
-			fmt.Errorf("invalid IA string: %v", parts[addrRegexpIaIndex])
-	}
-	var l3 addr.HostAddr
-	if hostSVC := addr.HostSVCFromString(parts[addrRegexpL3Index]); hostSVC != addr.SvcNone {
-		l3 = hostSVC
-	} else {
-		l3 = addr.HostFromIPStr(parts[addrRegexpL3Index])
-		if l3 == nil {
-			return snet.SCIONAddress{},
-				fmt.Errorf("invalid IP address string: %v", parts[addrRegexpL3Index])
-		}
+		return snet.SCIONAddress{}, err
+	}
+	if a.Port != 0 {
+		return snet.SCIONAddress{}, fmt.Errorf("unexpected port in address %q", address)
 	}
-	return snet.SCIONAddress{IA: ia, Host: l3}, nil
+	return a.SCIONAddress(), nil
 }
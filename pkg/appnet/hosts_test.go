@@ -15,6 +15,7 @@
 package appnet
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -29,6 +30,8 @@ func TestSplitHostPort(t *testing.T) {
 		{"1-ff00:0:0,[1.1.1.1]:80", "1-ff00:0:0,[1.1.1.1]", "80", false},
 		{"1-ff00:0:0,1.1.1.1:80", "1-ff00:0:0,1.1.1.1", "80", false},
 		{"1-ff00:0:0,[::]:80", "1-ff00:0:0,[::]", "80", false},
+		{"1-ff00:0:0,[fe80::1%eth0]:80", "1-ff00:0:0,[fe80::1%eth0]", "80", false},
+		{"1-ff00:0:0,[CS]:80", "1-ff00:0:0,[CS]", "80", false},
 		{"foo:80", "foo", "80", false},
 		{"www.example.com:666", "www.example.com", "666", false},
 		{"1-ff00:0:0,0:0:0:80", "", "", true},
@@ -37,6 +40,9 @@ func TestSplitHostPort(t *testing.T) {
 		{"1-ff00:0:0,1.1.1.1", "", "", true},
 		{"1-ff00:0:0,[::]", "", "", true},
 		{"foo", "", "", true},
+		{"1-ff00:0:0,fe80::1%eth0:80", "", "", true},
+		{"1-ff00:0:0,[1.1.1.1]:", "", "", true},
+		{"1-ff00:0:0,[1.1.1.1]:http", "", "", true},
 	}
 	for _, c := range cases {
 		host, port, err := SplitHostPort(c.input)
@@ -51,3 +57,33 @@ func TestSplitHostPort(t *testing.T) {
 		}
 	}
 }
+
+func TestMangleSCIONAddr(t *testing.T) {
+	cases := []struct {
+		input     string
+		mangled   string
+		unmangled string
+	}{
+		{"foo:80", "foo:80", ""},
+		{"1-ff00:0:110,127.0.0.1:80", "[1-ff00:0:110,127.0.0.1]:80", "1-ff00:0:110,127.0.0.1:80"},
+		{"1-ff00:0:110,[::1]:80", "[1-ff00:0:110,::1]:80", "1-ff00:0:110,[::1]:80"},
+		{"1-ff00:0:110,[fe80::1%eth0]:80", "[1-ff00:0:110,fe80::1%25eth0]:80", "1-ff00:0:110,[fe80::1%eth0]:80"},
+		{"1-ff00:0:110,fe80::1%eth0", "[1-ff00:0:110,fe80::1%25eth0]", ""},
+		{"1-ff00:0:110,CS:80", "1-ff00:0:110,CS:80", ""},
+	}
+	for _, c := range cases {
+		mangled := MangleSCIONAddr(c.input)
+		if mangled != c.mangled {
+			t.Errorf("%q: expected mangled %q, got %q", c.input, c.mangled, mangled)
+			continue
+		}
+		if c.unmangled == "" {
+			continue
+		}
+		// net/url unescapes the zone in the host of a parsed URL
+		host := strings.Replace(mangled, "%25", "%", 1)
+		if unmangled := UnmangleSCIONAddr(host); unmangled != c.unmangled {
+			t.Errorf("%q: expected unmangled %q, got %q", c.input, c.unmangled, unmangled)
+		}
+	}
+}
@@ -79,16 +79,14 @@ func parseHostsFile(file *os.File) (hostsTable, error) {
 		if len(fields) == 0 {
 			continue
 		}
-		if addrRegexp.MatchString(fields[0]) {
-			addr, err := addrFromString(fields[0])
-			if err != nil {
-				continue
-			}
+		addr, err := addrFromString(fields[0])
+		if err != nil {
+			continue
+		}
 
-			// map hostnames to scionAddress
-			for _, name := range fields[1:] {
-				hosts[name] = addr
-			}
+		// map hostnames to scionAddress
+		for _, name := range fields[1:] {
+			hosts[name] = addr
 		}
 	}
 	return hosts, scanner.Err()
@@ -0,0 +1,62 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+// PathsEqual returns true if the paths traverse the same sequence of
+// interfaces. The raw forwarding path, including the hop field MACs, and the
+// expiry are ignored.
+// A nil path denotes the empty path within the local AS.
+func PathsEqual(a, b snet.Path) bool {
+	return fingerprint(a) == fingerprint(b)
+}
+
+// DiffPaths compares two sets of paths, as for example returned by successive
+// calls to QueryPaths. It returns the paths in newPaths that have no equal
+// path in oldPaths, and the paths in oldPaths that have no equal path in
+// newPaths, see PathsEqual. Out of several equal paths, only the first is
+// reported. The order of the paths is preserved.
+func DiffPaths(oldPaths, newPaths []snet.Path) (added, removed []snet.Path) {
+	return pathsNotIn(newPaths, oldPaths), pathsNotIn(oldPaths, newPaths)
+}
+
+// pathsNotIn returns the paths in a without an equal path in b, without
+// duplicates.
+func pathsNotIn(a, b []snet.Path) []snet.Path {
+	seen := make(map[snet.PathFingerprint]bool, len(b))
+	for _, p := range b {
+		seen[fingerprint(p)] = true
+	}
+	var result []snet.Path
+	for _, p := range a {
+		f := fingerprint(p)
+		if !seen[f] {
+			seen[f] = true
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// fingerprint is snet.Fingerprint, extended to the nil path.
+func fingerprint(p snet.Path) snet.PathFingerprint {
+	if p == nil {
+		return ""
+	}
+	return snet.Fingerprint(p)
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/snet"
+	snetpath "github.com/scionproto/scion/go/lib/snet/path"
+	"github.com/scionproto/scion/go/lib/spath"
+)
+
+func TestPathsEqual(t *testing.T) {
+	viaB := testPath("1-ff00:0:1", 1, "1-ff00:0:2", 1, "1-ff00:0:2", 2, "1-ff00:0:3", 1)
+	viaC := testPath("1-ff00:0:1", 3, "1-ff00:0:4", 1, "1-ff00:0:4", 2, "1-ff00:0:3", 2)
+	// same interfaces as viaB, but different raw path and expiry
+	viaBRefreshed := snetpath.Path{
+		SPath: spath.Path{Raw: []byte{1, 2, 3, 4}},
+		Meta: snet.PathMetadata{
+			Interfaces: viaB.Metadata().Interfaces,
+			Expiry:     time.Now().Add(time.Hour),
+		},
+	}
+	empty := snetpath.Path{}
+
+	cases := []struct {
+		name     string
+		a, b     snet.Path
+		expected bool
+	}{
+		{"same", viaB, viaB, true},
+		{"refreshed", viaB, viaBRefreshed, true},
+		{"different", viaB, viaC, false},
+		{"nil", nil, nil, true},
+		{"nil and empty", nil, empty, true},
+		{"nil and non-empty", nil, viaB, false},
+	}
+	for _, c := range cases {
+		if actual := PathsEqual(c.a, c.b); actual != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, actual)
+		}
+		if actual := PathsEqual(c.b, c.a); actual != c.expected {
+			t.Errorf("%s (swapped): expected %v, got %v", c.name, c.expected, actual)
+		}
+	}
+}
+
+func TestDiffPaths(t *testing.T) {
+	a := testPath("1-ff00:0:1", 1, "1-ff00:0:2", 1)
+	b := testPath("1-ff00:0:1", 2, "1-ff00:0:3", 1)
+	c := testPath("1-ff00:0:1", 3, "1-ff00:0:4", 1)
+	d := testPath("1-ff00:0:1", 4, "1-ff00:0:5", 1)
+
+	cases := []struct {
+		name           string
+		old, new       []snet.Path
+		added, removed []snet.Path
+	}{
+		{"empty", nil, nil, nil, nil},
+		{"identical", []snet.Path{a, b}, []snet.Path{b, a}, nil, nil},
+		{"appeared", nil, []snet.Path{a, b}, []snet.Path{a, b}, nil},
+		{"disappeared", []snet.Path{a, b}, nil, nil, []snet.Path{a, b}},
+		{"disjoint", []snet.Path{a, b}, []snet.Path{c, d}, []snet.Path{c, d}, []snet.Path{a, b}},
+		{"overlap", []snet.Path{a, b, c}, []snet.Path{b, c, d}, []snet.Path{d}, []snet.Path{a}},
+		{"duplicates", []snet.Path{a, a, b}, []snet.Path{b, c, c}, []snet.Path{c}, []snet.Path{a}},
+		{"intra-AS", []snet.Path{nil}, []snet.Path{snetpath.Path{}, a}, []snet.Path{a}, nil},
+	}
+	check := func(name string, actual, expected []snet.Path) {
+		if len(actual) != len(expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, actual)
+			return
+		}
+		for i := range actual {
+			if !PathsEqual(actual[i], expected[i]) {
+				t.Errorf("%s: path %d, expected %v, got %v", name, i, expected[i], actual[i])
+			}
+		}
+	}
+	for _, tc := range cases {
+		added, removed := DiffPaths(tc.old, tc.new)
+		check(tc.name+" added", added, tc.added)
+		check(tc.name+" removed", removed, tc.removed)
+	}
+}
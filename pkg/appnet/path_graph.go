@@ -0,0 +1,131 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/scionproto/scion/go/lib/common"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+// pathGraphColors are the colors of the paths in the DOT graph, reused
+// cyclically.
+var pathGraphColors = []string{
+	"blue", "red", "green", "orange", "purple", "brown", "cyan", "magenta",
+}
+
+// PathGraph is the graph formed by a set of paths, where the nodes are the
+// ASes and the edges are the links between interfaces traversed by the paths.
+// It can be written in Graphviz DOT format, or encoded as JSON.
+type PathGraph struct {
+	// Nodes are the ISD-ASes, in the order in which they first occur on the
+	// paths.
+	Nodes []string `json:"nodes"`
+	// Edges are the links, in the order in which they first occur on the
+	// paths. A link traversed by several paths occurs only once.
+	Edges []PathGraphEdge `json:"edges"`
+}
+
+// PathGraphEdge is a link between the egress interface of one AS and the
+// ingress interface of the next AS on a path.
+type PathGraphEdge struct {
+	From   string          `json:"from"`
+	FromIF common.IFIDType `json:"from_if"`
+	To     string          `json:"to"`
+	ToIF   common.IFIDType `json:"to_if"`
+	// Paths are the indices of the paths traversing this link.
+	Paths []int `json:"paths"`
+}
+
+// NewPathGraph returns the graph formed by the paths. Paths without interfaces,
+// i.e. nil or the empty path within the local AS, contribute no nodes or edges.
+func NewPathGraph(paths []snet.Path) *PathGraph {
+	g := &PathGraph{Nodes: []string{}, Edges: []PathGraphEdge{}}
+	nodes := make(map[string]bool)
+	addNode := func(ia string) {
+		if !nodes[ia] {
+			nodes[ia] = true
+			g.Nodes = append(g.Nodes, ia)
+		}
+	}
+	edges := make(map[pathGraphLink]int) // index in g.Edges
+	for i, p := range paths {
+		if p == nil || p.Metadata() == nil {
+			continue
+		}
+		intfs := p.Metadata().Interfaces
+		for k := 0; k+1 < len(intfs); k += 2 {
+			from, to := intfs[k], intfs[k+1]
+			addNode(from.IA.String())
+			addNode(to.IA.String())
+			link := pathGraphLink{from, to}
+			e, ok := edges[link]
+			if !ok {
+				e, ok = edges[pathGraphLink{to, from}]
+			}
+			if !ok {
+				e = len(g.Edges)
+				edges[link] = e
+				g.Edges = append(g.Edges, PathGraphEdge{
+					From:   from.IA.String(),
+					FromIF: from.ID,
+					To:     to.IA.String(),
+					ToIF:   to.ID,
+				})
+			}
+			if ps := g.Edges[e].Paths; len(ps) == 0 || ps[len(ps)-1] != i {
+				g.Edges[e].Paths = append(ps, i)
+			}
+		}
+	}
+	return g
+}
+
+// pathGraphLink identifies a link by the interfaces at its ends. A link
+// traversed in the opposite direction is the same link, with swapped ends.
+type pathGraphLink struct {
+	from, to snet.PathInterface
+}
+
+// WriteDOT writes the graph in Graphviz DOT format. The edges are labeled with
+// the interface IDs and colored by the paths traversing them; the edges of a
+// link shared by several paths are drawn in parallel, one color per path.
+func (g *PathGraph) WriteDOT(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "digraph paths {")
+	fmt.Fprintln(b, "  node [shape=box];")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(b, "  %q;\n", n)
+	}
+	for _, e := range g.Edges {
+		colors := make([]string, len(e.Paths))
+		names := make([]string, len(e.Paths))
+		for i, p := range e.Paths {
+			colors[i] = pathGraphColors[p%len(pathGraphColors)]
+			names[i] = fmt.Sprintf("%d", p)
+		}
+		fmt.Fprintf(b, "  %q -> %q [label=%q, color=%q, tooltip=%q];\n",
+			e.From, e.To,
+			fmt.Sprintf("%d>%d", e.FromIF, e.ToIF),
+			strings.Join(colors, ":"),
+			"paths "+strings.Join(names, ", "))
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+func TestPathGraph(t *testing.T) {
+	// Paths from 1-ff00:0:1 to 1-ff00:0:3; the first two share the link
+	// from 1-ff00:0:2 to 1-ff00:0:3.
+	viaB1 := testPath("1-ff00:0:1", 1, "1-ff00:0:2", 1, "1-ff00:0:2", 2, "1-ff00:0:3", 1)
+	viaB2 := testPath("1-ff00:0:1", 2, "1-ff00:0:2", 3, "1-ff00:0:2", 2, "1-ff00:0:3", 1)
+	viaC := testPath("1-ff00:0:1", 3, "1-ff00:0:4", 1, "1-ff00:0:4", 2, "1-ff00:0:3", 2)
+	paths := []snet.Path{viaB1, viaB2, viaC, nil}
+
+	g := NewPathGraph(paths)
+	expectedNodes := []string{"1-ff00:0:1", "1-ff00:0:2", "1-ff00:0:3", "1-ff00:0:4"}
+	if !reflect.DeepEqual(g.Nodes, expectedNodes) {
+		t.Errorf("expected nodes %v, got %v", expectedNodes, g.Nodes)
+	}
+	expectedEdges := []PathGraphEdge{
+		{"1-ff00:0:1", 1, "1-ff00:0:2", 1, []int{0}},
+		{"1-ff00:0:2", 2, "1-ff00:0:3", 1, []int{0, 1}},
+		{"1-ff00:0:1", 2, "1-ff00:0:2", 3, []int{1}},
+		{"1-ff00:0:1", 3, "1-ff00:0:4", 1, []int{2}},
+		{"1-ff00:0:4", 2, "1-ff00:0:3", 2, []int{2}},
+	}
+	if !reflect.DeepEqual(g.Edges, expectedEdges) {
+		t.Errorf("expected edges %v, got %v", expectedEdges, g.Edges)
+	}
+
+	var dot bytes.Buffer
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	out := dot.String()
+	for _, expected := range []string{
+		"digraph paths {",
+		`"1-ff00:0:4";`,
+		`"1-ff00:0:1" -> "1-ff00:0:2" [label="1>1", color="blue"`,
+		`"1-ff00:0:2" -> "1-ff00:0:3" [label="2>1", color="blue:red"`,
+		`"1-ff00:0:4" -> "1-ff00:0:3" [label="2>2", color="green"`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected DOT output to contain %q, got:\n%s", expected, out)
+		}
+	}
+	if n := strings.Count(out, "->"); n != len(expectedEdges) {
+		t.Errorf("expected %d edges in DOT output, got %d", len(expectedEdges), n)
+	}
+
+	encoded, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded PathGraph
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, g) {
+		t.Errorf("JSON round trip: expected %v, got %v (%s)", g, decoded, encoded)
+	}
+}
+
+func TestPathGraphEmpty(t *testing.T) {
+	g := NewPathGraph(nil)
+	encoded, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `{"nodes":[],"edges":[]}` {
+		t.Errorf("unexpected JSON for empty graph: %s", encoded)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	log "github.com/inconshreveable/log15"
+
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+// PathWeights are the weights of the path properties considered by
+// ScorePaths. Only the ratio between the weights matters; a weight of 0
+// ignores the corresponding property.
+type PathWeights struct {
+	Latency   float64 // low total latency
+	Bandwidth float64 // high bottleneck bandwidth
+	Hops      float64 // low number of AS hops
+}
+
+// ChoosePathByWeights chooses the path with the highest score according to
+// ScorePaths. The paths are queried and scored on every call, so this always
+// reflects the current paths and weights.
+// If the remote address is in the local IA, return (nil, nil).
+func ChoosePathByWeights(dst addr.IA, weights PathWeights) (snet.Path, error) {
+
+	paths, err := QueryPaths(dst)
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+	return SelectPathByWeights(paths, weights), nil
+}
+
+// SelectPathByWeights returns the path with the highest score according to
+// ScorePaths. Of paths with equal score, the first one is returned.
+// Returns nil if paths is empty.
+func SelectPathByWeights(paths []snet.Path, weights PathWeights) snet.Path {
+	var selectedPath snet.Path
+	best := math.Inf(-1)
+	for i, score := range ScorePaths(paths, weights) {
+		if score > best {
+			selectedPath, best = paths[i], score
+		}
+	}
+	if selectedPath != nil {
+		log.Debug("Path selection by weights", "path", fmt.Sprintf("%s", selectedPath), "score", best)
+	}
+	return selectedPath
+}
+
+// ScorePaths returns a score in [0, 1] for each path, larger is better.
+// The score is the weighted average of the latency, bandwidth and hop count of
+// the path, each normalized to [0, 1] relative to the other paths.
+// The latency is only known if it is announced for all hops of the path, and
+// likewise for the bandwidth. If a property is not known for a path, the
+// median of the normalized values of the other paths is used, so that
+// missing metadata neither favours nor disqualifies a path.
+func ScorePaths(paths []snet.Path, weights PathWeights) []float64 {
+	latency := make([]float64, len(paths))
+	bandwidth := make([]float64, len(paths))
+	hops := make([]float64, len(paths))
+	for i, p := range paths {
+		md := p.Metadata()
+		latency[i] = totalLatency(md)
+		bandwidth[i] = bottleneckBandwidth(md)
+		hops[i] = hopCount(md)
+	}
+	normLatency := normalizeMetric(latency, false)
+	normBandwidth := normalizeMetric(bandwidth, true)
+	normHops := normalizeMetric(hops, false)
+
+	sum := weights.Latency + weights.Bandwidth + weights.Hops
+	scores := make([]float64, len(paths))
+	if sum <= 0 {
+		return scores
+	}
+	for i := range paths {
+		scores[i] = (weights.Latency*normLatency[i] +
+			weights.Bandwidth*normBandwidth[i] +
+			weights.Hops*normHops[i]) / sum
+	}
+	return scores
+}
+
+// totalLatency returns the sum of the hop latencies in seconds, or NaN if the
+// latency is not known for all hops.
+func totalLatency(md *snet.PathMetadata) float64 {
+	if md == nil || len(md.Latency) == 0 {
+		return math.NaN()
+	}
+	var total time.Duration
+	for _, l := range md.Latency {
+		if l < 0 {
+			return math.NaN()
+		}
+		total += l
+	}
+	return total.Seconds()
+}
+
+// hopCount returns the number of AS hops, or NaN if the path has no metadata.
+func hopCount(md *snet.PathMetadata) float64 {
+	if md == nil {
+		return math.NaN()
+	}
+	return float64(len(md.Interfaces) / 2)
+}
+
+// bottleneckBandwidth returns the minimum of the hop bandwidths in Kbit/s, or
+// NaN if the bandwidth is not known for all hops.
+func bottleneckBandwidth(md *snet.PathMetadata) float64 {
+	if md == nil || len(md.Bandwidth) == 0 {
+		return math.NaN()
+	}
+	bottleneck := uint64(math.MaxUint64)
+	for _, b := range md.Bandwidth {
+		if b == 0 {
+			return math.NaN()
+		}
+		if b < bottleneck {
+			bottleneck = b
+		}
+	}
+	return float64(bottleneck)
+}
+
+// normalizeMetric maps the values linearly to [0, 1], where 1 is the best
+// value, i.e. the largest if largerIsBetter and the smallest otherwise.
+// Unknown values (NaN) are replaced by the median of the known normalized
+// values, or 0.5 if no value is known. If all known values are equal, they
+// are mapped to 1.
+func normalizeMetric(values []float64, largerIsBetter bool) []float64 {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+		}
+	}
+
+	normalized := make([]float64, len(values))
+	var known []float64
+	for i, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		n := 1.0
+		if max > min {
+			n = (v - min) / (max - min)
+			if !largerIsBetter {
+				n = 1 - n
+			}
+		}
+		normalized[i] = n
+		known = append(known, n)
+	}
+
+	neutral := 0.5
+	if len(known) > 0 {
+		sort.Float64s(known)
+		mid := len(known) / 2
+		if len(known)%2 == 1 {
+			neutral = known[mid]
+		} else {
+			neutral = (known[mid-1] + known[mid]) / 2
+		}
+	}
+	for i, v := range values {
+		if math.IsNaN(v) {
+			normalized[i] = neutral
+		}
+	}
+	return normalized
+}
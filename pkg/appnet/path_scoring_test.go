@@ -0,0 +1,165 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/snet"
+	snetpath "github.com/scionproto/scion/go/lib/snet/path"
+)
+
+// metadataPath returns a path with the given number of AS hops, and with the
+// given per-hop latencies and bandwidths (nil for unknown). The id is stored
+// in the MTU field, to identify the path in the tests.
+func metadataPath(id uint16, hops int, latency []time.Duration, bandwidth []uint64) snet.Path {
+	return snetpath.Path{
+		Meta: snet.PathMetadata{
+			MTU:        id,
+			Interfaces: make([]snet.PathInterface, 2*hops),
+			Latency:    latency,
+			Bandwidth:  bandwidth,
+		},
+	}
+}
+
+// noMetadataPath is a path for which no metadata is available. The embedded
+// path is aliased, as a field named Path would shadow the Path method.
+type noMetadataPath struct {
+	basePath
+}
+
+type basePath = snet.Path
+
+func (p noMetadataPath) Metadata() *snet.PathMetadata {
+	return nil
+}
+
+func TestScorePaths(t *testing.T) {
+	ms := time.Millisecond
+	fast := metadataPath(1, 4, []time.Duration{5 * ms, 5 * ms, 5 * ms}, []uint64{1000, 1000, 1000})
+	wide := metadataPath(2, 3, []time.Duration{50 * ms, 50 * ms}, []uint64{100000, 100000})
+	short := metadataPath(3, 1, []time.Duration{30 * ms}, []uint64{10000})
+	paths := []snet.Path{fast, wide, short}
+
+	cases := []struct {
+		name     string
+		weights  PathWeights
+		expected snet.Path
+	}{
+		{"latency", PathWeights{Latency: 1}, fast},
+		{"bandwidth", PathWeights{Bandwidth: 1}, wide},
+		{"hops", PathWeights{Hops: 1}, short},
+		{"latency and hops", PathWeights{Latency: 1, Hops: 1}, short},
+		{"latency over bandwidth", PathWeights{Latency: 3, Bandwidth: 1}, fast},
+		{"bandwidth over latency", PathWeights{Latency: 1, Bandwidth: 3}, wide},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := SelectPathByWeights(paths, c.weights)
+			if actual == nil || actual.Metadata().MTU != c.expected.Metadata().MTU {
+				t.Errorf("expected path %d, got %v (scores %v)",
+					c.expected.Metadata().MTU, actual, ScorePaths(paths, c.weights))
+			}
+		})
+	}
+}
+
+func TestScorePathsRange(t *testing.T) {
+	paths := []snet.Path{
+		metadataPath(4, 2, []time.Duration{time.Millisecond}, []uint64{100}),
+		metadataPath(5, 5, []time.Duration{time.Second}, []uint64{1}),
+	}
+	scores := ScorePaths(paths, PathWeights{Latency: 1, Bandwidth: 1, Hops: 1})
+	if scores[0] != 1 || scores[1] != 0 {
+		t.Errorf("expected scores [1 0], got %v", scores)
+	}
+	scores = ScorePaths(paths, PathWeights{})
+	if scores[0] != 0 || scores[1] != 0 {
+		t.Errorf("expected scores [0 0] for zero weights, got %v", scores)
+	}
+}
+
+func TestScorePathsMissingMetadata(t *testing.T) {
+	ms := time.Millisecond
+	paths := []snet.Path{
+		metadataPath(6, 2, []time.Duration{10 * ms}, nil),
+		metadataPath(7, 2, []time.Duration{20 * ms}, nil),
+		metadataPath(8, 2, []time.Duration{30 * ms}, nil),
+		// latency not announced for one hop
+		metadataPath(9, 2, []time.Duration{snet.LatencyUnset}, nil),
+	}
+	scores := ScorePaths(paths, PathWeights{Latency: 1, Bandwidth: 1})
+	// the unknown latency gets the median normalized latency, i.e. that of
+	// the 20ms path; the bandwidth is unknown for all paths and neutral.
+	if math.Abs(scores[3]-scores[1]) > 1e-9 {
+		t.Errorf("expected neutral score %v for path with missing latency, got %v", scores[1], scores[3])
+	}
+	if !(scores[0] > scores[1] && scores[1] > scores[2]) {
+		t.Errorf("expected scores to decrease with latency, got %v", scores)
+	}
+	if SelectPathByWeights(paths, PathWeights{Latency: 1}).Metadata().MTU != 6 {
+		t.Errorf("expected lowest latency path to be selected")
+	}
+}
+
+func TestNormalizeMetric(t *testing.T) {
+	nan := math.NaN()
+	cases := []struct {
+		values         []float64
+		largerIsBetter bool
+		expected       []float64
+	}{
+		{[]float64{1, 2, 3}, true, []float64{0, 0.5, 1}},
+		{[]float64{1, 2, 3}, false, []float64{1, 0.5, 0}},
+		{[]float64{2, 2}, false, []float64{1, 1}},
+		{[]float64{nan, nan}, true, []float64{0.5, 0.5}},
+		{[]float64{0, nan, 10}, true, []float64{0, 0.5, 1}},
+		{[]float64{0, nan, 5, 10}, true, []float64{0, 0.5, 0.5, 1}},
+		{[]float64{}, true, []float64{}},
+	}
+	for _, c := range cases {
+		actual := normalizeMetric(c.values, c.largerIsBetter)
+		if len(actual) != len(c.expected) {
+			t.Fatalf("%v: expected %v, got %v", c.values, c.expected, actual)
+		}
+		for i := range actual {
+			if math.Abs(actual[i]-c.expected[i]) > 1e-9 {
+				t.Errorf("%v: expected %v, got %v", c.values, c.expected, actual)
+				break
+			}
+		}
+	}
+}
+
+func TestScorePathsNilMetadata(t *testing.T) {
+	paths := []snet.Path{
+		metadataPath(10, 1, nil, nil),
+		metadataPath(11, 3, nil, nil),
+		metadataPath(12, 5, nil, nil),
+		noMetadataPath{metadataPath(13, 1, nil, nil)},
+	}
+	scores := ScorePaths(paths, PathWeights{Latency: 1, Bandwidth: 1, Hops: 1})
+	// the hop count of the path without metadata is unknown and gets the
+	// median normalized hop count, i.e. that of the 3 hop path.
+	if math.Abs(scores[3]-scores[1]) > 1e-9 {
+		t.Errorf("expected neutral score %v for path without metadata, got %v", scores[1], scores[3])
+	}
+	if SelectPathByWeights(paths, PathWeights{Hops: 1}).Metadata().MTU != 10 {
+		t.Errorf("expected shortest path to be selected")
+	}
+}
@@ -23,11 +23,14 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bclicn/color"
 	log "github.com/inconshreveable/log15"
 
 	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/common"
 	"github.com/scionproto/scion/go/lib/snet"
 	"github.com/scionproto/scion/go/lib/spath"
 )
@@ -37,8 +40,25 @@ const (
 	PathAlgoDefault = iota // default algorithm
 	MTU                    // metric for path with biggest MTU
 	Shortest               // metric for shortest path
+	// Deterministic always selects the same path out of the same set of
+	// candidates; see SelectPathDeterministic. Intended for testing only.
+	Deterministic
 )
 
+// PathAlgoMetric returns the metric for the path selection algorithm with the
+// given name, "shortest" or "mtu", as used in command line flags. Any other
+// name selects the default algorithm.
+func PathAlgoMetric(pathAlgo string) int {
+	switch pathAlgo {
+	case "shortest":
+		return Shortest
+	case "mtu":
+		return MTU
+	default:
+		return PathAlgoDefault
+	}
+}
+
 // ChoosePathInteractive presents the user a selection of paths to choose from.
 // If the remote address is in the local IA, return (nil, nil), without prompting the user.
 func ChoosePathInteractive(dst addr.IA) (snet.Path, error) {
@@ -82,6 +102,65 @@ func ChoosePathByMetric(pathAlgo int, dst addr.IA) (snet.Path, error) {
 	return pathSelection(paths, pathAlgo), nil
 }
 
+// ChoosePathByMetricFrom is like ChoosePathByMetric, but prefers paths leaving
+// the local AS over the interface egress; see PreferFirstInterface.
+// If the remote address is in the local IA, return (nil, nil).
+func ChoosePathByMetricFrom(pathAlgo int, dst addr.IA, egress common.IFIDType) (snet.Path, error) {
+
+	paths, err := QueryPaths(dst)
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+	return pathSelection(PreferFirstInterface(paths, egress), pathAlgo), nil
+}
+
+// FilterPathsByFirstInterface returns the paths leaving the local AS over the
+// interface egress.
+func FilterPathsByFirstInterface(paths []snet.Path, egress common.IFIDType) []snet.Path {
+	var filtered []snet.Path
+	for _, p := range paths {
+		intfs := p.Metadata().Interfaces
+		if len(intfs) > 0 && intfs[0].ID == egress {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// PreferFirstInterface returns the paths, where out of the paths with the same
+// sequence of ASes, only the ones leaving the local AS over the interface
+// egress are kept, if there are any. Paths through a sequence of ASes that
+// cannot be reached over egress are kept unchanged.
+// The order of the paths is preserved.
+func PreferFirstInterface(paths []snet.Path, egress common.IFIDType) []snet.Path {
+	hasEgress := make(map[string]bool)
+	for _, p := range FilterPathsByFirstInterface(paths, egress) {
+		hasEgress[asSequence(p)] = true
+	}
+	var filtered []snet.Path
+	for _, p := range paths {
+		intfs := p.Metadata().Interfaces
+		if !hasEgress[asSequence(p)] || intfs[0].ID == egress {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// asSequence returns a string representation of the sequence of ASes on the path.
+func asSequence(p snet.Path) string {
+	var b strings.Builder
+	var last addr.IA
+	for i, intf := range p.Metadata().Interfaces {
+		if i == 0 || intf.IA != last {
+			b.WriteString(intf.IA.String())
+			b.WriteByte(' ')
+			last = intf.IA
+		}
+	}
+	return b.String()
+}
+
 // SetPath is a helper function to set the path on an snet.UDPAddr
 func SetPath(addr *snet.UDPAddr, path snet.Path) {
 	if path == nil {
@@ -104,6 +183,51 @@ func SetDefaultPath(addr *snet.UDPAddr) error {
 	return nil
 }
 
+var (
+	// ErrPathExpired is returned by DialAddrWithPath for an expired path.
+	ErrPathExpired = errors.New("path expired")
+	// ErrPathMismatch is returned by DialAddrWithPath for a path that does not
+	// lead to the destination.
+	ErrPathMismatch = errors.New("path does not lead to destination")
+)
+
+// validatePath checks that path leads to dst and has not expired at now, and
+// returns it. Otherwise, if fallback is set, the paths returned by query are
+// matched against path, and the path with the same interfaces, or the first
+// path, is returned.
+func validatePath(dst addr.IA, path snet.Path, fallback bool, now time.Time,
+	query func(addr.IA) ([]snet.Path, error)) (snet.Path, error) {
+
+	err := checkPath(dst, path, now)
+	if err == nil || !fallback {
+		return path, err
+	}
+	paths, qerr := query(dst)
+	if qerr != nil {
+		return nil, qerr
+	}
+	if len(paths) == 0 {
+		return nil, nil // local AS
+	}
+	fp := fingerprint(path)
+	for _, p := range paths {
+		if fp != "" && snet.Fingerprint(p) == fp && checkPath(dst, p, now) == nil {
+			return p, nil
+		}
+	}
+	return paths[0], nil
+}
+
+func checkPath(dst addr.IA, path snet.Path, now time.Time) error {
+	if path == nil || path.Destination() != dst {
+		return ErrPathMismatch
+	}
+	if md := path.Metadata(); md != nil && !md.Expiry.IsZero() && now.After(md.Expiry) {
+		return ErrPathExpired
+	}
+	return nil
+}
+
 // QueryPaths queries the DefNetwork's sciond PathQuerier connection for paths to addr
 // If addr is in the local IA, an empty slice and no error is returned.
 func QueryPaths(ia addr.IA) ([]snet.Path, error) {
@@ -169,6 +293,9 @@ func pathSelection(paths []snet.Path, pathAlgo int) snet.Path {
 	case MTU:
 		log.Debug("Path selection algorithm", "pathAlgo", "MTU")
 		selectedPath, metric = pathAlgos[pathAlgo](paths)
+	case Deterministic:
+		log.Debug("Path selection algorithm", "pathAlgo", "deterministic")
+		selectedPath = SelectPathDeterministic(paths)
 	default:
 		// Default is to take result with best score
 		for _, algo := range pathAlgos {
@@ -183,6 +310,24 @@ func pathSelection(paths []snet.Path, pathAlgo int) snet.Path {
 	return selectedPath
 }
 
+// SelectPathDeterministic returns the path with the lexicographically smallest
+// fingerprint, independent of the order of the paths. Returns nil if paths is
+// empty.
+// This is intended to make tests reproducible; it does not select a good path
+// and should not be used in production. To apply a policy, filter the paths
+// first, e.g. with FilterPathsByFirstInterface.
+func SelectPathDeterministic(paths []snet.Path) snet.Path {
+	var selectedPath snet.Path
+	var selectedFingerprint snet.PathFingerprint
+	for _, path := range paths {
+		fingerprint := snet.Fingerprint(path)
+		if selectedPath == nil || fingerprint < selectedFingerprint {
+			selectedPath, selectedFingerprint = path, fingerprint
+		}
+	}
+	return selectedPath
+}
+
 func selectShortestPath(paths []snet.Path) (selectedPath snet.Path, metric float64) {
 	// Selects shortest path by number of hops
 	for _, path := range paths {
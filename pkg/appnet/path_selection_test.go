@@ -0,0 +1,190 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/common"
+	"github.com/scionproto/scion/go/lib/snet"
+	snetpath "github.com/scionproto/scion/go/lib/snet/path"
+)
+
+func ifid(id int) common.IFIDType {
+	return common.IFIDType(id)
+}
+
+func TestPreferFirstInterface(t *testing.T) {
+	// Paths from 1-ff00:0:1 to 1-ff00:0:3, the first two only differ in the
+	// first hop interface.
+	viaB1 := testPath("1-ff00:0:1", 1, "1-ff00:0:2", 1, "1-ff00:0:2", 2, "1-ff00:0:3", 1)
+	viaB2 := testPath("1-ff00:0:1", 2, "1-ff00:0:2", 3, "1-ff00:0:2", 2, "1-ff00:0:3", 1)
+	viaC := testPath("1-ff00:0:1", 3, "1-ff00:0:4", 1, "1-ff00:0:4", 2, "1-ff00:0:3", 2)
+	paths := []snet.Path{viaB1, viaB2, viaC}
+
+	testCases := []struct {
+		name     string
+		egress   int
+		filtered []snet.Path
+		prefer   []snet.Path
+	}{
+		{"first", 1, []snet.Path{viaB1}, []snet.Path{viaB1, viaC}},
+		{"second", 2, []snet.Path{viaB2}, []snet.Path{viaB2, viaC}},
+		{"other AS sequence", 3, []snet.Path{viaC}, []snet.Path{viaB1, viaB2, viaC}},
+		{"unknown", 42, nil, []snet.Path{viaB1, viaB2, viaC}},
+	}
+	check := func(name string, actual, expected []snet.Path) {
+		if len(actual) != len(expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, actual)
+			return
+		}
+		for i := range actual {
+			if snet.Fingerprint(actual[i]) != snet.Fingerprint(expected[i]) {
+				t.Errorf("%s: path %d, expected %s, got %s", name, i, expected[i], actual[i])
+			}
+		}
+	}
+	for _, tc := range testCases {
+		check(tc.name+" filter", FilterPathsByFirstInterface(paths, ifid(tc.egress)), tc.filtered)
+		check(tc.name+" prefer", PreferFirstInterface(paths, ifid(tc.egress)), tc.prefer)
+	}
+
+	// selection among the remaining paths
+	selected := pathSelection(PreferFirstInterface([]snet.Path{viaB2, viaB1}, ifid(1)), Shortest)
+	check("selection", []snet.Path{selected}, []snet.Path{viaB1})
+}
+
+func TestSelectPathDeterministic(t *testing.T) {
+	viaB1 := testPath("1-ff00:0:1", 1, "1-ff00:0:2", 1, "1-ff00:0:2", 2, "1-ff00:0:3", 1)
+	viaB2 := testPath("1-ff00:0:1", 2, "1-ff00:0:2", 3, "1-ff00:0:2", 2, "1-ff00:0:3", 1)
+	viaC := testPath("1-ff00:0:1", 3, "1-ff00:0:4", 1, "1-ff00:0:4", 2, "1-ff00:0:3", 2)
+
+	if SelectPathDeterministic(nil) != nil {
+		t.Errorf("expected nil for empty candidate set")
+	}
+
+	orders := [][]snet.Path{
+		{viaB1, viaB2, viaC},
+		{viaC, viaB2, viaB1},
+		{viaB2, viaC, viaB1},
+	}
+	expected := snet.Fingerprint(SelectPathDeterministic(orders[0]))
+	for i := 0; i < 10; i++ {
+		for _, paths := range orders {
+			selected := pathSelection(paths, Deterministic)
+			if snet.Fingerprint(selected) != expected {
+				t.Fatalf("selection not deterministic: expected %s, got %s for %v", expected, selected, paths)
+			}
+		}
+	}
+	for _, p := range orders[0] {
+		if snet.Fingerprint(p) < expected {
+			t.Errorf("path %s has smaller fingerprint than selected", p)
+		}
+	}
+
+	// the selection is made among the paths remaining after filtering
+	for _, p := range orders[0] {
+		egress := p.Metadata().Interfaces[0].ID
+		selected := SelectPathDeterministic(FilterPathsByFirstInterface(orders[1], egress))
+		if snet.Fingerprint(selected) != snet.Fingerprint(p) {
+			t.Errorf("egress %d: expected %s, got %s", egress, p, selected)
+		}
+	}
+}
+
+func TestPathAlgoMetric(t *testing.T) {
+	cases := map[string]int{
+		"shortest": Shortest,
+		"mtu":      MTU,
+		"":         PathAlgoDefault,
+		"unknown":  PathAlgoDefault,
+	}
+	for name, expected := range cases {
+		if actual := PathAlgoMetric(name); actual != expected {
+			t.Errorf("%q: expected metric %d, got %d", name, expected, actual)
+		}
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	dst := mustParseIA("1-ff00:0:3")
+	now := time.Now()
+	// expiringPath returns a path to dst via the given interface of the source
+	// AS, expiring at expiry.
+	expiringPath := func(dst addr.IA, egress int, expiry time.Time) snet.Path {
+		p := testPath("1-ff00:0:1", egress, "1-ff00:0:3", 1).(snetpath.Path)
+		p.Dst = dst
+		p.Meta.Expiry = expiry
+		return p
+	}
+	valid := expiringPath(dst, 1, now.Add(time.Hour))
+	expired := expiringPath(dst, 1, now.Add(-time.Minute))
+	refreshed := expiringPath(dst, 1, now.Add(2*time.Hour))
+	other := expiringPath(dst, 2, now.Add(time.Hour))
+
+	samePath := func(a, b snet.Path) bool {
+		return a != nil && snet.Fingerprint(a) == snet.Fingerprint(b) &&
+			a.Metadata().Expiry.Equal(b.Metadata().Expiry)
+	}
+
+	noQuery := func(addr.IA) ([]snet.Path, error) {
+		t.Errorf("unexpected path query")
+		return nil, errors.New("unexpected path query")
+	}
+	query := func(paths ...snet.Path) func(addr.IA) ([]snet.Path, error) {
+		return func(addr.IA) ([]snet.Path, error) { return paths, nil }
+	}
+
+	// a valid path is used without querying paths
+	if p, err := validatePath(dst, valid, true, now, noQuery); err != nil || !samePath(p, valid) {
+		t.Errorf("expected valid path to be used, got %v, %v", p, err)
+	}
+
+	// an expired path is replaced by the refreshed path with the same interfaces
+	p, err := validatePath(dst, expired, true, now, query(other, refreshed))
+	if err != nil || !samePath(p, refreshed) {
+		t.Errorf("expected refreshed path, got %v, %v", p, err)
+	}
+	// ... or by the first path, if it no longer exists
+	p, err = validatePath(dst, expired, true, now, query(other))
+	if err != nil || !samePath(p, other) {
+		t.Errorf("expected first path, got %v, %v", p, err)
+	}
+	// ... or rejected without fallback
+	if _, err := validatePath(dst, expired, false, now, noQuery); !errors.Is(err, ErrPathExpired) {
+		t.Errorf("expected ErrPathExpired, got %v", err)
+	}
+
+	// a path to a different destination
+	wrongDst := expiringPath(mustParseIA("1-ff00:0:4"), 1, now.Add(time.Hour))
+	if _, err := validatePath(dst, wrongDst, false, now, noQuery); !errors.Is(err, ErrPathMismatch) {
+		t.Errorf("expected ErrPathMismatch, got %v", err)
+	}
+	if p, err := validatePath(dst, wrongDst, true, now, query(other)); err != nil || !samePath(p, other) {
+		t.Errorf("expected first path, got %v, %v", p, err)
+	}
+
+	// no path at all
+	if _, err := validatePath(dst, nil, false, now, noQuery); !errors.Is(err, ErrPathMismatch) {
+		t.Errorf("expected ErrPathMismatch, got %v", err)
+	}
+	if p, err := validatePath(dst, nil, true, now, query(other)); err != nil || !samePath(p, other) {
+		t.Errorf("expected first path, got %v, %v", p, err)
+	}
+}
@@ -54,7 +54,7 @@ func readRainsConfig() (*snet.UDPAddr, error) {
 	} else if err != nil {
 		return nil, fmt.Errorf("error loading %s: %s", rainsConfigPath, err)
 	}
-	address, err := snet.ParseUDPAddr(strings.TrimSpace(string(bs)))
+	address, err := ParseUDPAddr(strings.TrimSpace(string(bs)))
 	if err != nil {
 		return nil, fmt.Errorf("error parsing %s, expected SCION UDP address: %s", rainsConfigPath, err)
 	}
@@ -0,0 +1,195 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// rateLimitBurst is the time for which a RateLimitedConn accumulates unused
+// send rate, i.e. the burst size is rateLimitBurst times the send rate. A
+// single datagram larger than the burst size can always be sent.
+const rateLimitBurst = 10 * time.Millisecond
+
+// ErrWouldBlock is returned by a non-blocking RateLimitedConn if sending a
+// datagram would exceed the send rate.
+var ErrWouldBlock = errors.New("send rate exceeded")
+
+// RateLimitedConn is a net.PacketConn limiting the rate at which datagrams
+// are sent over the wrapped connection, using a token bucket.
+// By default, writes exceeding the send rate block until the datagram can be
+// sent, or until the write deadline would be exceeded, in which case they
+// fail immediately with os.ErrDeadlineExceeded. In non-blocking mode, such
+// writes fail with ErrWouldBlock instead.
+type RateLimitedConn struct {
+	net.PacketConn
+
+	mutex         sync.Mutex
+	rate          float64 // bytes per second, 0 for unlimited
+	nonBlocking   bool
+	tokens        float64 // bytes that can be sent now, negative if reserved by waiting writes
+	last          time.Time
+	writeDeadline time.Time
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewRateLimitedConn returns a RateLimitedConn wrapping conn, e.g. as returned
+// by ListenPort or Dial. The send rate is initially unlimited.
+func NewRateLimitedConn(conn net.PacketConn) *RateLimitedConn {
+	return &RateLimitedConn{
+		PacketConn: conn,
+		closed:     make(chan struct{}),
+	}
+}
+
+// SetSendRate sets the send rate in bytes per second. 0 means unlimited.
+func (c *RateLimitedConn) SetSendRate(bytesPerSec int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if bytesPerSec < 0 {
+		bytesPerSec = 0
+	}
+	c.rate = float64(bytesPerSec)
+	c.tokens = c.rate * rateLimitBurst.Seconds()
+	c.last = time.Now()
+}
+
+// SetNonBlocking sets whether writes exceeding the send rate fail with
+// ErrWouldBlock instead of blocking.
+func (c *RateLimitedConn) SetNonBlocking(nonBlocking bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.nonBlocking = nonBlocking
+}
+
+// WriteTo sends b to addr once the send rate permits.
+func (c *RateLimitedConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if err := c.wait(len(b)); err != nil {
+		return 0, err
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+// Write sends b once the send rate permits, if the wrapped connection is
+// connected, e.g. as returned by Dial.
+func (c *RateLimitedConn) Write(b []byte) (int, error) {
+	w, ok := c.PacketConn.(interface{ Write([]byte) (int, error) })
+	if !ok {
+		return 0, fmt.Errorf("write on unconnected %T", c.PacketConn)
+	}
+	if err := c.wait(len(b)); err != nil {
+		return 0, err
+	}
+	return w.Write(b)
+}
+
+// Close closes the wrapped connection, and aborts the waiting writes.
+func (c *RateLimitedConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.PacketConn.Close()
+}
+
+// SetDeadline sets the read and write deadlines of the wrapped connection; the
+// write deadline also applies to the time waited for the send rate.
+func (c *RateLimitedConn) SetDeadline(t time.Time) error {
+	c.setWriteDeadline(t)
+	return c.PacketConn.SetDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of the wrapped connection, which
+// also applies to the time waited for the send rate.
+func (c *RateLimitedConn) SetWriteDeadline(t time.Time) error {
+	c.setWriteDeadline(t)
+	return c.PacketConn.SetWriteDeadline(t)
+}
+
+func (c *RateLimitedConn) setWriteDeadline(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.writeDeadline = t
+}
+
+// wait blocks until n bytes can be sent.
+func (c *RateLimitedConn) wait(n int) error {
+	d, err := c.reserve(n, time.Now())
+	if err != nil || d <= 0 {
+		return err
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-c.closed:
+		return net.ErrClosed
+	}
+}
+
+// reserve takes n bytes from the token bucket and returns the time to wait
+// until they can be sent. The bytes are not taken if the wait is not possible
+// because of the mode or the write deadline.
+func (c *RateLimitedConn) reserve(n int, now time.Time) (time.Duration, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	if !c.writeDeadline.IsZero() && !now.Before(c.writeDeadline) {
+		return 0, os.ErrDeadlineExceeded
+	}
+	if c.rate == 0 {
+		return 0, nil
+	}
+
+	burst := c.rate * rateLimitBurst.Seconds()
+	if burst < float64(n) {
+		burst = float64(n)
+	}
+	c.tokens += now.Sub(c.last).Seconds() * c.rate
+	if c.tokens > burst {
+		c.tokens = burst
+	}
+	c.last = now
+
+	missing := float64(n) - c.tokens
+	if missing <= 0 {
+		c.tokens -= float64(n)
+		return 0, nil
+	}
+	if c.nonBlocking {
+		return 0, ErrWouldBlock
+	}
+	d := time.Duration(missing / c.rate * float64(time.Second))
+	if !c.writeDeadline.IsZero() && now.Add(d).After(c.writeDeadline) {
+		return 0, os.ErrDeadlineExceeded
+	}
+	c.tokens -= float64(n)
+	return d, nil
+}
+
+func (c *RateLimitedConn) String() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return fmt.Sprintf("RateLimitedConn{%s, %.0f B/s}", c.PacketConn.LocalAddr(), c.rate)
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+func TestRateLimitedConn(t *testing.T) {
+	remote, _ := snet.ParseUDPAddr("1-ff00:0:4,[10.0.0.4]:5678")
+	fake := newFakePacketConn()
+	conn := NewRateLimitedConn(fake)
+
+	// unlimited
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if _, err := conn.WriteTo(make([]byte, 1000), remote); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("unlimited writes took %s", elapsed)
+	}
+
+	// 50 packets of 1000 bytes at 100KB/s take 0.5s, less the initial burst
+	// of 1000 bytes.
+	conn.SetSendRate(100000)
+	start = time.Now()
+	for i := 0; i < 50; i++ {
+		if _, err := conn.WriteTo(make([]byte, 1000), remote); err != nil {
+			t.Fatal(err)
+		}
+	}
+	elapsed := time.Since(start)
+	if elapsed < 450*time.Millisecond || elapsed > 750*time.Millisecond {
+		t.Errorf("expected rate limited writes to take 0.5s, took %s", elapsed)
+	}
+	if len(fake.packets) != 150 {
+		t.Errorf("expected 150 packets, got %d", len(fake.packets))
+	}
+}
+
+func TestRateLimitedConnNonBlocking(t *testing.T) {
+	remote, _ := snet.ParseUDPAddr("1-ff00:0:4,[10.0.0.4]:5678")
+	fake := newFakePacketConn()
+	conn := NewRateLimitedConn(fake)
+	conn.SetSendRate(10000)
+	conn.SetNonBlocking(true)
+
+	// the initial burst is 100 bytes
+	if _, err := conn.WriteTo(make([]byte, 100), remote); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.WriteTo(make([]byte, 1000), remote); !errors.Is(err, ErrWouldBlock) {
+		t.Fatalf("expected ErrWouldBlock, got %v", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if _, err := conn.WriteTo(make([]byte, 1000), remote); err != nil {
+		t.Fatalf("expected write to succeed after waiting, got %v", err)
+	}
+	if len(fake.packets) != 2 {
+		t.Errorf("expected 2 packets, got %d", len(fake.packets))
+	}
+}
+
+func TestRateLimitedConnDeadline(t *testing.T) {
+	remote, _ := snet.ParseUDPAddr("1-ff00:0:4,[10.0.0.4]:5678")
+	fake := newFakePacketConn()
+	conn := NewRateLimitedConn(fake)
+	conn.SetSendRate(10000)
+
+	// after the initial burst of 100 bytes, the packet is sent after 90ms
+	if _, err := conn.WriteTo(make([]byte, 1000), remote); err != nil {
+		t.Fatal(err)
+	}
+	// the next packet can only be sent after another 100ms
+	_ = conn.SetWriteDeadline(time.Now().Add(20 * time.Millisecond))
+	start := time.Now()
+	_, err := conn.WriteTo(make([]byte, 1000), remote)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected os.ErrDeadlineExceeded, got %v", err)
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("expected write to fail without waiting, took %s", elapsed)
+	}
+
+	// the failed write has not used up the send rate
+	_ = conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+	start = time.Now()
+	if _, err := conn.WriteTo(make([]byte, 1000), remote); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("expected write within 100ms, took %s", elapsed)
+	}
+
+	// deadline in the past
+	_ = conn.SetDeadline(time.Now().Add(-time.Second))
+	if _, err := conn.WriteTo(make([]byte, 1), remote); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected os.ErrDeadlineExceeded, got %v", err)
+	}
+}
+
+func TestRateLimitedConnClose(t *testing.T) {
+	remote, _ := snet.ParseUDPAddr("1-ff00:0:4,[10.0.0.4]:5678")
+	conn := NewRateLimitedConn(newFakePacketConn())
+	conn.SetSendRate(1000)
+
+	// the write has to wait for 1s
+	done := make(chan error)
+	go func() {
+		_, err := conn.WriteTo(make([]byte, 1000), remote)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	_ = conn.Close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("expected net.ErrClosed, got %v", err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("blocked write not aborted by Close")
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+const (
+	// redundantHeaderLen is the length of the sequence number prefixed to each
+	// datagram sent over a RedundantConn.
+	redundantHeaderLen = 8
+	// redundantDedupSize is the number of recently received datagrams
+	// remembered by a RedundantConn to suppress duplicates.
+	redundantDedupSize = 4096
+)
+
+// ChooseDisjointPaths queries paths to dst and returns up to k of them, chosen
+// to share as few interfaces as possible; see SelectDisjointPaths.
+// If the remote address is in the local IA, return (nil, nil).
+func ChooseDisjointPaths(dst addr.IA, k int) ([]snet.Path, error) {
+	paths, err := QueryPaths(dst)
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+	return SelectDisjointPaths(paths, k), nil
+}
+
+// SelectDisjointPaths greedily picks up to k paths from paths, such that the
+// selected paths share as few interfaces as possible.
+// The first path is always selected. Each following path is the one sharing
+// the fewest interfaces with the paths selected so far; ties are broken by
+// preferring fewer hops and then the order of the input.
+func SelectDisjointPaths(paths []snet.Path, k int) []snet.Path {
+	if k > len(paths) {
+		k = len(paths)
+	}
+	if k <= 0 {
+		return nil
+	}
+	used := make(map[snet.PathInterface]struct{})
+	taken := make([]bool, len(paths))
+	selected := make([]snet.Path, 0, k)
+	take := func(i int) {
+		taken[i] = true
+		selected = append(selected, paths[i])
+		for _, intf := range paths[i].Metadata().Interfaces {
+			used[intf] = struct{}{}
+		}
+	}
+
+	take(0)
+	for len(selected) < k {
+		best, bestShared, bestHops := -1, 0, 0
+		for i, p := range paths {
+			if taken[i] {
+				continue
+			}
+			shared := 0
+			for _, intf := range p.Metadata().Interfaces {
+				if _, ok := used[intf]; ok {
+					shared++
+				}
+			}
+			hops := len(p.Metadata().Interfaces)
+			if best < 0 || shared < bestShared || (shared == bestShared && hops < bestHops) {
+				best, bestShared, bestHops = i, shared, hops
+			}
+		}
+		take(best)
+	}
+	return selected
+}
+
+// RedundantConn is a connection to a single remote which sends each datagram
+// over multiple paths, typically chosen with ChooseDisjointPaths, so that the
+// failure of a single link does not cause the datagram to be lost.
+// Each datagram is prefixed with a sequence number, which the receiving
+// RedundantConn uses to drop the duplicates.
+// Both ends of the connection need to use a RedundantConn.
+type RedundantConn struct {
+	conn   net.PacketConn
+	remote *snet.UDPAddr
+	paths  []snet.Path
+
+	writeMutex sync.Mutex
+	seq        uint64
+
+	readMutex sync.Mutex
+	readBuf   []byte
+	dedup     dedupFilter
+}
+
+var _ net.Conn = (*RedundantConn)(nil)
+
+// NewRedundantConn returns a RedundantConn sending to remote over all of the
+// given paths, using the unconnected conn, e.g. as returned by ListenPort.
+// If paths is empty, the path set on remote is used, i.e. datagrams are not
+// duplicated.
+func NewRedundantConn(conn net.PacketConn, remote *snet.UDPAddr, paths []snet.Path) *RedundantConn {
+	return &RedundantConn{
+		conn:   conn,
+		remote: remote.Copy(),
+		paths:  paths,
+		seq:    randomSeq(),
+		dedup:  newDedupFilter(redundantDedupSize),
+	}
+}
+
+// randomSeq returns a random initial sequence number, so that a restarted
+// sender is not mistaken for sending duplicates.
+func randomSeq() uint64 {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// Write sends b over each of the paths. It succeeds if the datagram could be
+// sent over at least one path.
+func (c *RedundantConn) Write(b []byte) (int, error) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	pkt := make([]byte, redundantHeaderLen+len(b))
+	binary.BigEndian.PutUint64(pkt, c.seq)
+	copy(pkt[redundantHeaderLen:], b)
+	c.seq++
+
+	if len(c.paths) == 0 {
+		if _, err := c.conn.WriteTo(pkt, c.remote); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	var firstErr error
+	sent := false
+	for _, path := range c.paths {
+		dst := c.remote.Copy()
+		SetPath(dst, path)
+		if _, err := c.conn.WriteTo(pkt, dst); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		sent = true
+	}
+	if !sent {
+		return 0, firstErr
+	}
+	return len(b), nil
+}
+
+// Read reads the next datagram that was not already received over another
+// path. Datagrams from other senders than the remote are dropped.
+func (c *RedundantConn) Read(b []byte) (int, error) {
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+
+	if cap(c.readBuf) < redundantHeaderLen+len(b) {
+		c.readBuf = make([]byte, redundantHeaderLen+len(b))
+	}
+	buf := c.readBuf[:redundantHeaderLen+len(b)]
+	for {
+		n, from, err := c.conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		if n < redundantHeaderLen || !isSameHost(from, c.remote) {
+			continue
+		}
+		seq := binary.BigEndian.Uint64(buf)
+		if !c.dedup.add(seq) {
+			continue
+		}
+		return copy(b, buf[redundantHeaderLen:n]), nil
+	}
+}
+
+// Close closes the underlying connection.
+func (c *RedundantConn) Close() error {
+	return c.conn.Close()
+}
+
+// LocalAddr returns the local address of the underlying connection.
+func (c *RedundantConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote address.
+func (c *RedundantConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline sets the deadlines of the underlying connection.
+func (c *RedundantConn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the underlying connection.
+func (c *RedundantConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying connection.
+func (c *RedundantConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// Paths returns the paths over which the datagrams are sent.
+func (c *RedundantConn) Paths() []snet.Path {
+	return c.paths
+}
+
+func (c *RedundantConn) String() string {
+	return fmt.Sprintf("RedundantConn{%s, %d paths}", c.remote, len(c.paths))
+}
+
+func isSameHost(from net.Addr, remote *snet.UDPAddr) bool {
+	f, ok := from.(*snet.UDPAddr)
+	if !ok {
+		return false
+	}
+	return f.IA == remote.IA && f.Host.IP.Equal(remote.Host.IP) && f.Host.Port == remote.Host.Port
+}
+
+// dedupFilter remembers the last size sequence numbers seen.
+// Duplicates arriving after more than size other datagrams are not detected.
+type dedupFilter struct {
+	seen  map[uint64]struct{}
+	order []uint64 // ring buffer of the remembered sequence numbers
+	next  int
+	full  bool
+}
+
+func newDedupFilter(size int) dedupFilter {
+	return dedupFilter{
+		seen:  make(map[uint64]struct{}, size),
+		order: make([]uint64, size),
+	}
+}
+
+// add records seq and returns true if it was not seen before.
+func (d *dedupFilter) add(seq uint64) bool {
+	if _, ok := d.seen[seq]; ok {
+		return false
+	}
+	if d.full {
+		delete(d.seen, d.order[d.next])
+	}
+	d.seen[seq] = struct{}{}
+	d.order[d.next] = seq
+	d.next = (d.next + 1) % len(d.order)
+	if d.next == 0 {
+		d.full = true
+	}
+	return true
+}
@@ -0,0 +1,192 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/common"
+	"github.com/scionproto/scion/go/lib/snet"
+	snetpath "github.com/scionproto/scion/go/lib/snet/path"
+)
+
+// testPath returns a path with the given interfaces, specified as
+// alternating IA strings and interface IDs.
+func testPath(ifaces ...interface{}) snet.Path {
+	var intfs []snet.PathInterface
+	for i := 0; i < len(ifaces); i += 2 {
+		ia, err := addr.IAFromString(ifaces[i].(string))
+		if err != nil {
+			panic(err)
+		}
+		intfs = append(intfs, snet.PathInterface{
+			IA: ia,
+			ID: common.IFIDType(ifaces[i+1].(int)),
+		})
+	}
+	return snetpath.Path{
+		Meta: snet.PathMetadata{Interfaces: intfs},
+	}
+}
+
+func TestSelectDisjointPaths(t *testing.T) {
+	// Paths from 1-ff00:0:1 to 1-ff00:0:4
+	viaA := testPath("1-ff00:0:1", 1, "1-ff00:0:2", 1, "1-ff00:0:2", 2, "1-ff00:0:4", 1)
+	viaAB := testPath("1-ff00:0:1", 1, "1-ff00:0:2", 1, "1-ff00:0:2", 3, "1-ff00:0:3", 1,
+		"1-ff00:0:3", 2, "1-ff00:0:4", 2)
+	viaC := testPath("1-ff00:0:1", 2, "1-ff00:0:5", 1, "1-ff00:0:5", 2, "1-ff00:0:4", 3)
+	viaCLong := testPath("1-ff00:0:1", 2, "1-ff00:0:5", 1, "1-ff00:0:5", 3, "1-ff00:0:6", 1,
+		"1-ff00:0:6", 2, "1-ff00:0:4", 4)
+
+	testCases := []struct {
+		name     string
+		paths    []snet.Path
+		k        int
+		expected []snet.Path
+	}{
+		{"empty", nil, 2, nil},
+		{"zero", []snet.Path{viaA, viaC}, 0, nil},
+		{"single", []snet.Path{viaA, viaAB, viaC}, 1, []snet.Path{viaA}},
+		{"disjoint", []snet.Path{viaA, viaAB, viaC}, 2, []snet.Path{viaA, viaC}},
+		{"disjoint first overlapping", []snet.Path{viaAB, viaA, viaCLong, viaC}, 2, []snet.Path{viaAB, viaC}},
+		{"all", []snet.Path{viaA, viaAB, viaC}, 3, []snet.Path{viaA, viaC, viaAB}},
+		{"more than available", []snet.Path{viaA, viaAB}, 5, []snet.Path{viaA, viaAB}},
+		{"only overlapping", []snet.Path{viaA, viaAB}, 2, []snet.Path{viaA, viaAB}},
+	}
+	for _, tc := range testCases {
+		actual := SelectDisjointPaths(tc.paths, tc.k)
+		if len(actual) != len(tc.expected) {
+			t.Errorf("%s: expected %d paths, got %d", tc.name, len(tc.expected), len(actual))
+			continue
+		}
+		for i := range actual {
+			if snet.Fingerprint(actual[i]) != snet.Fingerprint(tc.expected[i]) {
+				t.Errorf("%s: path %d, expected %s, got %s", tc.name, i, tc.expected[i], actual[i])
+			}
+		}
+	}
+}
+
+func TestDedupFilter(t *testing.T) {
+	d := newDedupFilter(4)
+	for _, seq := range []uint64{1, 2, 3} {
+		if !d.add(seq) {
+			t.Fatalf("%d reported as duplicate", seq)
+		}
+	}
+	if d.add(2) {
+		t.Fatal("duplicate 2 not detected")
+	}
+	for _, seq := range []uint64{4, 5, 6} {
+		if !d.add(seq) {
+			t.Fatalf("%d reported as duplicate", seq)
+		}
+	}
+	if len(d.seen) != 4 {
+		t.Fatalf("filter not bounded, remembers %d entries", len(d.seen))
+	}
+	if d.add(6) || d.add(3) {
+		t.Fatal("remembered duplicate not detected")
+	}
+}
+
+// fakePacketConn is a net.PacketConn recording the packets written to it and
+// returning the packets from the queue on read.
+type fakePacketConn struct {
+	written []*snet.UDPAddr
+	packets [][]byte
+	queue   chan fakePacket
+}
+
+type fakePacket struct {
+	data []byte
+	from net.Addr
+}
+
+func newFakePacketConn() *fakePacketConn {
+	return &fakePacketConn{queue: make(chan fakePacket, 100)}
+}
+
+func (c *fakePacketConn) WriteTo(b []byte, dst net.Addr) (int, error) {
+	c.written = append(c.written, dst.(*snet.UDPAddr))
+	c.packets = append(c.packets, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (c *fakePacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	p, ok := <-c.queue
+	if !ok {
+		return 0, nil, io.EOF
+	}
+	return copy(b, p.data), p.from, nil
+}
+
+func (c *fakePacketConn) Close() error                       { return nil }
+func (c *fakePacketConn) LocalAddr() net.Addr                { return nil }
+func (c *fakePacketConn) SetDeadline(t time.Time) error      { return nil }
+func (c *fakePacketConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *fakePacketConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func TestRedundantConn(t *testing.T) {
+	local, _ := snet.ParseUDPAddr("1-ff00:0:1,[10.0.0.1]:1234")
+	remote, _ := snet.ParseUDPAddr("1-ff00:0:4,[10.0.0.4]:5678")
+	other, _ := snet.ParseUDPAddr("1-ff00:0:4,[10.0.0.5]:5678")
+	paths := []snet.Path{
+		testPath("1-ff00:0:1", 1, "1-ff00:0:4", 1),
+		testPath("1-ff00:0:1", 2, "1-ff00:0:4", 2),
+	}
+
+	senderConn := newFakePacketConn()
+	sender := NewRedundantConn(senderConn, remote, paths)
+	for _, msg := range []string{"foo", "bar"} {
+		n, err := sender.Write([]byte(msg))
+		if err != nil || n != len(msg) {
+			t.Fatalf("write failed: %d %v", n, err)
+		}
+	}
+	if len(senderConn.packets) != 4 {
+		t.Fatalf("expected each datagram to be sent over both paths, got %d packets", len(senderConn.packets))
+	}
+
+	// deliver all packets, the duplicates in reverse order, and a packet
+	// from an unrelated host
+	receiverConn := newFakePacketConn()
+	receiver := NewRedundantConn(receiverConn, local, nil)
+	receiverConn.queue <- fakePacket{senderConn.packets[0], local}
+	receiverConn.queue <- fakePacket{senderConn.packets[1], other}
+	receiverConn.queue <- fakePacket{senderConn.packets[3], local}
+	receiverConn.queue <- fakePacket{senderConn.packets[2], local}
+	receiverConn.queue <- fakePacket{senderConn.packets[1], local}
+	close(receiverConn.queue)
+
+	buf := make([]byte, 100)
+	var received []string
+	for {
+		n, err := receiver.Read(buf)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, string(buf[:n]))
+	}
+	if len(received) != 2 || received[0] != "foo" || received[1] != "bar" {
+		t.Errorf("expected [foo bar], got %v", received)
+	}
+}
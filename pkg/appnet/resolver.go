@@ -22,7 +22,8 @@ import (
 )
 
 // Resolver is the interface to resolve a host name to a SCION host address.
-// Currently, this is implemented for reading a hosts file and RAINS
+// Currently, this is implemented for reading a hosts file, RAINS and DNS TXT
+// records
 type Resolver interface {
 	// Resolve finds an address for the name.
 	// Returns a HostNotFoundError if the name was not found, but otherwise no
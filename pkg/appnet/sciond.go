@@ -0,0 +1,236 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/inconshreveable/log15"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/common"
+	"github.com/scionproto/scion/go/lib/ctrl/path_mgmt"
+	"github.com/scionproto/scion/go/lib/drkey"
+	"github.com/scionproto/scion/go/lib/sciond"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+	defaultRetryTimeout   = 10 * time.Second
+)
+
+// ReconnectingConnector is a sciond.Connector that survives restarts of
+// SCIOND. If a call fails with a transport error, the connection is
+// re-established and the call is retried, with exponential backoff, until it
+// succeeds or the caller's context expires. If the context has no deadline,
+// retrying stops after RetryTimeout. Other errors are returned immediately.
+type ReconnectingConnector struct {
+	// Connect establishes a new connection to SCIOND.
+	Connect func(ctx context.Context) (sciond.Connector, error)
+	// InitialBackoff is the wait time before the first retry, doubled for
+	// every further retry up to MaxBackoff. Defaults to 100ms and 5s.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// RetryTimeout limits the time spent retrying a call if the caller's
+	// context has no deadline. Defaults to 10s.
+	RetryTimeout time.Duration
+
+	mutex sync.Mutex
+	conn  sciond.Connector
+}
+
+// NewReconnectingConnector returns a ReconnectingConnector for the SCIOND at
+// address. The connection is established on first use.
+func NewReconnectingConnector(address string) *ReconnectingConnector {
+	return &ReconnectingConnector{
+		Connect: sciond.NewService(address).Connect,
+	}
+}
+
+// retry calls f with the current connection, reconnecting and retrying as
+// long as it fails with a transport error and ctx is not done.
+func (c *ReconnectingConnector) retry(ctx context.Context, f func(sciond.Connector) error) error {
+	backoff := c.InitialBackoff
+	if backoff <= 0 {
+		backoff = defaultInitialBackoff
+	}
+	maxBackoff := c.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		retryTimeout := c.RetryTimeout
+		if retryTimeout <= 0 {
+			retryTimeout = defaultRetryTimeout
+		}
+		deadline = time.Now().Add(retryTimeout)
+	}
+	for {
+		conn, err := c.connection(ctx)
+		if err == nil {
+			err = f(conn)
+			if err == nil || !isTransportError(err) {
+				return err
+			}
+			c.reset(conn)
+		}
+		if ctx.Err() != nil || time.Now().Add(backoff).After(deadline) {
+			return err
+		}
+		log.Debug("SCIOND unavailable, retrying", "err", err, "backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// connection returns the current connection, connecting if necessary.
+func (c *ReconnectingConnector) connection(ctx context.Context) (sciond.Connector, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.conn == nil {
+		conn, err := c.Connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+	return c.conn, nil
+}
+
+// reset discards the connection conn, unless it has already been replaced by
+// a concurrent call.
+func (c *ReconnectingConnector) reset(conn sciond.Connector) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.conn == conn {
+		_ = conn.Close(context.Background())
+		c.conn = nil
+	}
+}
+
+// isTransportError returns true if err indicates that SCIOND could not be
+// reached, as opposed to an error in handling the request.
+func isTransportError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, sciond.ErrUnableToConnect) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return status.Code(err) == codes.Unavailable
+}
+
+func (c *ReconnectingConnector) LocalIA(ctx context.Context) (ia addr.IA, err error) {
+	err = c.retry(ctx, func(conn sciond.Connector) error {
+		ia, err = conn.LocalIA(ctx)
+		return err
+	})
+	return
+}
+
+func (c *ReconnectingConnector) Paths(ctx context.Context, dst, src addr.IA,
+	f sciond.PathReqFlags) (paths []snet.Path, err error) {
+
+	err = c.retry(ctx, func(conn sciond.Connector) error {
+		paths, err = conn.Paths(ctx, dst, src, f)
+		return err
+	})
+	return
+}
+
+func (c *ReconnectingConnector) ASInfo(ctx context.Context, ia addr.IA) (info sciond.ASInfo, err error) {
+	err = c.retry(ctx, func(conn sciond.Connector) error {
+		info, err = conn.ASInfo(ctx, ia)
+		return err
+	})
+	return
+}
+
+func (c *ReconnectingConnector) IFInfo(ctx context.Context,
+	ifs []common.IFIDType) (info map[common.IFIDType]*net.UDPAddr, err error) {
+
+	err = c.retry(ctx, func(conn sciond.Connector) error {
+		info, err = conn.IFInfo(ctx, ifs)
+		return err
+	})
+	return
+}
+
+func (c *ReconnectingConnector) SVCInfo(ctx context.Context,
+	svcTypes []addr.HostSVC) (info map[addr.HostSVC]string, err error) {
+
+	err = c.retry(ctx, func(conn sciond.Connector) error {
+		info, err = conn.SVCInfo(ctx, svcTypes)
+		return err
+	})
+	return
+}
+
+func (c *ReconnectingConnector) RevNotificationFromRaw(ctx context.Context, b []byte) error {
+	return c.retry(ctx, func(conn sciond.Connector) error {
+		return conn.RevNotificationFromRaw(ctx, b)
+	})
+}
+
+func (c *ReconnectingConnector) RevNotification(ctx context.Context,
+	sRevInfo *path_mgmt.SignedRevInfo) error {
+
+	return c.retry(ctx, func(conn sciond.Connector) error {
+		return conn.RevNotification(ctx, sRevInfo)
+	})
+}
+
+func (c *ReconnectingConnector) DRKeyGetLvl2Key(ctx context.Context, meta drkey.Lvl2Meta,
+	valTime time.Time) (key drkey.Lvl2Key, err error) {
+
+	err = c.retry(ctx, func(conn sciond.Connector) error {
+		key, err = conn.DRKeyGetLvl2Key(ctx, meta, valTime)
+		return err
+	})
+	return
+}
+
+// Close closes the current connection, if any. The connector can still be
+// used afterwards; it reconnects on the next call.
+func (c *ReconnectingConnector) Close(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close(ctx)
+	c.conn = nil
+	return err
+}
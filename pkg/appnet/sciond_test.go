@@ -0,0 +1,163 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/sciond"
+)
+
+var _ sciond.Connector = (*ReconnectingConnector)(nil)
+
+// failingSciond is a mock sciond.Connector. LocalIA fails with err for the
+// first failures calls (counted over all connections) and succeeds afterwards.
+type failingSciond struct {
+	sciond.Connector // not implemented
+	failures         *int
+	calls            *int
+	err              error
+}
+
+func (s failingSciond) LocalIA(ctx context.Context) (addr.IA, error) {
+	*s.calls++
+	if *s.failures > 0 {
+		*s.failures--
+		return addr.IA{}, s.err
+	}
+	return addr.IA{I: 1, A: 0xff0000000001}, nil
+}
+
+func (s failingSciond) Close(ctx context.Context) error {
+	return nil
+}
+
+func newFailingConnector(failures int, err error) (*ReconnectingConnector, *int, *int) {
+	var calls, connects int
+	c := &ReconnectingConnector{
+		Connect: func(ctx context.Context) (sciond.Connector, error) {
+			connects++
+			return failingSciond{failures: &failures, calls: &calls, err: err}, nil
+		},
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     4 * time.Millisecond,
+	}
+	return c, &calls, &connects
+}
+
+func TestReconnectingConnector(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+
+	t.Run("transport errors", func(t *testing.T) {
+		c, calls, connects := newFailingConnector(5, unavailable)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		ia, err := c.LocalIA(ctx)
+		if err != nil {
+			t.Fatalf("expected success after retries, got %v", err)
+		}
+		if ia.IsZero() {
+			t.Errorf("expected non-zero IA")
+		}
+		if *calls != 6 || *connects != 6 {
+			t.Errorf("expected 6 calls on 6 connections, got %d calls, %d connects", *calls, *connects)
+		}
+	})
+
+	t.Run("logical error", func(t *testing.T) {
+		logical := status.Error(codes.NotFound, "no such AS")
+		c, calls, _ := newFailingConnector(1, logical)
+		_, err := c.LocalIA(context.Background())
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("expected logical error to be returned, got %v", err)
+		}
+		if *calls != 1 {
+			t.Errorf("expected no retry for logical error, got %d calls", *calls)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		c, _, _ := newFailingConnector(1000000, unavailable)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := c.LocalIA(ctx)
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("expected transport error after deadline, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("retrying did not stop at deadline, took %v", elapsed)
+		}
+	})
+
+	t.Run("retry timeout", func(t *testing.T) {
+		c, _, _ := newFailingConnector(1000000, unavailable)
+		c.RetryTimeout = 50 * time.Millisecond
+		start := time.Now()
+		if _, err := c.LocalIA(context.Background()); err == nil {
+			t.Errorf("expected error")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("retrying did not stop after RetryTimeout, took %v", elapsed)
+		}
+	})
+
+	t.Run("connect error", func(t *testing.T) {
+		connects := 0
+		c := &ReconnectingConnector{
+			Connect: func(ctx context.Context) (sciond.Connector, error) {
+				connects++
+				if connects < 3 {
+					return nil, sciond.ErrUnableToConnect
+				}
+				failures, calls := 0, 0
+				return failingSciond{failures: &failures, calls: &calls}, nil
+			},
+			InitialBackoff: time.Millisecond,
+		}
+		if _, err := c.LocalIA(context.Background()); err != nil {
+			t.Errorf("expected success after reconnecting, got %v", err)
+		}
+		if connects != 3 {
+			t.Errorf("expected 3 connection attempts, got %d", connects)
+		}
+	})
+}
+
+func TestIsTransportError(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected bool
+	}{
+		{status.Error(codes.Unavailable, ""), true},
+		{sciond.ErrUnableToConnect, true},
+		{status.Error(codes.InvalidArgument, ""), false},
+		{errors.New("no path"), false},
+		{context.DeadlineExceeded, false},
+		{context.Canceled, false},
+	}
+	for _, c := range cases {
+		if actual := isTransportError(c.err); actual != c.expected {
+			t.Errorf("%v: expected %v, got %v", c.err, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/scionproto/scion/go/lib/slayers/path/scion"
+	"github.com/scionproto/scion/go/lib/snet"
+	"github.com/scionproto/scion/go/lib/spath"
+)
+
+// TrafficStats counts the packets and bytes in one direction.
+type TrafficStats struct {
+	Packets uint64
+	Bytes   uint64
+}
+
+func (s *TrafficStats) add(n int) {
+	s.Packets++
+	s.Bytes += uint64(n)
+}
+
+// PathStats are the statistics of the traffic over a single path.
+type PathStats struct {
+	Sent     TrafficStats
+	Received TrafficStats
+}
+
+// ConnStats is a snapshot of the statistics of a StatsConn.
+type ConnStats struct {
+	Sent     TrafficStats
+	Received TrafficStats
+	// Paths contains the statistics for each path that has been used. The
+	// map grows with the number of distinct paths and is never pruned.
+	Paths map[PathKey]PathStats
+}
+
+// PathKey identifies a path in the statistics of a StatsConn.
+// It consists of the interfaces of the hop fields of the path, so that
+// packets received over a path, as returned by ReadFrom, have the same key as
+// the packets sent over it, and the key remains the same when the path is
+// refreshed. The key of the empty path within the local AS is "".
+type PathKey string
+
+// StatsPathKey returns the PathKey of path, to look up its statistics.
+func StatsPathKey(path snet.Path) PathKey {
+	if path == nil {
+		return ""
+	}
+	return pathKey(path.Path())
+}
+
+func pathKey(p spath.Path) PathKey {
+	if len(p.Raw) == 0 {
+		return ""
+	}
+	var decoded scion.Decoded
+	if p.Type != scion.PathType || decoded.DecodeFromBytes(p.Raw) != nil {
+		return PathKey(fmt.Sprintf("%s %x", p.Type, p.Raw))
+	}
+	hops := make([]string, len(decoded.HopFields))
+	for i, hf := range decoded.HopFields {
+		hops[i] = fmt.Sprintf("%d>%d", hf.ConsIngress, hf.ConsEgress)
+	}
+	return PathKey(strings.Join(hops, " "))
+}
+
+// StatsConn is a net.PacketConn counting the packets and bytes sent and
+// received over the wrapped connection, in total and for each path.
+type StatsConn struct {
+	net.PacketConn
+
+	mutex sync.Mutex
+	stats ConnStats
+}
+
+// NewStatsConn returns a StatsConn wrapping conn, e.g. as returned by
+// ListenPort.
+func NewStatsConn(conn net.PacketConn) *StatsConn {
+	return &StatsConn{
+		PacketConn: conn,
+		stats:      ConnStats{Paths: make(map[PathKey]PathStats)},
+	}
+}
+
+// ReadFrom reads a packet from the wrapped connection and counts it for the
+// path over which it was received.
+func (c *StatsConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if err != nil {
+		return n, addr, err
+	}
+	key := addrPathKey(addr)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.Received.add(n)
+	ps := c.stats.Paths[key]
+	ps.Received.add(n)
+	c.stats.Paths[key] = ps
+	return n, addr, err
+}
+
+// WriteTo writes a packet to the wrapped connection and counts it for the
+// path set in addr.
+func (c *StatsConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(b, addr)
+	if err != nil {
+		return n, err
+	}
+	key := addrPathKey(addr)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.Sent.add(n)
+	ps := c.stats.Paths[key]
+	ps.Sent.add(n)
+	c.stats.Paths[key] = ps
+	return n, err
+}
+
+// Stats returns a snapshot of the statistics.
+func (c *StatsConn) Stats() ConnStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stats := c.stats
+	stats.Paths = make(map[PathKey]PathStats, len(c.stats.Paths))
+	for k, v := range c.stats.Paths {
+		stats.Paths[k] = v
+	}
+	return stats
+}
+
+func addrPathKey(addr net.Addr) PathKey {
+	if a, ok := addr.(*snet.UDPAddr); ok {
+		return pathKey(a.Path)
+	}
+	return ""
+}
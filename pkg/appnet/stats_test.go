@@ -0,0 +1,135 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"net"
+	"testing"
+
+	"github.com/scionproto/scion/go/lib/slayers/path"
+	"github.com/scionproto/scion/go/lib/slayers/path/scion"
+	"github.com/scionproto/scion/go/lib/snet"
+	snetpath "github.com/scionproto/scion/go/lib/snet/path"
+	"github.com/scionproto/scion/go/lib/spath"
+)
+
+// rawTestPath returns a path with a single segment with the given egress and
+// ingress interface, and the given segment ID.
+func rawTestPath(t *testing.T, egress, ingress uint16, segID uint16) snet.Path {
+	decoded := scion.Decoded{
+		Base: scion.Base{
+			PathMeta: scion.MetaHdr{SegLen: [3]uint8{2, 0, 0}},
+			NumINF:   1,
+			NumHops:  2,
+		},
+		InfoFields: []*path.InfoField{{ConsDir: true, SegID: segID}},
+		HopFields: []*path.HopField{
+			{ConsEgress: egress, Mac: make([]byte, path.MacLen)},
+			{ConsIngress: ingress, Mac: make([]byte, path.MacLen)},
+		},
+	}
+	raw := make([]byte, decoded.Len())
+	if err := decoded.SerializeTo(raw); err != nil {
+		t.Fatal(err)
+	}
+	return snetpath.Path{SPath: spath.Path{Raw: raw, Type: scion.PathType}}
+}
+
+// receivedFrom returns the address from which a packet sent from remote over
+// p is received, as returned by snet: the path as received is reversed, ready
+// to reply.
+func receivedFrom(t *testing.T, remote *snet.UDPAddr, p snet.Path) *snet.UDPAddr {
+	// the remote sends over the reverse path
+	sent := p.Path()
+	if err := sent.Reverse(); err != nil {
+		t.Fatal(err)
+	}
+	received := sent.Copy()
+	if err := received.Reverse(); err != nil {
+		t.Fatal(err)
+	}
+	from := remote.Copy()
+	from.Path = received
+	return from
+}
+
+func TestStatsConn(t *testing.T) {
+	remote, _ := snet.ParseUDPAddr("1-ff00:0:4,[10.0.0.4]:5678")
+	path1 := rawTestPath(t, 1, 11, 100)
+	path2 := rawTestPath(t, 2, 12, 200)
+	refreshed1 := rawTestPath(t, 1, 11, 300)
+	key1, key2 := StatsPathKey(path1), StatsPathKey(path2)
+	if key1 == key2 || key1 == "" {
+		t.Fatalf("expected distinct keys, got %q and %q", key1, key2)
+	}
+	if StatsPathKey(refreshed1) != key1 {
+		t.Errorf("expected same key for refreshed path, got %q and %q", StatsPathKey(refreshed1), key1)
+	}
+
+	fake := newFakePacketConn()
+	conn := NewStatsConn(fake)
+	write := func(p snet.Path, size int) {
+		dst := remote.Copy()
+		SetPath(dst, p)
+		if _, err := conn.WriteTo(make([]byte, size), dst); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// send over the first path, then switch to the second path
+	write(path1, 100)
+	write(path1, 100)
+	write(refreshed1, 50)
+	write(path2, 1000)
+	// within the local AS
+	write(nil, 10)
+
+	fake.queue <- fakePacket{make([]byte, 20), receivedFrom(t, remote, path1)}
+	fake.queue <- fakePacket{make([]byte, 30), receivedFrom(t, remote, path2)}
+	fake.queue <- fakePacket{make([]byte, 40), receivedFrom(t, remote, path2)}
+	fake.queue <- fakePacket{make([]byte, 5), &net.UDPAddr{}}
+	buf := make([]byte, 100)
+	for i := 0; i < 4; i++ {
+		if _, _, err := conn.ReadFrom(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := conn.Stats()
+	if stats.Sent != (TrafficStats{Packets: 5, Bytes: 1260}) {
+		t.Errorf("unexpected sent total %+v", stats.Sent)
+	}
+	if stats.Received != (TrafficStats{Packets: 4, Bytes: 95}) {
+		t.Errorf("unexpected received total %+v", stats.Received)
+	}
+	expected := map[PathKey]PathStats{
+		key1: {Sent: TrafficStats{3, 250}, Received: TrafficStats{1, 20}},
+		key2: {Sent: TrafficStats{1, 1000}, Received: TrafficStats{2, 70}},
+		"":   {Sent: TrafficStats{1, 10}, Received: TrafficStats{1, 5}},
+	}
+	if len(stats.Paths) != len(expected) {
+		t.Errorf("expected %d paths, got %v", len(expected), stats.Paths)
+	}
+	for k, v := range expected {
+		if stats.Paths[k] != v {
+			t.Errorf("path %q: expected %+v, got %+v", k, v, stats.Paths[k])
+		}
+	}
+
+	// the snapshot is not affected by later traffic
+	write(path2, 1)
+	if stats.Paths[key2].Sent.Packets != 1 || conn.Stats().Paths[key2].Sent.Packets != 2 {
+		t.Errorf("snapshot modified by later traffic")
+	}
+}
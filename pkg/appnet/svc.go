@@ -0,0 +1,116 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/sciond"
+	"github.com/scionproto/scion/go/lib/snet"
+	"github.com/scionproto/scion/go/lib/spath"
+)
+
+// NoServicePathError is returned by ResolveSVCAddr if there is no path to any
+// instance of the service.
+type NoServicePathError struct {
+	Addr Addr
+	Err  error
+}
+
+func (e *NoServicePathError) Error() string {
+	return fmt.Sprintf("no path to service %s: %s", e.Addr, e.Err)
+}
+
+func (e *NoServicePathError) Unwrap() error {
+	return e.Err
+}
+
+// ResolveSVCAddr parses a SCION service address, e.g. "1-ff00:0:110,CS", and
+// sets the path to the AS, i.e. the first path returned by sciond, or, for the
+// local AS, the underlay address of the service instance announced by sciond.
+//
+// A packet sent to the returned address, e.g. with WriteTo on a connection
+// returned by Listen, is delivered to one instance of the service (anycast).
+// The reply, read with ReadFrom, has the concrete address of the responding
+// instance as source address.
+func ResolveSVCAddr(address string) (*snet.SVCAddr, error) {
+	a, err := ParseAddr(address)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := a.Host.(addr.HostSVC); !ok {
+		return nil, fmt.Errorf("not a service address: %s", a)
+	}
+	n := DefNetwork()
+	ctx, cancel := context.WithTimeout(context.Background(), initTimeout)
+	defer cancel()
+	return resolveSVCAddr(ctx, a, n.IA, n.PathQuerier, n.sciond)
+}
+
+func resolveSVCAddr(ctx context.Context, a Addr, localIA addr.IA,
+	querier snet.PathQuerier, conn sciond.Connector) (*snet.SVCAddr, error) {
+
+	svc := a.Host.(addr.HostSVC)
+	svcAddr := &snet.SVCAddr{IA: a.IA, SVC: svc}
+	if a.IA == localIA {
+		nextHop, err := localServiceNextHop(ctx, conn, svc)
+		if err != nil {
+			return nil, &NoServicePathError{Addr: a, Err: err}
+		}
+		svcAddr.NextHop = nextHop
+		return svcAddr, nil
+	}
+	paths, err := querier.Query(ctx, a.IA)
+	if err == nil && len(paths) == 0 {
+		err = fmt.Errorf("no path available")
+	}
+	if err != nil {
+		return nil, &NoServicePathError{Addr: a, Err: err}
+	}
+	SetSVCPath(svcAddr, paths[0])
+	return svcAddr, nil
+}
+
+// localServiceNextHop returns the underlay address of an instance of the
+// service in the local AS, as announced by sciond.
+func localServiceNextHop(ctx context.Context, conn sciond.Connector,
+	svc addr.HostSVC) (*net.UDPAddr, error) {
+
+	base := svc.Base()
+	info, err := conn.SVCInfo(ctx, []addr.HostSVC{base})
+	if err != nil {
+		return nil, err
+	}
+	underlay, ok := info[base]
+	if !ok || underlay == "" {
+		return nil, fmt.Errorf("no instance of %s in local AS", base.BaseString())
+	}
+	return net.ResolveUDPAddr("udp", underlay)
+}
+
+// SetSVCPath sets the path and the next hop of a service address, analogous
+// to SetPath.
+func SetSVCPath(svcAddr *snet.SVCAddr, path snet.Path) {
+	if path == nil {
+		svcAddr.Path = spath.Path{}
+		svcAddr.NextHop = nil
+	} else {
+		svcAddr.Path = path.Path()
+		svcAddr.NextHop = path.UnderlayNextHop()
+	}
+}
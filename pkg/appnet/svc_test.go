@@ -0,0 +1,105 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appnet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/sciond"
+	"github.com/scionproto/scion/go/lib/snet"
+	snetpath "github.com/scionproto/scion/go/lib/snet/path"
+	"github.com/scionproto/scion/go/lib/spath"
+)
+
+// mockQuerier is a snet.PathQuerier returning fixed paths per ISD-AS.
+type mockQuerier map[addr.IA][]snet.Path
+
+func (q mockQuerier) Query(ctx context.Context, dst addr.IA) ([]snet.Path, error) {
+	return q[dst], nil
+}
+
+// svcInfoSciond is a mock sciond.Connector answering SVCInfo requests.
+type svcInfoSciond struct {
+	sciond.Connector // not implemented
+	info             map[addr.HostSVC]string
+}
+
+func (s svcInfoSciond) SVCInfo(ctx context.Context,
+	svcTypes []addr.HostSVC) (map[addr.HostSVC]string, error) {
+
+	return s.info, nil
+}
+
+func TestResolveSVCAddr(t *testing.T) {
+	localIA := mustParseIA("1-ff00:0:110")
+	remoteIA := mustParseIA("1-ff00:0:111")
+	unreachableIA := mustParseIA("1-ff00:0:112")
+	nextHop := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 30041}
+	querier := mockQuerier{
+		remoteIA: {
+			snetpath.Path{SPath: spath.Path{Raw: []byte{1, 2, 3}}, NextHop: nextHop},
+			snetpath.Path{SPath: spath.Path{Raw: []byte{4, 5, 6}}},
+		},
+	}
+	conn := svcInfoSciond{info: map[addr.HostSVC]string{addr.SvcCS: "10.0.0.2:30252"}}
+	ctx := context.Background()
+
+	remote, err := resolveSVCAddr(ctx, Addr{IA: remoteIA, Host: addr.SvcCS}, localIA, querier, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remote.IA != remoteIA || remote.SVC != addr.SvcCS {
+		t.Errorf("unexpected destination %s", remote)
+	}
+	if !bytes.Equal(remote.Path.Raw, []byte{1, 2, 3}) || remote.NextHop.String() != nextHop.String() {
+		t.Errorf("expected first path to be set, got %v via %s", remote.Path.Raw, remote.NextHop)
+	}
+
+	local, err := resolveSVCAddr(ctx, Addr{IA: localIA, Host: addr.SvcCS.Multicast()}, localIA, querier, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local.SVC != addr.SvcCS.Multicast() || len(local.Path.Raw) != 0 {
+		t.Errorf("unexpected local address %s", local)
+	}
+	if local.NextHop == nil || local.NextHop.String() != "10.0.0.2:30252" {
+		t.Errorf("expected next hop to be the announced service instance, got %s", local.NextHop)
+	}
+
+	var noPathErr *NoServicePathError
+	_, err = resolveSVCAddr(ctx, Addr{IA: unreachableIA, Host: addr.SvcCS}, localIA, querier, conn)
+	if !errors.As(err, &noPathErr) {
+		t.Errorf("expected NoServicePathError for unreachable AS, got %v", err)
+	}
+	_, err = resolveSVCAddr(ctx, Addr{IA: localIA, Host: addr.SvcDS}, localIA, querier, conn)
+	if !errors.As(err, &noPathErr) {
+		t.Errorf("expected NoServicePathError for missing local instance, got %v", err)
+	}
+}
+
+func TestResolveSVCAddrNotService(t *testing.T) {
+	// Rejected before the network is initialized.
+	if _, err := ResolveSVCAddr("1-ff00:0:110,[192.0.2.1]:80"); err == nil {
+		t.Errorf("expected error for IP address")
+	}
+	if _, err := ResolveSVCAddr("1-ff00:0:110"); err == nil {
+		t.Errorf("expected error for malformed address")
+	}
+}
@@ -0,0 +1,359 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package doq implements a stub resolver for DNS over QUIC (RFC 9250),
+// querying DNS resolvers reachable over SCION.
+package doq
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/scionproto/scion/go/lib/snet"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
+)
+
+// ALPN is the application protocol identifier for DNS over QUIC.
+const ALPN = "doq"
+
+// DefaultTimeout is the timeout for a query to a single server, if
+// Resolver.Timeout is not set.
+const DefaultTimeout = 2 * time.Second
+
+const txtRecordPrefix = "scion="
+
+// maxCacheEntries is the maximum number of answers cached by a Resolver.
+const maxCacheEntries = 1024
+
+// exchangeFunc sends the DNS message msg to the server and returns the response.
+type exchangeFunc func(ctx context.Context, server string, msg []byte) ([]byte, error)
+
+// Resolver is a DNS stub resolver, sending queries over SCION/QUIC.
+// Responses are cached according to their TTL, for up to maxCacheEntries
+// names and types.
+//
+// Resolver implements appnet.Resolver, by looking up TXT records of the form
+// "scion=ISD-AS,[IP]", and can thus be used with appnet.ResolveUDPAddrAt.
+type Resolver struct {
+	// Servers are the SCION addresses of the DNS over QUIC servers, e.g.
+	// "1-ff00:0:110,[10.0.0.1]:853". The servers are tried in order, the next
+	// server is queried if a query fails or times out.
+	Servers []string
+	// Timeout for a query to a single server. DefaultTimeout if 0.
+	Timeout time.Duration
+
+	exchange exchangeFunc
+
+	mutex sync.Mutex
+	cache map[cacheKey]cacheEntry
+}
+
+var _ appnet.Resolver = (*Resolver)(nil)
+
+type cacheKey struct {
+	name  string
+	qtype dnsmessage.Type
+}
+
+type cacheEntry struct {
+	answers []dnsmessage.Resource
+	expires time.Time
+}
+
+// NewResolver returns a Resolver querying the given servers in order.
+func NewResolver(servers ...string) *Resolver {
+	return &Resolver{Servers: servers}
+}
+
+// LookupHost looks up the given host name and returns the IPv4 and IPv6
+// addresses found in the A and AAAA records.
+func (r *Resolver) LookupHost(ctx context.Context, name string) ([]string, error) {
+	var addrs []string
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		answers, err := r.lookup(ctx, name, qtype)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range answers {
+			switch body := a.Body.(type) {
+			case *dnsmessage.AResource:
+				addrs = append(addrs, net.IP(body.A[:]).String())
+			case *dnsmessage.AAAAResource:
+				addrs = append(addrs, net.IP(body.AAAA[:]).String())
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, &appnet.HostNotFoundError{Host: name}
+	}
+	return addrs, nil
+}
+
+// LookupSCION looks up the SCION addresses of the given host name, from TXT
+// records of the form "scion=ISD-AS,[IP]". Records that do not contain a valid
+// address are skipped.
+func (r *Resolver) LookupSCION(ctx context.Context, name string) ([]*snet.SCIONAddress, error) {
+	answers, err := r.lookup(ctx, name, dnsmessage.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []*snet.SCIONAddress
+	for _, a := range answers {
+		txt, ok := a.Body.(*dnsmessage.TXTResource)
+		if !ok {
+			continue
+		}
+		// a record may be split into multiple character strings
+		record := strings.Join(txt.TXT, "")
+		if !strings.HasPrefix(record, txtRecordPrefix) {
+			continue
+		}
+		address, err := appnet.ParseAddr(strings.TrimPrefix(record, txtRecordPrefix))
+		if err != nil {
+			continue
+		}
+		scionAddr := address.SCIONAddress()
+		addrs = append(addrs, &scionAddr)
+	}
+	if len(addrs) == 0 {
+		return nil, &appnet.HostNotFoundError{Host: name}
+	}
+	return addrs, nil
+}
+
+// Resolve implements appnet.Resolver. It returns the first address found
+// with LookupSCION.
+func (r *Resolver) Resolve(name string) (*snet.SCIONAddress, error) {
+	addrs, err := r.LookupSCION(context.Background(), name)
+	if err != nil {
+		return nil, err
+	}
+	return addrs[0], nil
+}
+
+// lookup returns the answers of the given type for name, from the cache or by
+// querying the servers.
+func (r *Resolver) lookup(ctx context.Context, name string, qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	key := cacheKey{name: strings.ToLower(name), qtype: qtype}
+	if answers, ok := r.cached(key); ok {
+		return answers, nil
+	}
+
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, err
+	}
+	query, err := (&dnsmessage.Message{
+		Header: dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: qname, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+	}).Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Servers) == 0 {
+		return nil, errors.New("doq: no servers configured")
+	}
+	var lastErr error
+	for _, server := range r.Servers {
+		answers, err := r.query(ctx, server, query, qname, qtype)
+		if err == nil {
+			r.store(key, answers)
+			return answers, nil
+		}
+		var errHostNotFound *appnet.HostNotFoundError
+		if errors.As(err, &errHostNotFound) {
+			return nil, err
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// query sends the query to a single server and returns the matching answers.
+func (r *Resolver) query(ctx context.Context, server string, query []byte,
+	qname dnsmessage.Name, qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	exchange := r.exchange
+	if exchange == nil {
+		exchange = exchangeQUIC
+	}
+	raw, err := exchange(ctx, server, query)
+	if err != nil {
+		return nil, fmt.Errorf("doq: query to %s failed: %w", server, err)
+	}
+	var resp dnsmessage.Message
+	if err := resp.Unpack(raw); err != nil {
+		return nil, fmt.Errorf("doq: invalid response from %s: %w", server, err)
+	}
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, &appnet.HostNotFoundError{Host: strings.TrimSuffix(qname.String(), ".")}
+	default:
+		return nil, fmt.Errorf("doq: query to %s failed: %s", server, resp.RCode)
+	}
+	var answers []dnsmessage.Resource
+	for _, a := range resp.Answers {
+		if a.Header.Type == qtype {
+			answers = append(answers, a)
+		}
+	}
+	return answers, nil
+}
+
+func (r *Resolver) cached(key cacheKey) ([]dnsmessage.Resource, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	entry, ok := r.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(r.cache, key)
+		return nil, false
+	}
+	return entry.answers, true
+}
+
+// store caches the answers for the minimum TTL of the records. Empty answers
+// are not cached.
+func (r *Resolver) store(key cacheKey, answers []dnsmessage.Resource) {
+	if len(answers) == 0 {
+		return
+	}
+	ttl := answers[0].Header.TTL
+	for _, a := range answers[1:] {
+		if a.Header.TTL < ttl {
+			ttl = a.Header.TTL
+		}
+	}
+	if ttl == 0 {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.cache == nil {
+		r.cache = make(map[cacheKey]cacheEntry)
+	}
+	now := time.Now()
+	if _, ok := r.cache[key]; !ok && len(r.cache) >= maxCacheEntries {
+		r.evict(now)
+	}
+	r.cache[key] = cacheEntry{
+		answers: answers,
+		expires: now.Add(time.Duration(ttl) * time.Second),
+	}
+}
+
+// evict makes room for a new cache entry, by removing the expired entries or,
+// if there are none, the entry expiring first. The mutex must be held.
+func (r *Resolver) evict(now time.Time) {
+	var first cacheKey
+	var firstExpires time.Time
+	evicted := false
+	for k, e := range r.cache {
+		if now.After(e.expires) {
+			delete(r.cache, k)
+			evicted = true
+		} else if firstExpires.IsZero() || e.expires.Before(firstExpires) {
+			first, firstExpires = k, e.expires
+		}
+	}
+	if !evicted && !firstExpires.IsZero() {
+		delete(r.cache, first)
+	}
+}
+
+// exchangeQUIC sends the DNS message msg to the server over a new QUIC
+// session, as specified in RFC 9250.
+func exchangeQUIC(ctx context.Context, server string, msg []byte) ([]byte, error) {
+	tlsCfg := &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{ALPN},
+	}
+	sess, err := appquic.DialContext(ctx, server, tlsCfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer sess.CloseWithError(0, "")
+
+	stream, err := sess.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetDeadline(deadline)
+	}
+	return exchangeStream(stream, msg)
+}
+
+// exchangeStream writes msg to the stream, closes the stream for writing and
+// reads the response. Messages are prefixed with a 2-byte length field; the
+// message ID is always 0.
+func exchangeStream(stream quic.Stream, msg []byte) ([]byte, error) {
+	if len(msg) > 0xffff {
+		return nil, errors.New("message too long")
+	}
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	copy(buf[2:], msg)
+	if _, err := stream.Write(buf); err != nil {
+		return nil, err
+	}
+	// Close only closes the send direction of the stream
+	if err := stream.Close(); err != nil {
+		return nil, err
+	}
+	return readMessage(stream)
+}
+
+func readMessage(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,294 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+)
+
+// stubServer answers queries with canned records.
+type stubServer struct {
+	records map[string][]dnsmessage.Resource
+
+	mutex   sync.Mutex
+	queries int
+}
+
+func (s *stubServer) exchange(ctx context.Context, server string, msg []byte) ([]byte, error) {
+	s.mutex.Lock()
+	s.queries++
+	s.mutex.Unlock()
+
+	var query dnsmessage.Message
+	if err := query.Unpack(msg); err != nil {
+		return nil, err
+	}
+	q := query.Questions[0]
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: query.ID, Response: true},
+		Questions: query.Questions,
+	}
+	records, ok := s.records[q.Name.String()]
+	if !ok {
+		resp.RCode = dnsmessage.RCodeNameError
+	}
+	for _, r := range records {
+		if r.Header.Type == q.Type {
+			r.Header.Name = q.Name
+			r.Header.Class = dnsmessage.ClassINET
+			resp.Answers = append(resp.Answers, r)
+		}
+	}
+	return resp.Pack()
+}
+
+func rr(ttl uint32, qtype dnsmessage.Type, body dnsmessage.ResourceBody) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Type: qtype, TTL: ttl},
+		Body:   body,
+	}
+}
+
+func newStubServer() *stubServer {
+	return &stubServer{
+		records: map[string][]dnsmessage.Resource{
+			"host.example.com.": {
+				rr(60, dnsmessage.TypeA, &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}}),
+				rr(60, dnsmessage.TypeAAAA, &dnsmessage.AAAAResource{AAAA: [16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}}),
+				rr(60, dnsmessage.TypeTXT, &dnsmessage.TXTResource{TXT: []string{"v=spf1 -all"}}),
+				rr(60, dnsmessage.TypeTXT, &dnsmessage.TXTResource{TXT: []string{"scion=garbage"}}),
+				rr(60, dnsmessage.TypeTXT, &dnsmessage.TXTResource{TXT: []string{"scion=1-ff00:0:110,[10.0.0.1]"}}),
+				rr(60, dnsmessage.TypeTXT, &dnsmessage.TXTResource{TXT: []string{"scion=1-ff00:0:111,", "[10.0.0.2]"}}),
+			},
+			"nottl.example.com.": {
+				rr(0, dnsmessage.TypeA, &dnsmessage.AResource{A: [4]byte{192, 0, 2, 2}}),
+			},
+			"empty.example.com.": {},
+		},
+	}
+}
+
+func TestLookupHost(t *testing.T) {
+	stub := newStubServer()
+	r := &Resolver{Servers: []string{"1-ff00:0:1,[127.0.0.1]:853"}, exchange: stub.exchange}
+
+	addrs, err := r.LookupHost(context.Background(), "host.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"192.0.2.1", "2001:db8::1"}
+	if !reflect.DeepEqual(addrs, expected) {
+		t.Errorf("expected %v, got %v", expected, addrs)
+	}
+
+	for _, name := range []string{"missing.example.com", "empty.example.com"} {
+		_, err = r.LookupHost(context.Background(), name)
+		var errHostNotFound *appnet.HostNotFoundError
+		if !errors.As(err, &errHostNotFound) {
+			t.Errorf("%s: expected HostNotFoundError, got %v", name, err)
+		}
+	}
+}
+
+func TestLookupSCION(t *testing.T) {
+	stub := newStubServer()
+	r := &Resolver{Servers: []string{"1-ff00:0:1,[127.0.0.1]:853"}, exchange: stub.exchange}
+
+	addrs, err := r.LookupSCION(context.Background(), "host.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"1-ff00:0:110,10.0.0.1", "1-ff00:0:111,10.0.0.2"}
+	if len(addrs) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, addrs)
+	}
+	for i := range addrs {
+		if addrs[i].String() != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], addrs[i])
+		}
+	}
+
+	// as appnet.Resolver
+	addr, err := appnet.ResolveUDPAddrAt("host.example.com:80", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.String() != "1-ff00:0:110,10.0.0.1:80" {
+		t.Errorf("unexpected address from ResolveUDPAddrAt: %s", addr)
+	}
+}
+
+func TestCache(t *testing.T) {
+	stub := newStubServer()
+	r := &Resolver{Servers: []string{"1-ff00:0:1,[127.0.0.1]:853"}, exchange: stub.exchange}
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.LookupSCION(context.Background(), "host.example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stub.queries != 1 {
+		t.Errorf("expected 1 query for cached name, got %d", stub.queries)
+	}
+
+	// records with TTL 0 must not be cached
+	stub.queries = 0
+	for i := 0; i < 3; i++ {
+		if _, err := r.LookupHost(context.Background(), "nottl.example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stub.queries != 6 { // A and AAAA
+		t.Errorf("expected 6 queries for uncacheable name, got %d", stub.queries)
+	}
+
+	// expired entries are not used
+	key := cacheKey{name: "host.example.com.", qtype: dnsmessage.TypeTXT}
+	entry := r.cache[key]
+	entry.expires = time.Now().Add(-time.Second)
+	r.cache[key] = entry
+	stub.queries = 0
+	if _, err := r.LookupSCION(context.Background(), "host.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if stub.queries != 1 {
+		t.Errorf("expected query after expiry, got %d", stub.queries)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	r := &Resolver{}
+	answers := func(ttl uint32) []dnsmessage.Resource {
+		return []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeTXT, TTL: ttl},
+			Body:   &dnsmessage.TXTResource{TXT: []string{"scion=1-ff00:0:1,[127.0.0.1]"}},
+		}}
+	}
+	key := func(i int) cacheKey {
+		return cacheKey{name: fmt.Sprintf("host%d.example.com.", i), qtype: dnsmessage.TypeTXT}
+	}
+	// the first entry expires first
+	r.store(key(0), answers(10))
+	for i := 1; i < maxCacheEntries; i++ {
+		r.store(key(i), answers(100))
+	}
+	r.store(key(maxCacheEntries), answers(100))
+	if len(r.cache) != maxCacheEntries {
+		t.Fatalf("expected %d cache entries, got %d", maxCacheEntries, len(r.cache))
+	}
+	if _, ok := r.cached(key(0)); ok {
+		t.Errorf("expected entry expiring first to be evicted")
+	}
+	if _, ok := r.cached(key(maxCacheEntries)); !ok {
+		t.Errorf("expected new entry to be cached")
+	}
+
+	// expired entries are evicted first
+	for _, i := range []int{5, 6} {
+		entry := r.cache[key(i)]
+		entry.expires = time.Now().Add(-time.Second)
+		r.cache[key(i)] = entry
+	}
+	r.store(key(maxCacheEntries+1), answers(100))
+	if len(r.cache) != maxCacheEntries-1 {
+		t.Errorf("expected %d cache entries, got %d", maxCacheEntries-1, len(r.cache))
+	}
+
+	// replacing an entry does not evict others
+	r.store(key(maxCacheEntries+2), answers(100))
+	r.store(key(maxCacheEntries+2), answers(200))
+	if len(r.cache) != maxCacheEntries {
+		t.Errorf("expected %d cache entries, got %d", maxCacheEntries, len(r.cache))
+	}
+}
+
+func TestFailover(t *testing.T) {
+	stub := newStubServer()
+	var tried []string
+	exchange := func(ctx context.Context, server string, msg []byte) ([]byte, error) {
+		tried = append(tried, server)
+		switch server {
+		case "unreachable":
+			return nil, errors.New("no path")
+		case "slow":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return stub.exchange(ctx, server, msg)
+	}
+	r := &Resolver{
+		Servers:  []string{"unreachable", "slow", "good"},
+		Timeout:  10 * time.Millisecond,
+		exchange: exchange,
+	}
+	if _, err := r.LookupSCION(context.Background(), "host.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tried, []string{"unreachable", "slow", "good"}) {
+		t.Errorf("unexpected servers tried: %v", tried)
+	}
+
+	// no failover for NXDOMAIN
+	tried = nil
+	r.Servers = []string{"good", "other"}
+	_, err := r.LookupSCION(context.Background(), "missing.example.com")
+	var errHostNotFound *appnet.HostNotFoundError
+	if !errors.As(err, &errHostNotFound) {
+		t.Errorf("expected HostNotFoundError, got %v", err)
+	}
+	if len(tried) != 1 {
+		t.Errorf("expected only first server to be queried, got %v", tried)
+	}
+
+	// cancelled context stops failover
+	tried = nil
+	r.Servers = []string{"unreachable", "good"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.exchange = func(ctx context.Context, server string, msg []byte) ([]byte, error) {
+		tried = append(tried, server)
+		return nil, ctx.Err()
+	}
+	if _, err := r.LookupSCION(ctx, "other.example.com"); err == nil {
+		t.Error("expected error for cancelled context")
+	}
+	if len(tried) != 1 {
+		t.Errorf("expected only first server to be queried, got %v", tried)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	msg, err := readMessage(bytes.NewReader([]byte{0, 3, 'f', 'o', 'o', 'x'}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg) != "foo" {
+		t.Errorf("expected 'foo', got '%s'", msg)
+	}
+	if _, err := readMessage(bytes.NewReader([]byte{0, 3, 'f'})); err == nil {
+		t.Error("expected error for truncated message")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grpcscion allows to run gRPC services over SCION.
+// Each gRPC connection (HTTP/2) runs over a single QUIC stream, see
+// appquic.StreamConn.
+// As in the other packages of scion-apps, the QUIC connection is encrypted
+// but the server certificate is not verified. The gRPC transport credentials
+// are therefore not used by default.
+package grpcscion
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	"google.golang.org/grpc"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+// NextProto is the application protocol negotiated for the QUIC sessions.
+const NextProto = "grpc-scion"
+
+// PathSelector chooses the path to the destination IA for a new connection,
+// e.g. appnet.ChoosePathByMetric. If it returns a nil path, the default path
+// is used.
+type PathSelector func(dst addr.IA) (snet.Path, error)
+
+// pathSelectorOption is the grpc.DialOption returned by WithPathSelector. It
+// does not alter the grpc configuration, but is picked up by Dial.
+type pathSelectorOption struct {
+	grpc.EmptyDialOption
+	selector PathSelector
+}
+
+// WithPathSelector returns a DialOption that sets the function choosing the
+// path for each connection to the server. By default, the first path to the
+// destination is used.
+func WithPathSelector(selector PathSelector) grpc.DialOption {
+	return pathSelectorOption{selector: selector}
+}
+
+// Dial creates a client connection to the gRPC server at the SCION address
+// target, of the form "ISD-AS,[IP]:port" or hostname:port, see
+// appnet.ResolveUDPAddr.
+// The options are passed to grpc.Dial, where the dialer is set to connect
+// over SCION.
+func Dial(target string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	return DialContext(context.Background(), target, opts...)
+}
+
+// DialContext is like Dial, but with a context, see grpc.DialContext.
+func DialContext(ctx context.Context, target string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	d := &dialer{
+		tlsConf: &tls.Config{
+			InsecureSkipVerify: true,
+			NextProtos:         []string{NextProto},
+		},
+	}
+	for _, o := range opts {
+		if p, ok := o.(pathSelectorOption); ok {
+			d.selector = p.selector
+		}
+	}
+	// The options given by the caller override the default credentials
+	opts = append([]grpc.DialOption{grpc.WithInsecure()}, opts...)
+	opts = append(opts, grpc.WithContextDialer(d.dial))
+	// The passthrough resolver hands the target to the dialer as is
+	return grpc.DialContext(ctx, "passthrough:///"+target, opts...)
+}
+
+type dialer struct {
+	selector PathSelector
+	tlsConf  *tls.Config
+}
+
+// dial opens a QUIC stream to the address. The QUIC handshake is aborted when
+// ctx is done.
+func (d *dialer) dial(ctx context.Context, address string) (net.Conn, error) {
+	raddr, err := appnet.ResolveUDPAddr(address)
+	if err != nil {
+		return nil, err
+	}
+	if d.selector != nil {
+		path, err := d.selector(raddr.IA)
+		if err != nil {
+			return nil, err
+		}
+		if path != nil {
+			appnet.SetPath(raddr, path)
+		}
+	}
+	return appquic.DialAddrStreamContext(ctx, raddr, address, d.tlsConf, nil)
+}
+
+// NewListener listens for gRPC connections over SCION on the given port. The
+// returned listener can be passed to grpc.Server.Serve.
+//
+// See note on wildcard addresses in the appnet package documentation.
+func NewListener(port uint16) (net.Listener, error) {
+	tlsConf := &tls.Config{
+		Certificates: appquic.GetDummyTLSCerts(),
+		NextProtos:   []string{NextProto},
+	}
+	return appquic.ListenStream(port, tlsConf, nil)
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scionecho
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+
+	"github.com/lucas-clemente/quic-go"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
+)
+
+// quicServer creates a QUIC listener on conn and returns the function
+// serving it.
+func (s *Server) quicServer(conn net.PacketConn) (func(), error) {
+	listener, err := quic.Listen(
+		conn,
+		&tls.Config{
+			Certificates: appquic.GetDummyTLSCerts(),
+			NextProtos:   []string{NextProto},
+		},
+		&quic.Config{KeepAlive: true},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		s.serveQUIC(listener)
+	}, nil
+}
+
+func (s *Server) serveQUIC(listener quic.Listener) {
+	defer listener.Close()
+	for {
+		sess, err := listener.Accept(context.Background())
+		if err != nil {
+			return
+		}
+		go func() {
+			for {
+				stream, err := sess.AcceptStream(context.Background())
+				if err != nil {
+					return
+				}
+				go s.serveStream(stream)
+			}
+		}()
+	}
+}
+
+func (s *Server) serveStream(stream quic.Stream) {
+	defer stream.Close()
+	switch s.Service {
+	case Echo:
+		_, _ = io.Copy(stream, stream)
+	case Discard:
+		_, _ = io.Copy(ioutil.Discard, stream)
+	case Chargen:
+		// The client ends the stream by cancelling reading, after which
+		// writing fails.
+		go func() {
+			_, _ = io.Copy(ioutil.Discard, stream)
+		}()
+		var chargen chargenState
+		for {
+			if _, err := stream.Write(chargen.nextLine()); err != nil {
+				return
+			}
+		}
+	}
+}
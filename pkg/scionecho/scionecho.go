@@ -0,0 +1,217 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scionecho provides in-process echo, discard and chargen servers
+// (RFC 862, 863, 864) over SCION/UDP and QUIC, to test SCION clients against.
+//
+// A server is started with Start, which listens on an ephemeral port and
+// returns the SCION address of the server:
+//   srv := &scionecho.Server{Service: scionecho.Echo, QUIC: true}
+//   addr, err := srv.Start()
+//   ...
+//   defer srv.Close()
+// For resilience tests, Latency and Loss can be injected into the packets
+// sent by the server.
+package scionecho
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/snet"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+)
+
+// Service is the service provided by a Server.
+type Service int
+
+const (
+	// Echo sends back all data received.
+	Echo Service = iota
+	// Discard throws away all data received.
+	Discard
+	// Chargen sends a stream of characters. Over UDP, a datagram with a line
+	// of characters is sent in response to every datagram received.
+	Chargen
+)
+
+// NextProto is the application protocol negotiated by the QUIC servers.
+const NextProto = "scionecho"
+
+const maxPacketSize = 65536
+
+// Server is an echo, discard or chargen server.
+type Server struct {
+	Service Service
+	// QUIC selects QUIC instead of UDP. Over QUIC, the service is provided
+	// on every stream opened by the client.
+	QUIC bool
+	// Latency delays every packet sent by the server.
+	Latency time.Duration
+	// Loss is the probability, in [0, 1], that a packet sent by the server is
+	// dropped.
+	Loss float64
+
+	mutex  sync.Mutex
+	conn   net.PacketConn
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Start listens on an ephemeral SCION/UDP port and serves in the background.
+// Returns the address of the server.
+func (s *Server) Start() (*snet.UDPAddr, error) {
+	conn, err := appnet.Listen(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.start(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &snet.UDPAddr{
+		IA:   appnet.DefNetwork().IA,
+		Host: conn.LocalAddr().(*net.UDPAddr),
+	}, nil
+}
+
+// Serve serves on the given connection in the background, e.g. on a plain UDP
+// socket in tests that cannot use SCION. The connection is closed by Close.
+func (s *Server) Serve(conn net.PacketConn) error {
+	return s.start(conn)
+}
+
+func (s *Server) start(conn net.PacketConn) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return errors.New("server closed")
+	}
+	if s.conn != nil {
+		return errors.New("server already started")
+	}
+	if s.Loss > 0 || s.Latency > 0 {
+		conn = &impairedConn{PacketConn: conn, latency: s.Latency, loss: s.Loss, closed: make(chan struct{})}
+	}
+	s.conn = conn
+
+	serve := s.serveUDP
+	if s.QUIC {
+		var err error
+		serve, err = s.quicServer(conn)
+		if err != nil {
+			return err
+		}
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		serve()
+	}()
+	return nil
+}
+
+// Close stops the server and waits until the serving goroutines have
+// terminated.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	conn := s.conn
+	s.mutex.Unlock()
+	var err error
+	if conn != nil {
+		err = conn.Close()
+	}
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveUDP() {
+	buf := make([]byte, maxPacketSize)
+	var chargen chargenState
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		switch s.Service {
+		case Echo:
+			_, _ = s.conn.WriteTo(buf[:n], addr)
+		case Chargen:
+			_, _ = s.conn.WriteTo(chargen.nextLine(), addr)
+		}
+	}
+}
+
+// chargenState produces the lines of the chargen pattern: each line consists
+// of 72 printable ASCII characters, starting one character after the start
+// of the previous line.
+type chargenState struct {
+	offset int
+}
+
+const (
+	chargenFirst   = ' '
+	chargenChars   = '~' - ' ' + 1
+	chargenLineLen = 72
+)
+
+func (c *chargenState) nextLine() []byte {
+	line := make([]byte, chargenLineLen+2)
+	for i := 0; i < chargenLineLen; i++ {
+		line[i] = byte(chargenFirst + (c.offset+i)%chargenChars)
+	}
+	line[chargenLineLen] = '\r'
+	line[chargenLineLen+1] = '\n'
+	c.offset = (c.offset + 1) % chargenChars
+	return line
+}
+
+// impairedConn delays and randomly drops the packets written to it.
+type impairedConn struct {
+	net.PacketConn
+	latency time.Duration
+	loss    float64
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (c *impairedConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if c.loss > 0 && rand.Float64() < c.loss {
+		return len(b), nil
+	}
+	if c.latency <= 0 {
+		return c.PacketConn.WriteTo(b, addr)
+	}
+	// Send asynchronously, so that the latency does not limit the throughput.
+	p := append([]byte(nil), b...)
+	time.AfterFunc(c.latency, func() {
+		select {
+		case <-c.closed:
+		default:
+			_, _ = c.PacketConn.WriteTo(p, addr)
+		}
+	})
+	return len(b), nil
+}
+
+func (c *impairedConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.PacketConn.Close()
+}
@@ -0,0 +1,157 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scionecho
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+// As there is no SCION network in unit tests, the servers are run on plain
+// UDP sockets on loopback.
+
+func startUDP(t *testing.T, s *Server) (*net.UDPConn, net.Addr) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Serve(serverConn); err != nil {
+		t.Fatal(err)
+	}
+	clientConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientConn, serverConn.LocalAddr()
+}
+
+// roundTrip sends msg to the server and returns the response and the round
+// trip time.
+func roundTrip(t *testing.T, conn *net.UDPConn, server net.Addr, msg []byte) ([]byte, time.Duration, error) {
+	start := time.Now()
+	if _, err := conn.WriteTo(msg, server); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, maxPacketSize)
+	n, _, err := conn.ReadFrom(buf)
+	return buf[:n], time.Since(start), err
+}
+
+func TestEchoUDP(t *testing.T) {
+	s := &Server{Service: Echo}
+	conn, server := startUDP(t, s)
+	defer conn.Close()
+	defer s.Close()
+
+	msg := []byte("hello")
+	resp, _, err := roundTrip(t, conn, server, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resp, msg) {
+		t.Errorf("expected echo %q, got %q", msg, resp)
+	}
+}
+
+func TestLatencyUDP(t *testing.T) {
+	const latency = 100 * time.Millisecond
+	s := &Server{Service: Echo, Latency: latency}
+	conn, server := startUDP(t, s)
+	defer conn.Close()
+	defer s.Close()
+
+	_, rtt, err := roundTrip(t, conn, server, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rtt < latency {
+		t.Errorf("expected round trip time of at least %v, got %v", latency, rtt)
+	}
+}
+
+func TestLossUDP(t *testing.T) {
+	s := &Server{Service: Echo, Loss: 1}
+	conn, server := startUDP(t, s)
+	defer conn.Close()
+	defer s.Close()
+
+	if _, _, err := roundTrip(t, conn, server, []byte("hello")); err == nil {
+		t.Errorf("expected response to be dropped")
+	}
+}
+
+func TestChargenUDP(t *testing.T) {
+	s := &Server{Service: Chargen}
+	conn, server := startUDP(t, s)
+	defer conn.Close()
+	defer s.Close()
+
+	first, _, err := roundTrip(t, conn, server, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := roundTrip(t, conn, server, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != chargenLineLen+2 || !bytes.HasSuffix(first, []byte("\r\n")) {
+		t.Errorf("unexpected chargen line %q", first)
+	}
+	if !bytes.Equal(first[1:chargenLineLen], second[:chargenLineLen-1]) {
+		t.Errorf("expected second line to be shifted by one: %q, %q", first, second)
+	}
+}
+
+func TestEchoQUIC(t *testing.T) {
+	s := &Server{Service: Echo, QUIC: true}
+	conn, server := startUDP(t, s)
+	conn.Close()
+	defer s.Close()
+
+	sess, err := quic.DialAddr(server.String(),
+		&tls.Config{InsecureSkipVerify: true, NextProtos: []string{NextProto}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.CloseWithError(0, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := sess.OpenStreamSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("hello over QUIC")
+	if _, err := stream.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	stream.Close()
+	resp, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resp, msg) {
+		t.Errorf("expected echo %q, got %q", msg, resp)
+	}
+}
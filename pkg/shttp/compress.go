@@ -0,0 +1,265 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shttp
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CompressMinSize is the minimum size of a response body to be compressed by
+// CompressHandler. Smaller bodies fit into a single packet even with the
+// minimum MTU of SCION paths over IPv6, so compressing them does not save any
+// packets.
+const CompressMinSize = 1024
+
+// uncompressibleTypes are the content types, or prefixes thereof, of already
+// compressed content, which is not compressed again.
+var uncompressibleTypes = []string{
+	"image/",
+	"audio/",
+	"video/",
+	"font/woff",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/zstd",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+}
+
+// compressibleImageTypes are the exceptions to uncompressibleTypes.
+var compressibleImageTypes = []string{
+	"image/svg+xml",
+	"image/bmp",
+	"image/x-icon",
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// CompressHandler returns a handler that compresses the responses of h with
+// gzip, if the client accepts it as indicated by the Accept-Encoding header.
+// Responses that are already encoded, that have an already compressed content
+// type (e.g. images or archives), or that are smaller than CompressMinSize are
+// passed through unchanged.
+//
+// Flushing the response, see http.Flusher, flushes the compressed data written
+// so far, so that streaming responses are delivered incrementally.
+func CompressHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, status: http.StatusOK}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// acceptsGzip returns whether the Accept-Encoding header value allows gzip.
+// An explicit entry for gzip takes precedence over the wildcard.
+func acceptsGzip(acceptEncoding string) bool {
+	gzipOK, wildcardOK := false, false
+	explicit := false
+	for _, entry := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(entry, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		ok := true
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				q, err := strconv.ParseFloat(p[2:], 64)
+				ok = err == nil && q > 0
+			}
+		}
+		switch coding {
+		case "gzip", "x-gzip":
+			explicit = true
+			gzipOK = ok
+		case "*":
+			wildcardOK = ok
+		}
+	}
+	if explicit {
+		return gzipOK
+	}
+	return wildcardOK
+}
+
+// compressWriter buffers the beginning of the response until it can decide
+// whether to compress it: once CompressMinSize bytes have been written, the
+// response is flushed or the handler returns.
+type compressWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool // WriteHeader has been called by the handler
+	decided     bool
+	buf         []byte
+	gz          *gzip.Writer
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = status
+	if !cw.canCompress() {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.gz != nil {
+			return cw.gz.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= CompressMinSize {
+		if err := cw.decideAndWrite(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush writes the buffered data and flushes the compressed data written so
+// far to the client.
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		// The size of a streamed response is unknown, so only the content type
+		// is taken into account.
+		_ = cw.decideAndWrite(true)
+	}
+	if cw.gz != nil {
+		_ = cw.gz.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// close completes the response after the handler has returned.
+func (cw *compressWriter) close() {
+	if !cw.wroteHeader {
+		if len(cw.buf) == 0 {
+			return // nothing written; leave the default response to the server
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		_ = cw.decideAndWrite(len(cw.buf) >= CompressMinSize)
+	}
+	if cw.gz != nil {
+		_ = cw.gz.Close()
+		gzipWriterPool.Put(cw.gz)
+		cw.gz = nil
+	}
+}
+
+// canCompress returns whether the response can be compressed, based on the
+// status and the headers set by the handler.
+func (cw *compressWriter) canCompress() bool {
+	switch {
+	case cw.status < 200, cw.status == http.StatusNoContent, cw.status == http.StatusNotModified:
+		return false
+	case cw.status == http.StatusPartialContent:
+		// The ranges refer to the uncompressed content
+		return false
+	}
+	hdr := cw.Header()
+	if hdr.Get("Content-Encoding") != "" {
+		return false
+	}
+	if cl, err := strconv.ParseInt(hdr.Get("Content-Length"), 10, 64); err == nil && cl < CompressMinSize {
+		return false
+	}
+	if ct := hdr.Get("Content-Type"); ct != "" && !compressibleType(ct) {
+		return false
+	}
+	return true
+}
+
+// decideAndWrite decides whether to compress, sends the header and writes the
+// buffered data.
+func (cw *compressWriter) decideAndWrite(compress bool) error {
+	hdr := cw.Header()
+	if hdr.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// Detect the type from the uncompressed data, as the server would
+		// otherwise detect it from the compressed data.
+		hdr.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	cw.decide(compress && cw.canCompress())
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.gz != nil {
+		_, err = cw.gz.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (cw *compressWriter) decide(compress bool) {
+	cw.decided = true
+	if compress {
+		hdr := cw.Header()
+		hdr.Set("Content-Encoding", "gzip")
+		hdr.Del("Content-Length")
+		cw.gz = gzipWriterPool.Get().(*gzip.Writer)
+		cw.gz.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+func compressibleType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	for _, t := range compressibleImageTypes {
+		if mediaType == t {
+			return true
+		}
+	}
+	for _, t := range uncompressibleTypes {
+		if strings.HasPrefix(mediaType, t) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shttp
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressHandler(t *testing.T) {
+	jsonBody := "[" + strings.Repeat(`{"key": "value"},`, 200) + "{}]"
+	cases := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+		expectGzip     bool
+	}{
+		{"gzip accepted", "gzip, deflate", "application/json", jsonBody, true},
+		{"wildcard accepted", "*", "application/json", jsonBody, true},
+		{"sniffed content type", "gzip", "", jsonBody, true},
+		{"not accepted", "", "application/json", jsonBody, false},
+		{"other encoding", "br", "application/json", jsonBody, false},
+		{"gzip refused", "gzip;q=0, *", "application/json", jsonBody, false},
+		{"small body", "gzip", "application/json", `{"key": "value"}`, false},
+		{"compressed type", "gzip", "image/png", jsonBody, false},
+		{"svg", "gzip", "image/svg+xml", jsonBody, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			handler := CompressHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if c.contentType != "" {
+					w.Header().Set("Content-Type", c.contentType)
+				}
+				// write in pieces, to exercise the buffering
+				for i := 0; i < len(c.body); i += 100 {
+					end := i + 100
+					if end > len(c.body) {
+						end = len(c.body)
+					}
+					_, _ = io.WriteString(w, c.body[i:end])
+				}
+			}))
+			r := httptest.NewRequest("GET", "/", nil)
+			if c.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", c.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+			}
+			if w.Header().Get("Content-Type") == "" {
+				t.Errorf("expected Content-Type to be set")
+			}
+			encoding := w.Header().Get("Content-Encoding")
+			if c.expectGzip != (encoding == "gzip") {
+				t.Fatalf("expected gzip: %v, got Content-Encoding %q", c.expectGzip, encoding)
+			}
+			body := w.Body.Bytes()
+			if c.expectGzip {
+				if len(body) >= len(c.body) {
+					t.Errorf("expected compressed body, got %d bytes for %d bytes", len(body), len(c.body))
+				}
+				zr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = ioutil.ReadAll(zr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if string(body) != c.body {
+				t.Errorf("unexpected body %q", body)
+			}
+		})
+	}
+}
+
+// TestCompressHandlerFlush checks that the data written before a flush can be
+// decompressed by the client before the response is complete.
+func TestCompressHandlerFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler := CompressHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(rw, "first event\n")
+		rw.(http.Flusher).Flush()
+
+		if !w.Flushed {
+			t.Errorf("expected response to be flushed")
+		}
+		zr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len("first event\n"))
+		if _, err := io.ReadFull(zr, buf); err != nil {
+			t.Fatalf("cannot decompress flushed data: %s", err)
+		}
+		if string(buf) != "first event\n" {
+			t.Errorf("unexpected flushed data %q", buf)
+		}
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("expected streamed response to be compressed")
+	}
+}
+
+func TestCompressHandlerStatus(t *testing.T) {
+	handler := CompressHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, w.Code)
+	}
+	if w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 0 {
+		t.Errorf("expected empty, unencoded response")
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shttp
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"github.com/lucas-clemente/quic-go/http3"
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+)
+
+// ServeDual serves handler both over SCION, with HTTP/3 on the SCION address
+// scionAddr, and over TCP, with a standard http.Server on tcpAddr.
+// The TCP server uses TLS if tlsConfig contains certificates, and plain HTTP
+// otherwise. On SCION, dummy certificates are used if none are configured, as
+// in Server.Serve.
+//
+// ServeDual returns once both listeners are established, the requests are
+// handled in the background. The returned function closes both servers.
+// Use IsSCION to find out which transport a request arrived on.
+func ServeDual(scionAddr, tcpAddr string, handler http.Handler,
+	tlsConfig *tls.Config) (func() error, error) {
+
+	laddr, err := net.ResolveUDPAddr("udp", scionAddr)
+	if err != nil {
+		return nil, err
+	}
+	sconn, err := appnet.Listen(laddr)
+	if err != nil {
+		return nil, err
+	}
+	tcpListener, err := net.Listen("tcp", tcpAddr)
+	if err != nil {
+		sconn.Close()
+		return nil, err
+	}
+	return serveDual(sconn, tcpListener, handler, tlsConfig), nil
+}
+
+func serveDual(sconn net.PacketConn, tcpListener net.Listener, handler http.Handler,
+	tlsConfig *tls.Config) func() error {
+
+	scionServer := &Server{
+		Server: &http3.Server{
+			Server: &http.Server{
+				Handler: handler,
+			},
+		},
+	}
+	tcpServer := &http.Server{
+		Handler: handler,
+	}
+	// the configs are modified by the servers, don't share them
+	if tlsConfig != nil {
+		scionServer.TLSConfig = tlsConfig.Clone()
+		tcpServer.TLSConfig = tlsConfig.Clone()
+	}
+
+	go func() {
+		_ = scionServer.Serve(sconn)
+	}()
+	go func() {
+		if tcpServer.TLSConfig != nil && len(tcpServer.TLSConfig.Certificates) > 0 {
+			_ = tcpServer.ServeTLS(tcpListener, "", "")
+		} else {
+			_ = tcpServer.Serve(tcpListener)
+		}
+	}()
+
+	return func() error {
+		errS := scionServer.Close()
+		// the http3 server does not close the conn passed to Serve
+		errC := sconn.Close()
+		errT := tcpServer.Close()
+		if errS != nil {
+			return errS
+		}
+		if errC != nil {
+			return errC
+		}
+		return errT
+	}
+}
+
+// IsSCION returns whether the request was received over SCION, i.e. whether
+// the remote address of the request is a SCION address.
+func IsSCION(r *http.Request) bool {
+	_, err := appnet.ParseUDPAddr(r.RemoteAddr)
+	return err == nil
+}
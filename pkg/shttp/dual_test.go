@@ -0,0 +1,95 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shttp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/lucas-clemente/quic-go/http3"
+)
+
+func TestIsSCION(t *testing.T) {
+	testCases := []struct {
+		RemoteAddr string
+		Expected   bool
+	}{
+		{"1-ff00:0:110,[127.0.0.1]:40000", true},
+		{"1-ff00:0:110,[::1]:40000", true},
+		{"1-ff00:0:110,[fe80::1%eth0]:40000", true},
+		{"[fe80::1%eth0]:40000", false},
+		{"127.0.0.1:40000", false},
+		{"[::1]:40000", false},
+		{"", false},
+	}
+	for _, tc := range testCases {
+		r := &http.Request{RemoteAddr: tc.RemoteAddr}
+		if actual := IsSCION(r); actual != tc.Expected {
+			t.Errorf("IsSCION for RemoteAddr '%s', expected: %v, actual: %v", tc.RemoteAddr, tc.Expected, actual)
+		}
+	}
+}
+
+// TestServeDual checks that the handler is reachable on both listeners. As
+// there is no SCION network in unit tests, the HTTP/3 server is run on a plain
+// UDP socket instead.
+func TestServeDual(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %v", r.Proto, IsSCION(r))
+	})
+
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shutdown := serveDual(udpConn, tcpListener, handler, nil)
+	defer shutdown()
+
+	get := func(client *http.Client, url string) string {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	tcpBody := get(http.DefaultClient, fmt.Sprintf("http://%s/", tcpListener.Addr()))
+	if tcpBody != "HTTP/1.1 false" {
+		t.Errorf("unexpected response over TCP: '%s'", tcpBody)
+	}
+
+	quicClient := &http.Client{
+		Transport: &http3.RoundTripper{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	quicBody := get(quicClient, fmt.Sprintf("https://%s/", udpConn.LocalAddr()))
+	if quicBody != "HTTP/3 false" {
+		t.Errorf("unexpected response over QUIC: '%s'", quicBody)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shttp
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// FileServer returns a handler that serves the files in root, like
+// http.FileServer, with support for resuming interrupted downloads, e.g. after
+// the path to the client failed.
+//
+// Range requests, also with multiple ranges, are answered with 206 Partial
+// Content and the corresponding Content-Range headers; all responses for
+// files advertise Accept-Ranges: bytes. The files are streamed from root and
+// never read into memory as a whole.
+// In addition to Last-Modified, each file has a strong ETag derived from its
+// modification time and size, so that a client can resume a download with
+// If-Range and receive the whole file again if it has changed in between.
+//
+// Directories are served as by http.FileServer.
+func FileServer(root http.FileSystem) http.Handler {
+	return &fileHandler{root: root, dirs: http.FileServer(root)}
+}
+
+type fileHandler struct {
+	root http.FileSystem
+	dirs http.Handler
+}
+
+func (h *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upath := r.URL.Path
+	if !strings.HasPrefix(upath, "/") {
+		upath = "/" + upath
+	}
+	// Leave redirects (e.g. of .../index.html) and directories to http.FileServer
+	if strings.HasSuffix(upath, "/") || strings.HasSuffix(upath, "/index.html") {
+		h.dirs.ServeHTTP(w, r)
+		return
+	}
+	f, err := h.root.Open(path.Clean(upath))
+	if err != nil {
+		h.dirs.ServeHTTP(w, r) // for the error response
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		h.dirs.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	if w.Header().Get("Etag") == "" {
+		w.Header().Set("Etag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
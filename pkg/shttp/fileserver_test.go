@@ -0,0 +1,187 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// testFileContent returns n bytes of content, in which every position is
+// distinguishable.
+func testFileContent(n int) []byte {
+	content := make([]byte, n)
+	for i := range content {
+		content[i] = byte(i * 7 % 251)
+	}
+	return content
+}
+
+// writeTestFile writes content to firmware.bin in a new temporary directory,
+// and returns the directory.
+func writeTestFile(t *testing.T, content []byte) string {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "firmware.bin"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func serveTestFile(dir string, header http.Header) *http.Response {
+	req := httptest.NewRequest("GET", "/firmware.bin", nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	FileServer(http.Dir(dir)).ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func TestFileServer(t *testing.T) {
+	content := testFileContent(100000)
+	dir := writeTestFile(t, content)
+	resp := serveTestFile(dir, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Accept-Ranges") != "bytes" {
+		t.Errorf("expected Accept-Ranges: bytes, got %q", resp.Header.Get("Accept-Ranges"))
+	}
+	if resp.Header.Get("Etag") == "" {
+		t.Errorf("expected an ETag")
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if !bytes.Equal(body, content) {
+		t.Errorf("unexpected body of %d bytes", len(body))
+	}
+}
+
+func TestFileServerRange(t *testing.T) {
+	content := testFileContent(100000)
+	dir := writeTestFile(t, content)
+	cases := []struct {
+		rng        string
+		start, end int // inclusive
+	}{
+		{"bytes=0-99", 0, 99},
+		{"bytes=50000-", 50000, 99999},
+		{"bytes=-1000", 99000, 99999},
+		{"bytes=99999-200000", 99999, 99999},
+	}
+	for _, c := range cases {
+		t.Run(c.rng, func(t *testing.T) {
+			resp := serveTestFile(dir, http.Header{"Range": {c.rng}})
+			if resp.StatusCode != http.StatusPartialContent {
+				t.Fatalf("expected status 206, got %d", resp.StatusCode)
+			}
+			expectedRange := fmt.Sprintf("bytes %d-%d/%d", c.start, c.end, len(content))
+			if resp.Header.Get("Content-Range") != expectedRange {
+				t.Errorf("expected Content-Range %q, got %q", expectedRange, resp.Header.Get("Content-Range"))
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			if !bytes.Equal(body, content[c.start:c.end+1]) {
+				t.Errorf("unexpected body of %d bytes", len(body))
+			}
+		})
+	}
+
+	resp := serveTestFile(dir, http.Header{"Range": {"bytes=100000-"}})
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("expected status 416 for unsatisfiable range, got %d", resp.StatusCode)
+	}
+}
+
+func TestFileServerMultiRange(t *testing.T) {
+	content := testFileContent(100000)
+	dir := writeTestFile(t, content)
+	resp := serveTestFile(dir, http.Header{"Range": {"bytes=0-9,5000-5999,-10"}})
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("expected status 206, got %d", resp.StatusCode)
+	}
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("expected multipart/byteranges, got %q", resp.Header.Get("Content-Type"))
+	}
+	expected := []struct{ start, end int }{{0, 9}, {5000, 5999}, {99990, 99999}}
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	for _, e := range expected {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedRange := fmt.Sprintf("bytes %d-%d/%d", e.start, e.end, len(content))
+		if part.Header.Get("Content-Range") != expectedRange {
+			t.Errorf("expected Content-Range %q, got %q", expectedRange, part.Header.Get("Content-Range"))
+		}
+		body, _ := ioutil.ReadAll(part)
+		if !bytes.Equal(body, content[e.start:e.end+1]) {
+			t.Errorf("unexpected body of part %q", expectedRange)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("expected %d parts", len(expected))
+	}
+}
+
+func TestFileServerIfRange(t *testing.T) {
+	content := testFileContent(1000)
+	dir := writeTestFile(t, content)
+	etag := serveTestFile(dir, nil).Header.Get("Etag")
+
+	// resume with matching ETag
+	resp := serveTestFile(dir, http.Header{"Range": {"bytes=500-"}, "If-Range": {etag}})
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Errorf("expected status 206 for matching If-Range, got %d", resp.StatusCode)
+	}
+	// file has changed in between, the whole file is sent
+	resp = serveTestFile(dir, http.Header{"Range": {"bytes=500-"}, "If-Range": {`"outdated"`}})
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200 for outdated If-Range, got %d", resp.StatusCode)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if !bytes.Equal(body, content) {
+		t.Errorf("expected the whole file for outdated If-Range")
+	}
+}
+
+func TestFileServerCompressed(t *testing.T) {
+	content := bytes.Repeat([]byte("compressible "), 1000)
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "file.txt"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/file.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=10-19")
+	rec := httptest.NewRecorder()
+	CompressHandler(FileServer(http.Dir(dir))).ServeHTTP(rec, req)
+	resp := rec.Result()
+	if resp.StatusCode != http.StatusPartialContent || resp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("expected uncompressed partial content, got %d %q",
+			resp.StatusCode, resp.Header.Get("Content-Encoding"))
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if !bytes.Equal(body, content[10:20]) {
+		t.Errorf("unexpected body %q", body)
+	}
+}
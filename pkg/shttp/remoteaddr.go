@@ -0,0 +1,89 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httputil"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+// ForwardedForHeader is the header in which a reverse proxy, set up with
+// ForwardSCIONAddr, passes the SCION address of the client to the backend.
+const ForwardedForHeader = "X-Forwarded-For-Scion"
+
+type remoteAddrKey struct{}
+
+// RemoteSCIONAddr returns the SCION address of the client that sent the
+// request, or false if the request was not received over SCION.
+// For requests forwarded by a reverse proxy, the address of the original
+// client is returned if the handler is wrapped with TrustForwardedSCIONAddr.
+func RemoteSCIONAddr(r *http.Request) (*snet.UDPAddr, bool) {
+	if addr, ok := r.Context().Value(remoteAddrKey{}).(*snet.UDPAddr); ok {
+		return addr.Copy(), true
+	}
+	addr, err := appnet.ParseUDPAddr(r.RemoteAddr)
+	if err != nil {
+		return nil, false
+	}
+	return addr, true
+}
+
+// withRemoteSCIONAddr returns a handler that adds the SCION address of the
+// client to the request context and sets RemoteAddr to its canonical string
+// representation, before calling h.
+func withRemoteSCIONAddr(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, err := appnet.ParseUDPAddr(r.RemoteAddr); err == nil {
+			r = r.WithContext(context.WithValue(r.Context(), remoteAddrKey{}, addr))
+			r.RemoteAddr = addr.String()
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// ForwardSCIONAddr configures the reverse proxy to pass the SCION address of
+// the client to the backend in the ForwardedForHeader. The header is removed
+// from requests not received over SCION, so that clients cannot set it.
+func ForwardSCIONAddr(proxy *httputil.ReverseProxy) {
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Header.Del(ForwardedForHeader)
+		if addr, ok := RemoteSCIONAddr(r); ok {
+			r.Header.Set(ForwardedForHeader, addr.String())
+		}
+	}
+}
+
+// TrustForwardedSCIONAddr returns a handler that takes the SCION address of
+// the client from the ForwardedForHeader, so that it is returned by
+// RemoteSCIONAddr, before calling h.
+// Only use this for a backend that can exclusively be reached through a
+// reverse proxy set up with ForwardSCIONAddr; otherwise, clients can claim
+// arbitrary addresses.
+func TrustForwardedSCIONAddr(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Get(ForwardedForHeader); v != "" {
+			if addr, err := appnet.ParseUDPAddr(v); err == nil {
+				r = r.WithContext(context.WithValue(r.Context(), remoteAddrKey{}, addr))
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
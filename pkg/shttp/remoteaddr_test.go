@@ -0,0 +1,100 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+const clientAddr = "1-ff00:0:112,[fd00:f00d:cafe::7f00:a]:31000"
+
+// iaHandler responds with the IA of the client, or "none".
+var iaHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if addr, ok := RemoteSCIONAddr(r); ok {
+		_, _ = w.Write([]byte(addr.IA.String()))
+	} else {
+		_, _ = w.Write([]byte("none"))
+	}
+})
+
+func TestRemoteSCIONAddr(t *testing.T) {
+	expected, err := snet.ParseUDPAddr(clientAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual *snet.UDPAddr
+	var remoteAddr string
+	handler := withRemoteSCIONAddr(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actual, _ = RemoteSCIONAddr(r)
+		remoteAddr = r.RemoteAddr
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = clientAddr
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if actual == nil || !actual.IA.Equal(expected.IA) || !actual.Host.IP.Equal(expected.Host.IP) {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+	if remoteAddr != expected.String() {
+		t.Errorf("expected RemoteAddr %s, got %s", expected, remoteAddr)
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	if _, ok := RemoteSCIONAddr(r); ok {
+		t.Errorf("expected no SCION address for request over TCP")
+	}
+}
+
+func TestRemoteSCIONAddrProxy(t *testing.T) {
+	backend := httptest.NewServer(TrustForwardedSCIONAddr(iaHandler))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(backendURL)
+	ForwardSCIONAddr(proxy)
+	handler := withRemoteSCIONAddr(proxy)
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		expected   string
+	}{
+		{"scion", clientAddr, "", "1-ff00:0:112"},
+		{"scion, spoofed", clientAddr, "1-ff00:0:110,[127.0.0.1]:1", "1-ff00:0:112"},
+		{"tcp", "192.0.2.1:1234", "", "none"},
+		{"tcp, spoofed", "192.0.2.1:1234", "1-ff00:0:110,[127.0.0.1]:1", "none"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = c.remoteAddr
+		if c.header != "" {
+			r.Header.Set(ForwardedForHeader, c.header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if actual := w.Body.String(); actual != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.name, c.expected, actual)
+		}
+	}
+}
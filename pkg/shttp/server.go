@@ -15,18 +15,42 @@
 package shttp
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
+	"io"
 	"net"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/lucas-clemente/quic-go/http3"
 	"github.com/netsec-ethz/scion-apps/pkg/appnet"
 	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
 )
 
+// shutdownLinger is the time that Shutdown waits after all requests have
+// completed before closing the server, so that the data of the last responses
+// can still be delivered (or retransmitted) before the QUIC sessions are
+// closed.
+const shutdownLinger = 500 * time.Millisecond
+
 // Server wraps a http3.Server making it work with SCION
+//
+// The size of the request headers is limited by MaxHeaderBytes of the
+// embedded http.Server, as in net/http.
 type Server struct {
 	*http3.Server
+	// MaxBodyBytes is the maximum size of request bodies, see
+	// MaxBytesHandler. Zero means no limit.
+	MaxBodyBytes int64
+
+	wrapHandler sync.Once
+
+	mutex        sync.Mutex
+	shuttingDown bool
+	active       int           // number of requests whose stream is not yet closed
+	drained      chan struct{} // closed when active drops to 0 during shutdown
 }
 
 // ListenAndServe listens for HTTPS connections on the SCION address addr and calls Serve
@@ -86,6 +110,18 @@ func (srv *Server) Serve(conn net.PacketConn) error {
 	if len(srv.TLSConfig.Certificates) == 0 {
 		srv.TLSConfig.Certificates = appquic.GetDummyTLSCerts()
 	}
+	// Wrap the handler only once, Serve may be called for multiple connections
+	srv.wrapHandler.Do(func() {
+		handler := srv.Handler
+		if handler == nil {
+			handler = http.DefaultServeMux
+		}
+		handler = withRemoteSCIONAddr(handler)
+		if srv.MaxBodyBytes > 0 {
+			handler = MaxBytesHandler(handler, srv.MaxBodyBytes)
+		}
+		srv.Handler = srv.trackRequests(handler)
+	})
 
 	return srv.Server.Serve(conn)
 }
@@ -95,3 +131,199 @@ func (srv *Server) Serve(conn net.PacketConn) error {
 func (srv *Server) Close() error {
 	return srv.Server.Close()
 }
+
+// Shutdown gracefully shuts down the server, analogous to
+// http.Server.Shutdown: new requests are refused with 503 Service Unavailable,
+// while the requests in flight are allowed to complete. Once all requests
+// have completed or the context expires, the server is closed, aborting any
+// remaining requests.
+// Returns the context's error if it expired before all requests completed,
+// otherwise the error returned by Close.
+//
+// As closing the QUIC listener also closes the QUIC sessions accepted on it,
+// new connections can only be refused once all requests have completed.
+// A request has completed once the handler has returned and the response
+// stream has been closed. As the data of a closed stream may still be in
+// flight, the server is closed only shutdownLinger later.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	srv.mutex.Lock()
+	srv.shuttingDown = true
+	if srv.drained == nil {
+		srv.drained = make(chan struct{})
+		if srv.active == 0 {
+			close(srv.drained)
+		}
+	}
+	drained := srv.drained
+	srv.mutex.Unlock()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		_ = srv.Close()
+		return ctx.Err()
+	}
+	linger := time.NewTimer(shutdownLinger)
+	defer linger.Stop()
+	select {
+	case <-linger.C:
+		return srv.Close()
+	case <-ctx.Done():
+		_ = srv.Close()
+		return ctx.Err()
+	}
+}
+
+// CloseGracefully shuts down the server gracefully, waiting at most timeout
+// for the requests in flight to complete; see Shutdown.
+// This replaces http3.Server.CloseGracefully, which is not implemented.
+func (srv *Server) CloseGracefully(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// trackRequests returns a handler that keeps track of the requests in flight
+// for Shutdown, and refuses new requests once Shutdown has been called.
+func (srv *Server) trackRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.mutex.Lock()
+		if srv.shuttingDown {
+			srv.mutex.Unlock()
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		srv.active++
+		srv.mutex.Unlock()
+
+		// The request is done once the stream is closed, which http3 does only
+		// after the handler has returned. The stream's context, i.e. the
+		// request's context, is cancelled when the stream is closed.
+		defer func() {
+			go func() {
+				<-r.Context().Done()
+				srv.requestDone()
+			}()
+		}()
+		h.ServeHTTP(w, r)
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	})
+}
+
+func (srv *Server) requestDone() {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.active--
+	if srv.active == 0 && srv.drained != nil {
+		close(srv.drained)
+	}
+}
+
+// ErrBodyTooLarge is returned when reading a request body beyond the limit set
+// by MaxBytesHandler.
+var ErrBodyTooLarge = errors.New("shttp: request body too large")
+
+// MaxBytesHandler returns a handler that limits the size of request bodies to
+// n bytes before calling h.
+// Requests announcing a larger Content-Length are rejected with
+// 413 Request Entity Too Large, without calling h. For bodies of unknown
+// length, reading beyond the limit returns ErrBodyTooLarge to h and, unless h
+// has already written the response header, responds with 413; anything h
+// writes afterwards is discarded. The body is never buffered.
+func MaxBytesHandler(h http.Handler, n int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > n {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if r.Body == nil || r.Body == http.NoBody {
+			h.ServeHTTP(w, r)
+			return
+		}
+		mw := &maxBytesWriter{ResponseWriter: w}
+		r.Body = &maxBytesBody{body: r.Body, remaining: n, w: mw}
+		h.ServeHTTP(mw, r)
+	})
+}
+
+// maxBytesWriter is the http.ResponseWriter passed to the handler wrapped by
+// MaxBytesHandler. It keeps track of whether the header has been written, and
+// discards the response of the handler once the request has been rejected.
+type maxBytesWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	rejected    bool
+}
+
+func (w *maxBytesWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *maxBytesWriter) Write(b []byte) (int, error) {
+	if w.rejected {
+		return len(b), nil
+	}
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *maxBytesWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && !w.rejected {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// reject responds with 413 Request Entity Too Large, if the header has not
+// been written yet.
+func (w *maxBytesWriter) reject() {
+	if w.wroteHeader {
+		return
+	}
+	http.Error(w.ResponseWriter, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+	w.wroteHeader = true
+	w.rejected = true
+}
+
+// maxBytesBody is a request body limited to a number of bytes, like
+// http.MaxBytesReader.
+type maxBytesBody struct {
+	body      io.ReadCloser
+	remaining int64
+	w         *maxBytesWriter
+	err       error
+}
+
+func (b *maxBytesBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// read one byte more than allowed, to detect bodies exceeding the limit
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.body.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		b.err = err
+		return n, err
+	}
+	n = int(b.remaining)
+	b.remaining = 0
+	b.err = ErrBodyTooLarge
+	b.w.reject()
+	return n, b.err
+}
+
+func (b *maxBytesBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,340 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/http3"
+)
+
+func TestMaxBytesHandler(t *testing.T) {
+	const limit = 10
+	var invoked bool
+	handler := MaxBytesHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		invoked = true
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		fmt.Fprintf(w, "%d", len(body))
+	}), limit)
+
+	cases := []struct {
+		name           string
+		body           string
+		contentLength  int64
+		expectedStatus int
+		expectInvoked  bool
+	}{
+		{"within limit", "0123456789", 10, http.StatusOK, true},
+		{"content length over limit", "0123456789x", 11, http.StatusRequestEntityTooLarge, false},
+		{"unknown length over limit", "0123456789x", -1, http.StatusRequestEntityTooLarge, true},
+		{"unknown length within limit", "0123", -1, http.StatusOK, true},
+		{"unknown length at limit", "0123456789", -1, http.StatusOK, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			invoked = false
+			r := httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+			r.ContentLength = c.contentLength
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != c.expectedStatus {
+				t.Errorf("expected status %d, got %d", c.expectedStatus, w.Code)
+			}
+			if invoked != c.expectInvoked {
+				t.Errorf("expected handler invoked: %v, got %v", c.expectInvoked, invoked)
+			}
+		})
+	}
+}
+
+// TestMaxBytesHandlerUnknownLength checks that a body without Content-Length
+// exceeding the limit is rejected with 413, even if the handler does not
+// report the error itself, and that the limit does not affect responses
+// written before the body is read.
+func TestMaxBytesHandlerUnknownLength(t *testing.T) {
+	const limit = 10
+	var readErr error
+	handler := MaxBytesHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = ioutil.ReadAll(r.Body)
+		if readErr != nil {
+			http.Error(w, readErr.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}), limit)
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("x", 100)))
+	r.ContentLength = -1
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if !errors.Is(readErr, ErrBodyTooLarge) {
+		t.Errorf("expected ErrBodyTooLarge, got %v", readErr)
+	}
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), readErr.Error()) {
+		t.Errorf("expected response of the handler to be discarded, got %q", w.Body.String())
+	}
+
+	// header already written by the handler
+	handler = MaxBytesHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, readErr = ioutil.ReadAll(r.Body)
+	}), limit)
+	r = httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("x", 100)))
+	r.ContentLength = -1
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if !errors.Is(readErr, ErrBodyTooLarge) {
+		t.Errorf("expected ErrBodyTooLarge, got %v", readErr)
+	}
+	if w.Code != http.StatusAccepted {
+		t.Errorf("expected status 202, got %d", w.Code)
+	}
+}
+
+// TestServerMaxBodyBytes checks that the limit is enforced by the server. As
+// there is no SCION network in unit tests, the HTTP/3 server is run on a plain
+// UDP socket instead.
+func TestServerMaxBodyBytes(t *testing.T) {
+	invoked := make(chan int, 10)
+	server := &Server{
+		Server: &http3.Server{
+			Server: &http.Server{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					body, _ := ioutil.ReadAll(r.Body)
+					invoked <- len(body)
+				}),
+			},
+		},
+		MaxBodyBytes: 1000,
+	}
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = server.Serve(udpConn)
+	}()
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &http3.RoundTripper{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	post := func(size int) int {
+		resp, err := client.Post(fmt.Sprintf("https://%s/", udpConn.LocalAddr()),
+			"application/octet-stream", bytes.NewReader(make([]byte, size)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := post(100000); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %d for oversized body, got %d", http.StatusRequestEntityTooLarge, status)
+	}
+	if status := post(1000); status != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, status)
+	}
+	if n := <-invoked; n != 1000 {
+		t.Errorf("expected handler to be invoked only for request within limit, got body of %d bytes", n)
+	}
+}
+
+// TestServerShutdown checks that Shutdown lets a request in flight complete,
+// while new requests are refused. As in TestServerMaxBodyBytes, the server is
+// run on a plain UDP socket.
+func TestServerShutdown(t *testing.T) {
+	started := make(chan struct{})
+	server := &Server{
+		Server: &http3.Server{
+			Server: &http.Server{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path == "/slow" {
+						close(started)
+						time.Sleep(500 * time.Millisecond)
+					}
+					fmt.Fprint(w, "done")
+				}),
+			},
+		},
+	}
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = server.Serve(udpConn)
+	}()
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &http3.RoundTripper{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	url := fmt.Sprintf("https://%s", udpConn.LocalAddr())
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	slow := make(chan result, 1)
+	go func() {
+		resp, err := client.Get(url + "/slow")
+		if err != nil {
+			slow <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		slow <- result{resp.StatusCode, string(body), err}
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		shutdown <- server.Shutdown(ctx)
+	}()
+	// wait for Shutdown to take effect
+	for {
+		server.mutex.Lock()
+		shuttingDown := server.shuttingDown
+		server.mutex.Unlock()
+		if shuttingDown {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	resp, err := client.Get(url + "/new")
+	if err != nil {
+		t.Errorf("expected new request to be refused with status %d, got error: %v",
+			http.StatusServiceUnavailable, err)
+	} else {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("expected new request to be refused, got status %d", resp.StatusCode)
+		}
+	}
+
+	r := <-slow
+	if r.err != nil || r.status != http.StatusOK || r.body != "done" {
+		t.Errorf("expected request in flight to complete, got %d %q, err: %v", r.status, r.body, r.err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown returned error: %v", err)
+	}
+}
+
+func TestServerShutdownDeadline(t *testing.T) {
+	server := &Server{Server: &http3.Server{Server: &http.Server{}}}
+	handler := server.trackRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+	}))
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	for {
+		server.mutex.Lock()
+		active := server.active
+		server.mutex.Unlock()
+		if active > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+// TestServerServeTwice checks that serving on multiple connections does not
+// wrap the handler multiple times.
+func TestServerServeTwice(t *testing.T) {
+	inFlight := make(chan struct{})
+	release := make(chan struct{})
+	server := &Server{
+		Server: &http3.Server{
+			Server: &http.Server{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					close(inFlight)
+					<-release
+				}),
+			},
+		},
+		MaxBodyBytes: 1000,
+	}
+	var conns []net.PacketConn
+	for i := 0; i < 2; i++ {
+		udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, udpConn)
+		go func() {
+			_ = server.Serve(udpConn)
+		}()
+	}
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &http3.RoundTripper{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	done := make(chan error, 1)
+	go func() {
+		resp, err := client.Get(fmt.Sprintf("https://%s/", conns[1].LocalAddr()))
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	<-inFlight
+	server.mutex.Lock()
+	active := server.active
+	server.mutex.Unlock()
+	close(release)
+	if active != 1 {
+		t.Errorf("expected 1 active request, got %d", active)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
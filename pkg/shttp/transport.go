@@ -19,15 +19,21 @@ package shttp
 
 import (
 	"crypto/tls"
+	"errors"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
+	"sync"
+	"time"
 
+	log "github.com/inconshreveable/log15"
 	"github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/http3"
 	"github.com/netsec-ethz/scion-apps/pkg/appnet"
 	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
+	"github.com/scionproto/scion/go/lib/snet"
 )
 
 // RoundTripper extends the http.RoundTripper interface with a Close
@@ -36,28 +42,49 @@ type RoundTripper interface {
 	io.Closer
 }
 
-// NewRoundTripper creates a new RoundTripper that can be used as the Transport
+// failedPathTimeout is the time for which a path that failed is avoided
+// when dialing a new connection to the same host.
+const failedPathTimeout = 5 * time.Minute
+
+// NewRoundTripper creates a new Transport that can be used as the Transport
 // of an http.Client.
-func NewRoundTripper(tlsClientCfg *tls.Config, quicCfg *quic.Config) RoundTripper {
-	return &roundTripper{
-		&http3.RoundTripper{
-			Dial:            dial,
-			QuicConfig:      quicCfg,
-			TLSClientConfig: tlsClientCfg,
+func NewRoundTripper(tlsClientCfg *tls.Config, quicCfg *quic.Config) *Transport {
+	return &Transport{
+		hosts: make(map[string]*hostRoundTripper),
+		newHostRoundTripper: func(h *hostRoundTripper) RoundTripper {
+			return &http3.RoundTripper{
+				Dial:            h.dial,
+				QuicConfig:      quicCfg,
+				TLSClientConfig: tlsClientCfg,
+			}
 		},
 	}
 }
 
-var _ RoundTripper = (*roundTripper)(nil)
+var _ RoundTripper = (*Transport)(nil)
+
+// Transport implements the RoundTripper interface. It wraps a
+// http3.RoundTripper per host, making it compatible with SCION
+type Transport struct {
+	// MaxPathRetries is the number of times a request that failed due to a
+	// path failure (e.g. an SCMP error or a timeout) is retried. For each
+	// retry, a new connection is established over a path that has not failed
+	// recently, if there is any.
+	// Only requests with idempotent methods (GET, HEAD, OPTIONS, TRACE) are
+	// retried, and only if the request body can be rewound
+	// (Request.GetBody). Zero disables retries and the avoidance of failed
+	// paths.
+	MaxPathRetries int
 
-// roundTripper implements the RoundTripper interface. It wraps a
-// http3.RoundTripper, making it compatible with SCION
-type roundTripper struct {
-	rt *http3.RoundTripper
+	mutex sync.Mutex
+	hosts map[string]*hostRoundTripper
+	// newHostRoundTripper creates the RoundTripper for a host, which dials
+	// the connection using h.dial.
+	newHostRoundTripper func(h *hostRoundTripper) RoundTripper
 }
 
 // RoundTrip does a single round trip; retrieving a response for a given request
-func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 
 	// If req.URL.Host is a SCION address, we need to mangle it so it passes through
 	// http3 without tripping up.
@@ -68,22 +95,162 @@ func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	*cpy.URL = *req.URL
 	cpy.URL.Host = appnet.MangleSCIONAddr(req.URL.Host)
 
-	return t.rt.RoundTrip(&cpy)
+	maxRetries := 0
+	if canRetry(req) {
+		maxRetries = t.MaxPathRetries
+	}
+	for retry := 0; ; retry++ {
+		h := t.host(cpy.URL.Host)
+		resp, err := h.RoundTrip(&cpy)
+		if err == nil || !isPathError(err) || t.MaxPathRetries == 0 {
+			return resp, err
+		}
+		// Reconnect for the next request, avoiding the failed path
+		t.resetHost(cpy.URL.Host, h)
+		if retry >= maxRetries {
+			return resp, err
+		}
+		log.Debug("shttp: path failure, retrying", "host", req.URL.Host, "err", err)
+		if cpy.Body != nil && cpy.Body != http.NoBody {
+			cpy.Body, err = cpy.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
 }
 
 // Close closes the QUIC connections that this RoundTripper has used
-func (t *roundTripper) Close() (err error) {
+func (t *Transport) Close() (err error) {
 
-	if t.rt != nil {
-		err = t.rt.Close()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for host, h := range t.hosts {
+		if cerr := h.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(t.hosts, host)
 	}
-
 	return err
 }
 
-// dial is the Dial function used in RoundTripper
-func dial(network, address string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlySession, error) {
-	return appquic.DialEarly(appnet.UnmangleSCIONAddr(address), tlsCfg, cfg)
+// host returns the RoundTripper for the host, creating it if necessary.
+func (t *Transport) host(host string) *hostRoundTripper {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	h, ok := t.hosts[host]
+	if !ok {
+		h = t.createHost(make(map[snet.PathFingerprint]time.Time))
+		t.hosts[host] = h
+	}
+	return h
+}
+
+// resetHost replaces the RoundTripper h for the host after a path failure.
+// The path used by h is excluded when dialing the new connection.
+func (t *Transport) resetHost(host string, h *hostRoundTripper) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.hosts[host] != h {
+		return // already reset by a concurrent request
+	}
+	failedPaths := h.failed()
+	_ = h.Close()
+	t.hosts[host] = t.createHost(failedPaths)
+}
+
+func (t *Transport) createHost(failedPaths map[snet.PathFingerprint]time.Time) *hostRoundTripper {
+	h := &hostRoundTripper{failedPaths: failedPaths}
+	h.RoundTripper = t.newHostRoundTripper(h)
+	return h
+}
+
+// hostRoundTripper is the RoundTripper for the connection to a single host.
+// It keeps track of the path used for the connection and of the paths that
+// have failed before, with the time of the failure.
+type hostRoundTripper struct {
+	RoundTripper
+
+	mutex       sync.Mutex
+	path        snet.PathFingerprint
+	failedPaths map[snet.PathFingerprint]time.Time
+}
+
+// dial is the Dial function used in the http3.RoundTripper for a host.
+func (h *hostRoundTripper) dial(network, address string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlySession, error) {
+	remote := appnet.UnmangleSCIONAddr(address)
+	raddr, err := appnet.ResolveUDPAddr(remote)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := appnet.QueryPaths(raddr.IA)
+	if err != nil {
+		return nil, err
+	}
+	if path := h.choosePath(paths); path != nil {
+		appnet.SetPath(raddr, path)
+	}
+	return appquic.DialAddrEarly(raddr, remote, tlsCfg, cfg)
+}
+
+// choosePath returns the first of the paths that has not failed within
+// failedPathTimeout, or the first path if all have failed. Returns nil if paths
+// is empty.
+func (h *hostRoundTripper) choosePath(paths []snet.Path) snet.Path {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(paths) == 0 {
+		h.path = ""
+		return nil
+	}
+	chosen := paths[0]
+	now := time.Now()
+	for _, p := range paths {
+		if failed, ok := h.failedPaths[snet.Fingerprint(p)]; !ok || now.Sub(failed) >= failedPathTimeout {
+			chosen = p
+			break
+		}
+	}
+	h.path = snet.Fingerprint(chosen)
+	return chosen
+}
+
+// failed returns the paths that have failed within failedPathTimeout,
+// including the current path.
+func (h *hostRoundTripper) failed() map[snet.PathFingerprint]time.Time {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	now := time.Now()
+	for fp, failed := range h.failedPaths {
+		if now.Sub(failed) >= failedPathTimeout {
+			delete(h.failedPaths, fp)
+		}
+	}
+	if h.path != "" {
+		h.failedPaths[h.path] = now
+	}
+	return h.failedPaths
+}
+
+// canRetry returns whether the request can safely be sent again.
+func canRetry(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// isPathError returns whether the error indicates that the path to the server
+// failed, i.e. an SCMP error was received or the connection timed out.
+func isPathError(err error) bool {
+	var opErr *snet.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
 
 var scionAddrURLRegexp = regexp.MustCompile(
@@ -15,19 +15,26 @@
 package shttp
 
 import (
+	"bytes"
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/http3"
 	"github.com/netsec-ethz/scion-apps/pkg/appnet"
 	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/common"
 	"github.com/scionproto/scion/go/lib/snet"
+	snetpath "github.com/scionproto/scion/go/lib/snet/path"
 )
 
 func TestMangleSCIONAddrURL(t *testing.T) {
@@ -42,6 +49,7 @@ func TestMangleSCIONAddrURL(t *testing.T) {
 		{"1-ff00:0:110,::1", "[1-ff00:0:110,::1]"},
 		{"1-ff00:0:110,[::1]", "[1-ff00:0:110,::1]"},
 		{"1-ff00:0:110,[::1]:80", "[1-ff00:0:110,::1]:80"},
+		{"1-ff00:0:110,[fe80::1%eth0]:80", "[1-ff00:0:110,fe80::1%25eth0]:80"},
 	}
 
 	urlPatterns := hostURLPatterns()
@@ -126,7 +134,9 @@ func TestRoundTripper(t *testing.T) {
 	}
 
 	rt := NewRoundTripper(nil, nil)
-	rt.(*roundTripper).rt.Dial = testDial
+	rt.newHostRoundTripper = func(h *hostRoundTripper) RoundTripper {
+		return &http3.RoundTripper{Dial: testDial}
+	}
 	c := &http.Client{Transport: rt}
 
 	for _, tc := range testCases {
@@ -157,3 +167,186 @@ func hostURLPatterns() []string {
 		"https://user@%s/hello?boo=bla",
 	}
 }
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// fakeHostRoundTripper simulates the connection to a host over the path
+// chosen by the hostRoundTripper. Requests fail with a timeout if the path is
+// in failing.
+type fakeHostRoundTripper struct {
+	h       *hostRoundTripper
+	paths   []snet.Path
+	failing map[snet.PathFingerprint]bool
+	log     *[]string
+}
+
+func (f *fakeHostRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := f.h.choosePath(f.paths)
+	fp := snet.Fingerprint(path)
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		*f.log = append(*f.log, fmt.Sprintf("%s %s", fp, body))
+	} else {
+		*f.log = append(*f.log, fp.String())
+	}
+	if f.failing[fp] {
+		return nil, timeoutError{}
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func (f *fakeHostRoundTripper) Close() error {
+	return nil
+}
+
+func newFakeRoundTripper(maxPathRetries int, failing ...int) (*Transport, []snet.PathFingerprint, *[]string) {
+	var paths []snet.Path
+	var fps []snet.PathFingerprint
+	for i := 1; i <= 3; i++ {
+		p := snetpath.Path{Meta: snet.PathMetadata{
+			Interfaces: []snet.PathInterface{{ID: common.IFIDType(i)}, {ID: common.IFIDType(10 + i)}},
+		}}
+		paths = append(paths, p)
+		fps = append(fps, snet.Fingerprint(p))
+	}
+	failingSet := make(map[snet.PathFingerprint]bool)
+	for _, i := range failing {
+		failingSet[fps[i]] = true
+	}
+	log := &[]string{}
+	rt := NewRoundTripper(nil, nil)
+	rt.MaxPathRetries = maxPathRetries
+	rt.newHostRoundTripper = func(h *hostRoundTripper) RoundTripper {
+		return &fakeHostRoundTripper{h: h, paths: paths, failing: failingSet, log: log}
+	}
+	return rt, fps, log
+}
+
+func TestRoundTripperPathRetry(t *testing.T) {
+	rt, fps, log := newFakeRoundTripper(2, 0)
+	c := &http.Client{Transport: rt}
+
+	resp, err := c.Get("https://host/")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp.Body.Close()
+	expected := []string{fps[0].String(), fps[1].String()}
+	if !reflect.DeepEqual(*log, expected) {
+		t.Fatalf("expected attempts over paths %v, got %v", expected, *log)
+	}
+
+	// the connection over the second path is kept for subsequent requests
+	resp, err = c.Get("https://host/")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp.Body.Close()
+	if (*log)[2] != fps[1].String() {
+		t.Fatalf("expected request over path %s, got %s", fps[1], (*log)[2])
+	}
+}
+
+func TestRoundTripperPathRetryExhausted(t *testing.T) {
+	rt, fps, log := newFakeRoundTripper(1, 0, 1, 2)
+	c := &http.Client{Transport: rt}
+
+	_, err := c.Get("https://host/")
+	if !errors.As(err, &timeoutError{}) {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	expected := []string{fps[0].String(), fps[1].String()}
+	if !reflect.DeepEqual(*log, expected) {
+		t.Fatalf("expected attempts over paths %v, got %v", expected, *log)
+	}
+}
+
+func TestRoundTripperPathRetryBody(t *testing.T) {
+	rt, fps, log := newFakeRoundTripper(2, 0)
+	c := &http.Client{Transport: rt}
+
+	// POST is not retried
+	body := ioutil.NopCloser(strings.NewReader("unrewindable"))
+	_, err := c.Post("https://host/", "text/plain", body)
+	if !errors.As(err, &timeoutError{}) {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	expected := []string{fps[0].String() + " unrewindable"}
+	if !reflect.DeepEqual(*log, expected) {
+		t.Fatalf("expected attempts %v, got %v", expected, *log)
+	}
+
+	// the failed path is avoided for the next request; fail the second path
+	// too and check that a GET with a rewindable body is retried
+	rt.hosts["host"].RoundTripper.(*fakeHostRoundTripper).failing[fps[1]] = true
+	*log = nil
+	req, err := http.NewRequest(http.MethodGet, "https://host/", bytes.NewReader([]byte("rewindable")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp.Body.Close()
+	expected = []string{fps[1].String() + " rewindable", fps[2].String() + " rewindable"}
+	if !reflect.DeepEqual(*log, expected) {
+		t.Fatalf("expected attempts %v, got %v", expected, *log)
+	}
+}
+
+func TestRoundTripperNoPathRetry(t *testing.T) {
+	rt, fps, log := newFakeRoundTripper(0, 0)
+	c := &http.Client{Transport: rt}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get("https://host/"); !errors.As(err, &timeoutError{}) {
+			t.Fatalf("expected timeout error, got %v", err)
+		}
+	}
+	// without retries, the connection is not reset and no path is avoided
+	expected := []string{fps[0].String(), fps[0].String()}
+	if !reflect.DeepEqual(*log, expected) {
+		t.Fatalf("expected attempts over paths %v, got %v", expected, *log)
+	}
+	if len(rt.hosts["host"].failedPaths) != 0 {
+		t.Fatalf("expected no failed paths, got %v", rt.hosts["host"].failedPaths)
+	}
+}
+
+func TestRoundTripperFailedPathTimeout(t *testing.T) {
+	rt, fps, _ := newFakeRoundTripper(1)
+	c := &http.Client{Transport: rt}
+
+	resp, err := c.Get("https://host/")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp.Body.Close()
+	h := rt.hosts["host"]
+	h.failedPaths[fps[0]] = time.Now().Add(-failedPathTimeout)
+	h.failedPaths[fps[1]] = time.Now()
+
+	// the first path failed long ago and is used again, the recent failure of
+	// the second path is kept
+	if p := h.choosePath(h.RoundTripper.(*fakeHostRoundTripper).paths); snet.Fingerprint(p) != fps[0] {
+		t.Fatalf("expected path %s, got %s", fps[0], snet.Fingerprint(p))
+	}
+	failed := h.failed()
+	if _, ok := failed[fps[0]]; !ok || len(failed) != 2 {
+		t.Fatalf("expected current and recently failed path, got %v", failed)
+	}
+	h.failedPaths[fps[0]] = time.Now().Add(-failedPathTimeout)
+	h.path = fps[2]
+	failed = h.failed()
+	if _, ok := failed[fps[0]]; ok || len(failed) != 2 {
+		t.Fatalf("expected expired failure to be removed, got %v", failed)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sensorauth authenticates sensor readings with DRKey.
+//
+// The server MACs each reading with the Host2Host DRKey from the server to the
+// client. The server derives this key from the delegation secret (fast side),
+// while the client obtains it from its SCIOND (slow side). Both cache the keys
+// for the duration of their epoch.
+//
+// An authenticated message has the form
+//   timestamp (8 bytes, Unix seconds) | reading | MAC (32 bytes)
+// where the MAC is the HMAC-SHA256 over the timestamp and the reading, keyed
+// with the DRKey valid at the timestamp.
+package sensorauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/drkey"
+	"github.com/scionproto/scion/go/lib/drkey/protocol"
+	"github.com/scionproto/scion/go/lib/sciond"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+const (
+	// Protocol is the DRKey protocol identifier used for the keys.
+	Protocol = "piskes"
+	// MACSize is the size of the MAC appended to the reading.
+	MACSize = sha256.Size
+
+	timestampSize = 8
+	// maxClockSkew is the maximum difference between the timestamp of a
+	// message and the local time accepted by the client.
+	maxClockSkew = time.Minute
+	keyTimeout   = 10 * time.Second
+)
+
+// ErrInvalidMAC is returned by Client.Open if the MAC does not match.
+var ErrInvalidMAC = errors.New("invalid MAC")
+
+// KeyGetter obtains DRKeys from SCIOND; implemented by sciond.Connector.
+type KeyGetter interface {
+	DRKeyGetLvl2Key(ctx context.Context, meta drkey.Lvl2Meta, valTime time.Time) (drkey.Lvl2Key, error)
+}
+
+// DefaultSciondAddress returns the address of SCIOND, as configured by the
+// environment variable SCION_DAEMON_ADDRESS, as in appnet.
+func DefaultSciondAddress() string {
+	if address, ok := os.LookupEnv("SCION_DAEMON_ADDRESS"); ok {
+		return address
+	}
+	return sciond.DefaultAPIAddress
+}
+
+// Server computes the MACs for the readings sent by the sensor server.
+type Server struct {
+	sciond KeyGetter
+	local  *snet.UDPAddr
+
+	mutex   sync.Mutex
+	secrets map[addr.IA]drkey.DelegationSecret // by client IA
+}
+
+// NewServer returns a Server for the local address local, obtaining the
+// delegation secrets from sciond.
+func NewServer(sciond KeyGetter, local *snet.UDPAddr) *Server {
+	return &Server{
+		sciond:  sciond,
+		local:   local,
+		secrets: make(map[addr.IA]drkey.DelegationSecret),
+	}
+}
+
+// Seal returns the authenticated message for the reading sent to client at
+// time now.
+func (s *Server) Seal(client *snet.UDPAddr, reading []byte, now time.Time) ([]byte, error) {
+	ds, err := s.delegationSecret(client.IA, now)
+	if err != nil {
+		return nil, err
+	}
+	meta := keyMeta(s.local, client)
+	meta.Epoch = ds.Epoch
+	piskes := protocol.KnownDerivations[Protocol].(protocol.DelegatedDerivation)
+	key, err := piskes.DeriveLvl2FromDS(meta, ds)
+	if err != nil {
+		return nil, err
+	}
+	msg := make([]byte, timestampSize, timestampSize+len(reading)+MACSize)
+	binary.BigEndian.PutUint64(msg, uint64(now.Unix()))
+	msg = append(msg, reading...)
+	return append(msg, computeMAC(key.Key, msg)...), nil
+}
+
+// delegationSecret returns the delegation secret for the client IA, valid at
+// time t, from the cache if possible.
+func (s *Server) delegationSecret(clientIA addr.IA, t time.Time) (drkey.DelegationSecret, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if ds, ok := s.secrets[clientIA]; ok && ds.Epoch.Contains(t) {
+		return ds, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keyTimeout)
+	defer cancel()
+	dsMeta := drkey.Lvl2Meta{
+		KeyType:  drkey.AS2AS,
+		Protocol: Protocol,
+		SrcIA:    s.local.IA,
+		DstIA:    clientIA,
+	}
+	key, err := s.sciond.DRKeyGetLvl2Key(ctx, dsMeta, t)
+	if err != nil {
+		return drkey.DelegationSecret{}, fmt.Errorf("obtaining delegation secret: %w", err)
+	}
+	ds := drkey.DelegationSecret{
+		Protocol: key.Protocol,
+		Epoch:    key.Epoch,
+		SrcIA:    key.SrcIA,
+		DstIA:    key.DstIA,
+		Key:      key.Key,
+	}
+	s.secrets[clientIA] = ds
+	return ds, nil
+}
+
+// Client verifies the MACs of the readings received by the sensor fetcher.
+type Client struct {
+	sciond KeyGetter
+	local  *snet.UDPAddr
+
+	mutex sync.Mutex
+	keys  map[string]drkey.Lvl2Key // by server address
+}
+
+// NewClient returns a Client for the local address local, obtaining the keys
+// from sciond.
+func NewClient(sciond KeyGetter, local *snet.UDPAddr) *Client {
+	return &Client{
+		sciond: sciond,
+		local:  local,
+		keys:   make(map[string]drkey.Lvl2Key),
+	}
+}
+
+// Open verifies the authenticated message received from server at time now,
+// and returns the reading.
+func (c *Client) Open(server *snet.UDPAddr, msg []byte, now time.Time) ([]byte, error) {
+	if len(msg) < timestampSize+MACSize {
+		return nil, fmt.Errorf("message too short (%d bytes)", len(msg))
+	}
+	timestamp := time.Unix(int64(binary.BigEndian.Uint64(msg)), 0)
+	if d := now.Sub(timestamp); d > maxClockSkew || d < -maxClockSkew {
+		return nil, fmt.Errorf("timestamp %s out of range", timestamp)
+	}
+	key, err := c.key(server, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	data, mac := msg[:len(msg)-MACSize], msg[len(msg)-MACSize:]
+	if !hmac.Equal(mac, computeMAC(key.Key, data)) {
+		return nil, ErrInvalidMAC
+	}
+	return data[timestampSize:], nil
+}
+
+// key returns the key for the server, valid at time t, from the cache if
+// possible.
+func (c *Client) key(server *snet.UDPAddr, t time.Time) (drkey.Lvl2Key, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cacheKey := fmt.Sprintf("%s,%s", server.IA, server.Host.IP)
+	if key, ok := c.keys[cacheKey]; ok && key.Epoch.Contains(t) {
+		return key, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keyTimeout)
+	defer cancel()
+	key, err := c.sciond.DRKeyGetLvl2Key(ctx, keyMeta(server, c.local), t)
+	if err != nil {
+		return drkey.Lvl2Key{}, fmt.Errorf("obtaining DRKey: %w", err)
+	}
+	c.keys[cacheKey] = key
+	return key, nil
+}
+
+// keyMeta returns the metadata of the Host2Host key from server to client.
+func keyMeta(server, client *snet.UDPAddr) drkey.Lvl2Meta {
+	return drkey.Lvl2Meta{
+		KeyType:  drkey.Host2Host,
+		Protocol: Protocol,
+		SrcIA:    server.IA,
+		DstIA:    client.IA,
+		SrcHost:  addr.HostFromIP(server.Host.IP),
+		DstHost:  addr.HostFromIP(client.Host.IP),
+	}
+}
+
+func computeMAC(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
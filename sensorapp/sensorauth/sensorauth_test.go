@@ -0,0 +1,166 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sensorauth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/drkey"
+	"github.com/scionproto/scion/go/lib/drkey/protocol"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+// mockSciond hands out keys derived from a fixed delegation secret, valid for
+// one hour epochs. Host2Host keys are derived as by the slow side.
+type mockSciond struct {
+	calls int
+}
+
+func (m *mockSciond) DRKeyGetLvl2Key(ctx context.Context, meta drkey.Lvl2Meta,
+	valTime time.Time) (drkey.Lvl2Key, error) {
+
+	m.calls++
+	begin := valTime.Truncate(time.Hour)
+	meta.Epoch = drkey.NewEpoch(uint32(begin.Unix()), uint32(begin.Add(time.Hour).Unix()))
+	ds := drkey.DelegationSecret{
+		Protocol: meta.Protocol,
+		Epoch:    meta.Epoch,
+		SrcIA:    meta.SrcIA,
+		DstIA:    meta.DstIA,
+		Key:      drkey.DRKey(append([]byte("0123456789abcde"), byte(begin.Unix()))),
+	}
+	switch meta.KeyType {
+	case drkey.AS2AS:
+		return drkey.Lvl2Key{Lvl2Meta: meta, Key: ds.Key}, nil
+	case drkey.Host2Host:
+		piskes := protocol.KnownDerivations[meta.Protocol].(protocol.DelegatedDerivation)
+		return piskes.DeriveLvl2FromDS(meta, ds)
+	default:
+		return drkey.Lvl2Key{}, errors.New("unsupported key type")
+	}
+}
+
+func mustParse(address string) *snet.UDPAddr {
+	a, err := snet.ParseUDPAddr(address)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func TestSealOpen(t *testing.T) {
+	serverAddr := mustParse("1-ff00:0:111,127.0.0.1:40002")
+	clientAddr := mustParse("1-ff00:0:112,[fd00:f00d:cafe::7f00:a]:12345")
+	serverSciond, clientSciond := &mockSciond{}, &mockSciond{}
+	server := NewServer(serverSciond, serverAddr)
+	client := NewClient(clientSciond, clientAddr)
+
+	now := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+	reading := []byte("2021/07/01 12:00:00\nTemperature: 21.5\n")
+
+	t.Run("valid", func(t *testing.T) {
+		msg, err := server.Seal(clientAddr, reading, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opened, err := client.Open(serverAddr, msg, now.Add(time.Second))
+		if err != nil {
+			t.Fatalf("expected valid MAC to be accepted, got %v", err)
+		}
+		if !bytes.Equal(opened, reading) {
+			t.Errorf("expected reading %q, got %q", reading, opened)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		msg, err := server.Seal(clientAddr, reading, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, i := range []int{0, timestampSize + 5, len(msg) - 1} {
+			tampered := append([]byte(nil), msg...)
+			tampered[i] ^= 1
+			if _, err := client.Open(serverAddr, tampered, now); err == nil {
+				t.Errorf("expected message tampered at byte %d to be rejected", i)
+			}
+		}
+		if _, err := client.Open(serverAddr, msg[:10], now); err == nil {
+			t.Errorf("expected truncated message to be rejected")
+		}
+	})
+
+	t.Run("other client", func(t *testing.T) {
+		otherAddr := mustParse("1-ff00:0:112,[fd00:f00d:cafe::7f00:b]:12345")
+		msg, err := server.Seal(otherAddr, reading, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Open(serverAddr, msg, now); err != ErrInvalidMAC {
+			t.Errorf("expected message for other client to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		msg, err := server.Seal(clientAddr, reading, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Open(serverAddr, msg, now.Add(10*time.Minute)); err == nil {
+			t.Errorf("expected stale message to be rejected")
+		}
+	})
+}
+
+func TestKeyCache(t *testing.T) {
+	serverAddr := mustParse("1-ff00:0:111,127.0.0.1:40002")
+	clientAddr := mustParse("1-ff00:0:112,127.0.0.2:12345")
+	serverSciond, clientSciond := &mockSciond{}, &mockSciond{}
+	server := NewServer(serverSciond, serverAddr)
+	client := NewClient(clientSciond, clientAddr)
+
+	now := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		ts := now.Add(time.Duration(i) * time.Second)
+		msg, err := server.Seal(clientAddr, []byte("reading"), ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Open(serverAddr, msg, ts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if serverSciond.calls != 1 || clientSciond.calls != 1 {
+		t.Errorf("expected one key request per epoch, got %d (server), %d (client)",
+			serverSciond.calls, clientSciond.calls)
+	}
+
+	// next epoch
+	ts := now.Add(90 * time.Minute)
+	msg, err := server.Seal(clientAddr, []byte("reading"), ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Open(serverAddr, msg, ts); err != nil {
+		t.Fatal(err)
+	}
+	if serverSciond.calls != 2 || clientSciond.calls != 2 {
+		t.Errorf("expected new key request in next epoch, got %d (server), %d (client)",
+			serverSciond.calls, clientSciond.calls)
+	}
+}
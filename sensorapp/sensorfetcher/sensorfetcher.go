@@ -21,9 +21,14 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/snet"
 
 	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+	"github.com/netsec-ethz/scion-apps/sensorapp/sensorauth"
 )
 
 func check(e error) {
@@ -35,6 +40,8 @@ func check(e error) {
 func main() {
 
 	serverAddrStr := flag.String("s", "", "Server address (<ISD-AS,[IP]:port> or <hostname:port>)")
+	useDRKey := flag.Bool("drkey", false, "Verify readings authenticated with DRKey; the server must use -drkey, too")
+	sciondAddress := flag.String("sciond", sensorauth.DefaultSciondAddress(), "SCIOND address, for DRKey")
 	flag.Parse()
 
 	if len(*serverAddrStr) == 0 {
@@ -53,6 +60,16 @@ func main() {
 
 	n, err := conn.Read(receivePacketBuffer)
 	check(err)
+	reading := receivePacketBuffer[:n]
+
+	if *useDRKey {
+		local := &snet.UDPAddr{IA: appnet.DefNetwork().IA, Host: conn.LocalAddr().(*net.UDPAddr)}
+		auth := sensorauth.NewClient(appnet.NewReconnectingConnector(*sciondAddress), local)
+		reading, err = auth.Open(conn.RemoteAddr().(*snet.UDPAddr), reading, time.Now())
+		if err != nil {
+			log.Fatalf("Rejected reading: %s", err)
+		}
+	}
 
-	fmt.Print(string(receivePacketBuffer[:n]))
+	fmt.Print(string(reading))
 }
@@ -21,11 +21,16 @@ import (
 	"bufio"
 	"flag"
 	"log"
+	"net"
 	"os"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/snet"
 
 	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+	"github.com/netsec-ethz/scion-apps/sensorapp/sensorauth"
 )
 
 const (
@@ -76,11 +81,19 @@ func main() {
 
 	// Fetch arguments from command line
 	port := flag.Uint("p", 40002, "Server Port")
+	useDRKey := flag.Bool("drkey", false, "Authenticate readings with DRKey")
+	sciondAddress := flag.String("sciond", sensorauth.DefaultSciondAddress(), "SCIOND address, for DRKey")
 	flag.Parse()
 
 	conn, err := appnet.ListenPort(uint16(*port))
 	check(err)
 
+	var auth *sensorauth.Server
+	if *useDRKey {
+		local := &snet.UDPAddr{IA: appnet.DefNetwork().IA, Host: conn.LocalAddr().(*net.UDPAddr)}
+		auth = sensorauth.NewServer(appnet.NewReconnectingConnector(*sciondAddress), local)
+	}
+
 	receivePacketBuffer := make([]byte, 2500)
 	sendPacketBuffer := make([]byte, 2500)
 	for {
@@ -100,9 +113,18 @@ func main() {
 		}
 		sensorDataLock.Unlock()
 		sensorValues = timeStr + "\n" + sensorValues
-		copy(sendPacketBuffer, sensorValues)
+		n := copy(sendPacketBuffer, sensorValues)
+		response := sendPacketBuffer[:n]
+
+		if auth != nil {
+			response, err = auth.Seal(clientAddress.(*snet.UDPAddr), response, time.Now())
+			if err != nil {
+				log.Printf("Failed to authenticate reading for %s: %s", clientAddress, err)
+				continue
+			}
+		}
 
-		_, err = conn.WriteTo(sendPacketBuffer[:len(sensorValues)], clientAddress)
+		_, err = conn.WriteTo(response, clientAddress)
 		check(err)
 	}
 }
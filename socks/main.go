@@ -0,0 +1,74 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
+)
+
+func main() {
+	var bindAddress *net.TCPAddr
+	kingpin.Flag("bind", "Address to bind on").Default("localhost:1080").TCPVar(&bindAddress)
+	pathAlgo := kingpin.Flag("path-algo", "Path selection algorithm / metric").Default("").Enum("", "shortest", "mtu")
+	nextProto := kingpin.Flag("alpn", "Application protocol negotiated with the remote QUIC server. "+
+		"The default is compatible with scion-netcat").Default("netcat").String()
+	kingpin.Parse()
+
+	d := &appquic.StreamDialer{
+		PathAlgo: appnet.PathAlgoMetric(*pathAlgo),
+		TLSConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			NextProtos:         []string{*nextProto},
+		},
+	}
+	listener, err := net.ListenTCP("tcp", bindAddress)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Fatal(serve(listener, dialSCION(d)))
+}
+
+// dialSCION returns a dialFunc that resolves host and opens a QUIC stream to
+// host:port with d.
+// The host can be a SCION address (ISD-AS,[IP]) or a host name. An optional
+// pseudo-TLD ".scion" is removed from host names before resolving them.
+func dialSCION(d *appquic.StreamDialer) dialFunc {
+	return func(host string, port uint16) (net.Conn, error) {
+		address := fmt.Sprintf("%s:%d", strings.TrimSuffix(host, ".scion"), port)
+		raddr, err := appnet.ResolveUDPAddr(address)
+		if err != nil {
+			return nil, &socksError{repHostUnreachable, err}
+		}
+		conn, err := d.DialAddr(raddr, address)
+		if err != nil {
+			var pathErr *appquic.PathError
+			if errors.As(err, &pathErr) {
+				return nil, &socksError{repNetworkUnreachable, err}
+			}
+			return nil, &socksError{repConnectionRefused, err}
+		}
+		return conn, nil
+	}
+}
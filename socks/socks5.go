@@ -0,0 +1,197 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
+)
+
+// SOCKS protocol version 5, see RFC 1928
+const (
+	socksVersion = 0x05
+
+	authMethodNone         = 0x00
+	authMethodNoAcceptable = 0xff
+
+	cmdConnect = 0x01
+
+	atypIPv4   = 0x01
+	atypDomain = 0x03
+	atypIPv6   = 0x04
+)
+
+// Reply codes, as defined in RFC 1928, section 6
+const (
+	repSucceeded           = 0x00
+	repGeneralFailure      = 0x01
+	repNetworkUnreachable  = 0x03
+	repHostUnreachable     = 0x04
+	repConnectionRefused   = 0x05
+	repCommandNotSupported = 0x07
+	repAddressNotSupported = 0x08
+)
+
+// socksError is an error carrying the SOCKS reply code that is sent to the client.
+type socksError struct {
+	code byte
+	err  error
+}
+
+func (e *socksError) Error() string {
+	return fmt.Sprintf("socks reply %#02x: %s", e.code, e.err)
+}
+
+func (e *socksError) Unwrap() error {
+	return e.err
+}
+
+// replyCode returns the SOCKS reply code for err. Errors that do not carry a
+// specific reply code are reported as general failure.
+func replyCode(err error) byte {
+	var serr *socksError
+	if errors.As(err, &serr) {
+		return serr.code
+	}
+	return repGeneralFailure
+}
+
+// dialFunc opens a connection to host:port. Errors returned should be
+// socksErrors to report a specific reply code to the client.
+type dialFunc func(host string, port uint16) (net.Conn, error)
+
+// serve accepts SOCKS connections on listener and handles each in a separate
+// goroutine.
+func serve(listener net.Listener, dial dialFunc) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			_ = handleConn(conn, dial)
+		}()
+	}
+}
+
+// handleConn performs the SOCKS handshake on conn and, if successful, pipes
+// the data between conn and the connection opened with dial.
+// The connection is closed when handleConn returns.
+func handleConn(conn net.Conn, dial dialFunc) error {
+	defer conn.Close()
+
+	if err := negotiateAuth(conn); err != nil {
+		return err
+	}
+	host, port, err := readRequest(conn)
+	if err != nil {
+		_ = writeReply(conn, replyCode(err))
+		return err
+	}
+	remote, err := dial(host, port)
+	if err != nil {
+		_ = writeReply(conn, replyCode(err))
+		return err
+	}
+	defer remote.Close()
+	if err := writeReply(conn, repSucceeded); err != nil {
+		return err
+	}
+	appquic.Pipe(conn, remote)
+	return nil
+}
+
+// negotiateAuth reads the client's method selection message and selects the
+// "no authentication required" method. Other methods are not supported.
+func negotiateAuth(conn io.ReadWriter) error {
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return err
+	}
+	if hdr[0] != socksVersion {
+		return fmt.Errorf("unsupported SOCKS version %d", hdr[0])
+	}
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return err
+	}
+	for _, m := range methods {
+		if m == authMethodNone {
+			_, err := conn.Write([]byte{socksVersion, authMethodNone})
+			return err
+		}
+	}
+	_, _ = conn.Write([]byte{socksVersion, authMethodNoAcceptable})
+	return errors.New("no acceptable authentication method")
+}
+
+// readRequest reads a CONNECT request and returns the requested destination.
+// Only domain name destinations are supported, as plain IP addresses cannot
+// identify a SCION host.
+func readRequest(conn io.Reader) (string, uint16, error) {
+	hdr := make([]byte, 4)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return "", 0, err
+	}
+	if hdr[0] != socksVersion {
+		return "", 0, fmt.Errorf("unsupported SOCKS version %d", hdr[0])
+	}
+	var host string
+	switch hdr[3] {
+	case atypDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return "", 0, err
+		}
+		name := make([]byte, l[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", 0, err
+		}
+		host = string(name)
+	case atypIPv4, atypIPv6:
+		l := net.IPv4len
+		if hdr[3] == atypIPv6 {
+			l = net.IPv6len
+		}
+		ip := make([]byte, l)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", 0, err
+		}
+		return "", 0, &socksError{repAddressNotSupported,
+			fmt.Errorf("IP address %s is not a SCION address", net.IP(ip))}
+	default:
+		return "", 0, &socksError{repAddressNotSupported, fmt.Errorf("unknown address type %d", hdr[3])}
+	}
+	p := make([]byte, 2)
+	if _, err := io.ReadFull(conn, p); err != nil {
+		return "", 0, err
+	}
+	if hdr[1] != cmdConnect {
+		return "", 0, &socksError{repCommandNotSupported, fmt.Errorf("unsupported command %d", hdr[1])}
+	}
+	return host, binary.BigEndian.Uint16(p), nil
+}
+
+// writeReply writes a reply with the given code. The bound address is not
+// meaningful for SCION and is always reported as 0.0.0.0:0.
+func writeReply(conn io.Writer, code byte) error {
+	_, err := conn.Write([]byte{socksVersion, code, 0x00, atypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,207 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
+	"github.com/netsec-ethz/scion-apps/pkg/scionecho"
+)
+
+// echoDial returns a dialFunc opening a QUIC stream to an in-process
+// scionecho server. As there is no SCION network in unit tests, the server
+// listens on plain UDP on loopback. The requested destinations are sent to
+// dialed.
+func echoDial(t *testing.T, dialed chan<- string) dialFunc {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &scionecho.Server{Service: scionecho.Echo, QUIC: true}
+	if err := srv.Serve(serverConn); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	tlsConf := &tls.Config{InsecureSkipVerify: true, NextProtos: []string{scionecho.NextProto}}
+	return func(host string, port uint16) (net.Conn, error) {
+		dialed <- fmt.Sprintf("%s:%d", host, port)
+		sess, err := quic.DialAddr(serverConn.LocalAddr().String(), tlsConf, nil)
+		if err != nil {
+			return nil, &socksError{repConnectionRefused, err}
+		}
+		stream, err := sess.OpenStreamSync(context.Background())
+		if err != nil {
+			_ = sess.CloseWithError(0, "")
+			return nil, err
+		}
+		return appquic.NewStreamConn(sess, stream), nil
+	}
+}
+
+func startServer(t *testing.T, dial dialFunc) net.Addr {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		_ = serve(listener, dial)
+	}()
+	return listener.Addr()
+}
+
+// handshake performs the SOCKS handshake for a CONNECT request with a domain
+// name destination and returns the reply code.
+func handshake(t *testing.T, conn net.Conn, cmd byte, host string, port uint16) byte {
+	t.Helper()
+	if _, err := conn.Write([]byte{socksVersion, 1, authMethodNone}); err != nil {
+		t.Fatal(err)
+	}
+	method := make([]byte, 2)
+	if _, err := io.ReadFull(conn, method); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(method, []byte{socksVersion, authMethodNone}) {
+		t.Fatalf("unexpected method selection %v", method)
+	}
+	req := []byte{socksVersion, cmd, 0x00, atypDomain, byte(len(host))}
+	req = append(req, host...)
+	req = append(req, byte(port>>8), byte(port))
+	if _, err := conn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	reply := make([]byte, 10)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply[0] != socksVersion {
+		t.Fatalf("unexpected version in reply %v", reply)
+	}
+	return reply[1]
+}
+
+func TestConnectEcho(t *testing.T) {
+	dialed := make(chan string, 1)
+	addr := startServer(t, echoDial(t, dialed))
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if code := handshake(t, conn, cmdConnect, "1-ff00:0:110,[10.0.0.1]", 7); code != repSucceeded {
+		t.Fatalf("unexpected reply code %#02x", code)
+	}
+	for _, msg := range []string{"hello", "world", "over SCION"} {
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len(msg))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != msg {
+			t.Fatalf("unexpected echo, expected %q, got %q", msg, buf)
+		}
+	}
+	if d := <-dialed; d != "1-ff00:0:110,[10.0.0.1]:7" {
+		t.Fatalf("unexpected dialed destination %s", d)
+	}
+}
+
+// TestConnectHalfClose checks that closing the sending direction of the
+// client is passed on to the remote, and that the remaining data echoed by
+// the remote, followed by the EOF, still arrives at the client.
+func TestConnectHalfClose(t *testing.T) {
+	dialed := make(chan string, 1)
+	addr := startServer(t, echoDial(t, dialed))
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if code := handshake(t, conn, cmdConnect, "echo.scion", 7); code != repSucceeded {
+		t.Fatalf("unexpected reply code %#02x", code)
+	}
+	data := bytes.Repeat([]byte("half-closed "), 10000)
+	go func() {
+		if _, err := conn.Write(data); err != nil {
+			t.Error(err)
+		}
+		if err := conn.(*net.TCPConn).CloseWrite(); err != nil {
+			t.Error(err)
+		}
+	}()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	received, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received, data) {
+		t.Fatalf("unexpected echo, expected %d bytes, got %d", len(data), len(received))
+	}
+}
+
+func TestConnectError(t *testing.T) {
+	codes := []byte{repHostUnreachable, repNetworkUnreachable, repConnectionRefused}
+	for _, code := range codes {
+		dial := func(host string, port uint16) (net.Conn, error) {
+			return nil, &socksError{code, errors.New("test")}
+		}
+		addr := startServer(t, dial)
+		conn, err := net.Dial("tcp", addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := handshake(t, conn, cmdConnect, "host.scion", 80); actual != code {
+			t.Errorf("unexpected reply code, expected %#02x, got %#02x", code, actual)
+		}
+		conn.Close()
+	}
+}
+
+func TestUnsupportedCommand(t *testing.T) {
+	dialed := make(chan string, 1)
+	addr := startServer(t, echoDial(t, dialed))
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	const cmdBind = 0x02
+	if code := handshake(t, conn, cmdBind, "host.scion", 80); code != repCommandNotSupported {
+		t.Fatalf("unexpected reply code %#02x", code)
+	}
+	select {
+	case d := <-dialed:
+		t.Fatalf("unexpected dial for unsupported command: %s", d)
+	default:
+	}
+}
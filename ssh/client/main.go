@@ -22,7 +22,6 @@ import (
 	"net"
 	"os"
 	"os/user"
-	"strconv"
 	"strings"
 
 	log "github.com/inconshreveable/log15"
@@ -43,7 +42,9 @@ var (
 	serverAddress = kingpin.Arg("host-address", "Server SCION address (without the port)").Required().String()
 	runCommand    = kingpin.Arg("command", "Command to run (empty for pty)").Strings()
 	port          = kingpin.Flag("port", "The server's port").Default("0").Short('p').Uint16()
-	localForward  = kingpin.Flag("local-forward", "Forward remote address connections to listening port. Format: listening_port:remote_address").Short('L').String()
+	localForward  = kingpin.Flag("local-forward", "Forward connections to the local port over the server to the remote address. Format: [bind_address:]port:remote_address").Short('L').Strings()
+	remoteForward = kingpin.Flag("remote-forward", "Forward connections to the port on the server to the local address. Format: [bind_address:]port:local_address").Short('R').Strings()
+	sftpMode      = kingpin.Flag("sftp", "Start an interactive SFTP session to transfer files").Bool()
 	options       = kingpin.Flag("option", "Set an option").Short('o').Strings()
 	configFiles   = kingpin.Flag("config", "Configuration files").Short('c').Default("/etc/ssh/ssh_config", "~/.ssh/config").Strings()
 	policyFile    = kingpin.Flag("policy-file", "Path to the JSON policy file").Default("").String()
@@ -108,13 +109,21 @@ func createConfig() *clientconfig.ClientConfig {
 	setConfIfNot(conf, "Port", *port, 0)
 	setConfIfNot(conf, "HostAddress", *serverAddress, "")
 	setConfIfNot(conf, "IdentityFile", *identityFile, "")
-	setConfIfNot(conf, "LocalForward", *localForward, "")
 	setConfIfNot(conf, "User", *loginName, "")
 	setConfIfNot(conf, "KnownHostsFile", *knownHostsFile, "")
 
 	return conf
 }
 
+// forwardSpecs returns the forwards given as flags, followed by the one from
+// the configuration, if any.
+func forwardSpecs(flags []string, fromConfig string) []string {
+	if fromConfig != "" {
+		return append(flags, fromConfig)
+	}
+	return flags
+}
+
 func updateConfigFromFile(conf *clientconfig.ClientConfig, pth string) {
 	err := config.UpdateFromFile(conf, utils.ParsePath(pth))
 	if err != nil {
@@ -181,24 +190,41 @@ func main() {
 	}
 	defer sshClient.CloseSession()
 
-	if conf.LocalForward != "" {
-		localForward := strings.SplitN(conf.LocalForward, ":", 2)
-
-		port, err := strconv.ParseUint(localForward[0], 10, 16)
+	for _, spec := range forwardSpecs(*localForward, conf.LocalForward) {
+		f, err := ssh.ParseForward(spec)
 		if err != nil {
-			golog.Panicf("Error parsing forwarding port: %v", err)
+			golog.Panicf("Error parsing local forward: %v", err)
 		}
-
-		err = sshClient.StartTunnel(uint16(port), localForward[1])
+		err = sshClient.StartLocalForward(f)
+		if err != nil {
+			golog.Panicf("Error starting local forward %s: %v", spec, err)
+		}
+	}
+	for _, spec := range forwardSpecs(*remoteForward, conf.RemoteForward) {
+		f, err := ssh.ParseForward(spec)
+		if err != nil {
+			golog.Panicf("Error parsing remote forward: %v", err)
+		}
+		err = sshClient.StartRemoteForward(f)
 		if err != nil {
-			golog.Panicf("Error starting tunnel: %v", err)
+			golog.Panicf("Error starting remote forward %s: %v", spec, err)
 		}
 	}
 
 	// TODO Don't just join those!
 	runCommand := strings.Join((*runCommand)[:], " ")
 
-	if runCommand == "" {
+	if *sftpMode {
+		sftpClient, err := sshClient.SFTP()
+		if err != nil {
+			golog.Panicf("Error starting SFTP session: %v", err)
+		}
+		defer sftpClient.Close()
+		err = runSFTP(sftpClient, os.Stdin, os.Stdout)
+		if err != nil {
+			golog.Panicf("Error in SFTP session: %v", err)
+		}
+	} else if runCommand == "" {
 		err = sshClient.Shell()
 		if err != nil {
 			golog.Panicf("Error starting shell: %v", err)
@@ -0,0 +1,135 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/sftp"
+
+	"github.com/netsec-ethz/scion-apps/ssh/client/ssh"
+)
+
+const sftpHelp = `Available commands:
+  cd <dir>                      change remote directory
+  get <remote> [<local>]        download file
+  help                          show this help
+  ls [<dir>]                    list remote directory
+  mkdir <dir>                   create remote directory
+  put <local> [<remote>]        upload file
+  pwd                           print remote directory
+  rm <file>                     remove remote file
+  exit, quit, bye               quit
+`
+
+// runSFTP runs an interactive SFTP session, reading commands from in, in the
+// style of OpenSSH's sftp.
+func runSFTP(c *sftp.Client, in io.Reader, out io.Writer) error {
+	wd, err := c.Getwd()
+	if err != nil {
+		return err
+	}
+	remote := func(p string) string {
+		if path.IsAbs(p) {
+			return p
+		}
+		return path.Join(wd, p)
+	}
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "sftp> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		args := strings.Fields(scanner.Text())
+		if len(args) == 0 {
+			continue
+		}
+		cmd, args := args[0], args[1:]
+
+		var err error
+		switch {
+		case cmd == "exit" || cmd == "quit" || cmd == "bye":
+			return nil
+		case cmd == "help" || cmd == "?":
+			fmt.Fprint(out, sftpHelp)
+		case cmd == "pwd":
+			fmt.Fprintf(out, "Remote working directory: %s\n", wd)
+		case cmd == "cd" && len(args) == 1:
+			dir := remote(args[0])
+			var fi os.FileInfo
+			fi, err = c.Stat(dir)
+			if err == nil && !fi.IsDir() {
+				err = fmt.Errorf("not a directory: %s", dir)
+			}
+			if err == nil {
+				wd = dir
+			}
+		case cmd == "ls" && len(args) <= 1:
+			dir := wd
+			if len(args) == 1 {
+				dir = remote(args[0])
+			}
+			err = sftpList(c, dir, out)
+		case cmd == "mkdir" && len(args) == 1:
+			err = c.Mkdir(remote(args[0]))
+		case cmd == "rm" && len(args) == 1:
+			err = c.Remove(remote(args[0]))
+		case cmd == "put" && (len(args) == 1 || len(args) == 2):
+			dst := wd
+			if len(args) == 2 {
+				dst = remote(args[1])
+			}
+			fmt.Fprintf(out, "Uploading %s to %s\n", args[0], dst)
+			_, err = ssh.PutFile(c, args[0], dst)
+		case cmd == "get" && (len(args) == 1 || len(args) == 2):
+			dst := "."
+			if len(args) == 2 {
+				dst = args[1]
+			}
+			fmt.Fprintf(out, "Fetching %s to %s\n", remote(args[0]), filepath.Clean(dst))
+			_, err = ssh.GetFile(c, remote(args[0]), dst)
+		default:
+			err = fmt.Errorf("invalid command, type \"help\" for a list of commands")
+		}
+		if err != nil {
+			fmt.Fprintf(out, "%s: %v\n", cmd, err)
+		}
+	}
+}
+
+func sftpList(c *sftp.Client, dir string, out io.Writer) error {
+	entries, err := c.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() {
+			name += "/"
+		}
+		fmt.Fprintf(out, "%s %10d %s %s\n",
+			e.Mode(), e.Size(), e.ModTime().Format("Jan _2 15:04"), name)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	log "github.com/inconshreveable/log15"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
+)
+
+// Forward is a port forward, as specified with -L or -R.
+type Forward struct {
+	// BindAddr is the address to listen on; empty for the default.
+	BindAddr string
+	// Port is the port to listen on.
+	Port uint16
+	// Target is the address to which the connections are forwarded; a TCP
+	// address or, for local forwards only, a SCION address.
+	Target string
+}
+
+// ParseForward parses a forward specification of the form
+// [bind_address:]port:host:hostport, where host:hostport is either a TCP or a
+// SCION address. An IPv6 bind_address is enclosed in square brackets.
+// As in ssh_config, the port and the target may also be separated by
+// whitespace.
+func ParseForward(spec string) (Forward, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return Forward{}, fmt.Errorf("empty forward specification")
+	}
+	s := strings.Join(fields, ":")
+
+	var bindAddr, rest string
+	if strings.HasPrefix(s, "[") {
+		end := strings.Index(s, "]:")
+		if end < 0 {
+			return Forward{}, fmt.Errorf("invalid forward specification %q", spec)
+		}
+		bindAddr, rest = s[1:end], s[end+2:]
+	} else {
+		parts := strings.SplitN(s, ":", 2)
+		if len(parts) != 2 {
+			return Forward{}, fmt.Errorf("invalid forward specification %q", spec)
+		}
+		if _, err := strconv.ParseUint(parts[0], 10, 16); err == nil {
+			rest = s
+		} else {
+			bindAddr, rest = parts[0], parts[1]
+		}
+	}
+
+	parts := strings.SplitN(rest, ":", 2)
+	if len(parts) != 2 {
+		return Forward{}, fmt.Errorf("invalid forward specification %q", spec)
+	}
+	port, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil {
+		return Forward{}, fmt.Errorf("invalid port in forward specification %q", spec)
+	}
+	target := parts[1]
+	if err := checkTarget(target); err != nil {
+		return Forward{}, fmt.Errorf("invalid target in forward specification %q: %w", spec, err)
+	}
+	return Forward{BindAddr: bindAddr, Port: uint16(port), Target: target}, nil
+}
+
+func checkTarget(target string) error {
+	var port string
+	var err error
+	if strings.Contains(target, ",") {
+		_, port, err = appnet.SplitHostPort(target)
+	} else {
+		_, port, err = net.SplitHostPort(target)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// StartRemoteForward starts a remote forward (ssh -R): the server listens on
+// the port and forwards all connections over the SSH connection to the
+// client, which connects them to the target TCP address. As in OpenSSH, the
+// server listens on the loopback address by default.
+// The forward is stopped by CloseSession.
+func (client *Client) StartRemoteForward(f Forward) error {
+	if strings.Contains(f.Target, ",") {
+		return fmt.Errorf("remote forward to SCION address %s is not supported", f.Target)
+	}
+	// The channels for the forwarded connections are matched by the IP address
+	// of the listener, so the bind address must be an IP address.
+	bindAddr := f.BindAddr
+	switch bindAddr {
+	case "", "localhost":
+		bindAddr = "127.0.0.1"
+	case "*":
+		bindAddr = "0.0.0.0"
+	}
+	listener, err := client.client.Listen("tcp", net.JoinHostPort(bindAddr, strconv.Itoa(int(f.Port))))
+	if err != nil {
+		return err
+	}
+	client.addListener(listener)
+
+	go func() {
+		defer listener.Close()
+		for {
+			remoteConn, err := listener.Accept()
+			if err != nil {
+				log.Debug("Error accepting remote forward connection: ", err)
+				return
+			}
+
+			go func() {
+				localConn, err := net.Dial("tcp", f.Target)
+				if err != nil {
+					log.Debug("Error dialing remote forward target: ", err)
+					remoteConn.Close()
+					return
+				}
+				appquic.Pipe(localConn, remoteConn)
+			}()
+		}
+	}()
+
+	return nil
+}
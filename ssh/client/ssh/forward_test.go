@@ -0,0 +1,61 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"testing"
+)
+
+func TestParseForward(t *testing.T) {
+	cases := []struct {
+		spec     string
+		expected Forward
+		ok       bool
+	}{
+		{"8080:localhost:80", Forward{"", 8080, "localhost:80"}, true},
+		{"8080:127.0.0.1:80", Forward{"", 8080, "127.0.0.1:80"}, true},
+		{"8080:[::1]:80", Forward{"", 8080, "[::1]:80"}, true},
+		{"localhost:8080:localhost:80", Forward{"localhost", 8080, "localhost:80"}, true},
+		{"*:8080:localhost:80", Forward{"*", 8080, "localhost:80"}, true},
+		{"127.0.0.1:8080:example.com:80", Forward{"127.0.0.1", 8080, "example.com:80"}, true},
+		{"[::1]:8080:localhost:80", Forward{"::1", 8080, "localhost:80"}, true},
+		{"8080:1-ff00:0:110,[10.0.0.1]:80", Forward{"", 8080, "1-ff00:0:110,[10.0.0.1]:80"}, true},
+		{"8080:1-ff00:0:110,10.0.0.1:80", Forward{"", 8080, "1-ff00:0:110,10.0.0.1:80"}, true},
+		{"8080 localhost:80", Forward{"", 8080, "localhost:80"}, true},
+		{"localhost:8080 localhost:80", Forward{"localhost", 8080, "localhost:80"}, true},
+		{"", Forward{}, false},
+		{"8080", Forward{}, false},
+		{"8080:localhost", Forward{}, false},
+		{"8080:localhost:http", Forward{}, false},
+		{"99999:localhost:80", Forward{}, false},
+		{"localhost:x:localhost:80", Forward{}, false},
+		{"[::1:8080:localhost:80", Forward{}, false},
+		{"8080:1-ff00:0:110,[10.0.0.1]", Forward{}, false},
+	}
+	for _, c := range cases {
+		actual, err := ParseForward(c.spec)
+		if !c.ok {
+			if err == nil {
+				t.Errorf("ParseForward(%q): expected error, got %+v", c.spec, actual)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseForward(%q): unexpected error %s", c.spec, err)
+		} else if actual != c.expected {
+			t.Errorf("ParseForward(%q): expected %+v, got %+v", c.spec, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/sftp"
+)
+
+// SFTP starts an SFTP session on a new channel of the connection. The
+// returned client must be closed after use.
+func (client *Client) SFTP() (*sftp.Client, error) {
+	return sftp.NewClient(client.client)
+}
+
+// PutFile copies the local file localPath to remotePath on the server. If
+// remotePath is an existing directory, the file is copied into this directory.
+// The file is streamed, so large files are not buffered in memory.
+// Returns the number of bytes copied.
+func PutFile(c *sftp.Client, localPath, remotePath string) (int64, error) {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	if fi, err := c.Stat(remotePath); err == nil && fi.IsDir() {
+		remotePath = path.Join(remotePath, filepath.Base(localPath))
+	}
+	dst, err := c.Create(remotePath)
+	if err != nil {
+		return 0, err
+	}
+	n, err := dst.ReadFrom(src)
+	if err != nil {
+		_ = dst.Close()
+		return n, err
+	}
+	return n, dst.Close()
+}
+
+// GetFile copies the file remotePath from the server to localPath. If
+// localPath is an existing directory, the file is copied into this directory.
+// The file is streamed, so large files are not buffered in memory.
+// Returns the number of bytes copied.
+func GetFile(c *sftp.Client, remotePath, localPath string) (int64, error) {
+	src, err := c.Open(remotePath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	if fi, err := os.Stat(localPath); err == nil && fi.IsDir() {
+		localPath = filepath.Join(localPath, path.Base(remotePath))
+	}
+	dst, err := os.Create(localPath)
+	if err != nil {
+		return 0, err
+	}
+	n, err := src.WriteTo(dst)
+	if err != nil {
+		_ = dst.Close()
+		return n, err
+	}
+	return n, dst.Close()
+}
@@ -23,6 +23,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -55,6 +56,9 @@ type Client struct {
 	client  *ssh.Client
 	session *ssh.Session
 	appConf *scionutils.PathAppConf
+
+	mutex     sync.Mutex
+	listeners []io.Closer // listeners of the forwards
 }
 
 // Create creates a new unconnected Client.
@@ -176,32 +180,26 @@ func (client *Client) forward(addr string, localConn net.Conn) error {
 		return err
 	}
 
-	close := func() {
-		localConn.Close()
-		remoteConn.Close()
-	}
-	_ = close
-
-	var once sync.Once
-	go func() {
-		io.Copy(localConn, remoteConn)
-		once.Do(close)
-	}()
-	go func() {
-		io.Copy(remoteConn, localConn)
-		once.Do(close)
-	}()
-
+	go appquic.Pipe(localConn, remoteConn)
 	return nil
 }
 
 // StartTunnel creates a new tunnel to the given address, forwarding all connections on the given port over the server to the given address. If the given address is a SCION address, QUIC is used; else TCP.
 func (client *Client) StartTunnel(localPort uint16, addr string) error {
-	if strings.Contains(addr, ",") {
-		localListener, err := appquic.ListenPort(localPort, nil, nil)
+	return client.StartLocalForward(Forward{Port: localPort, Target: addr})
+}
+
+// StartLocalForward starts a local forward (ssh -L), forwarding all
+// connections on the local port over the server to the target address. If the
+// target address is a SCION address, QUIC is used for both the local listener
+// and the target; else TCP. The forward is stopped by CloseSession.
+func (client *Client) StartLocalForward(f Forward) error {
+	if strings.Contains(f.Target, ",") {
+		localListener, err := appquic.ListenPort(f.Port, nil, nil)
 		if err != nil {
 			return err
 		}
+		client.addListener(localListener)
 
 		go func() {
 			defer localListener.Close()
@@ -209,7 +207,7 @@ func (client *Client) StartTunnel(localPort uint16, addr string) error {
 				sess, err := localListener.Accept(context.Background())
 				if err != nil {
 					log.Debug("Error accepting tunnel listener: ", err)
-					continue
+					return
 				}
 
 				stream, err := sess.AcceptStream(context.Background())
@@ -218,7 +216,7 @@ func (client *Client) StartTunnel(localPort uint16, addr string) error {
 					continue
 				}
 
-				err = client.forward(addr, &quicconn.QuicConn{Session: sess, Stream: stream})
+				err = client.forward(f.Target, &quicconn.QuicConn{Session: sess, Stream: stream})
 				if err != nil {
 					log.Debug("Error forwarding connection: ", err)
 					continue
@@ -226,10 +224,15 @@ func (client *Client) StartTunnel(localPort uint16, addr string) error {
 			}
 		}()
 	} else {
-		localListener, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%v", localPort))
+		bindAddr := f.BindAddr
+		if bindAddr == "" {
+			bindAddr = "0.0.0.0"
+		}
+		localListener, err := net.Listen("tcp", net.JoinHostPort(bindAddr, strconv.Itoa(int(f.Port))))
 		if err != nil {
 			return err
 		}
+		client.addListener(localListener)
 
 		go func() {
 			defer localListener.Close()
@@ -237,12 +240,13 @@ func (client *Client) StartTunnel(localPort uint16, addr string) error {
 				localConn, err := localListener.Accept()
 				if err != nil {
 					log.Debug("Error accepting tunnel listener: ", err)
-					continue
+					return
 				}
 
-				err = client.forward(addr, localConn)
+				err = client.forward(f.Target, localConn)
 				if err != nil {
 					log.Debug("Error forwarding connection: ", err)
+					localConn.Close()
 					continue
 				}
 			}
@@ -272,9 +276,22 @@ func (client *Client) DialSCION(addr string) (net.Conn, error) {
 	return sssh.TunnelDialSCION(client.client, addr)
 }
 
-// CloseSession closes the current session
+// CloseSession closes the current session and stops all forwards.
 func (client *Client) CloseSession() {
 	client.session.Close()
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	for _, l := range client.listeners {
+		l.Close()
+	}
+	client.listeners = nil
+}
+
+func (client *Client) addListener(l io.Closer) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.listeners = append(client.listeners, l)
 }
 
 func loadPrivateKey(filePath string) (ssh.AuthMethod, error) {
@@ -73,6 +73,16 @@ func updateConfigFromFile(conf *serverconfig.ServerConfig, pth string) {
 }
 
 func main() {
+	// The server re-executes itself to serve the SFTP subsystem for a session.
+	// This is checked before parsing the flags, as the configuration file is
+	// not used in this case.
+	if len(os.Args) == 2 && os.Args[1] == "--"+ssh.SFTPServerFlag {
+		if err := ssh.ServeSFTP(); err != nil {
+			golog.Fatalf("SFTP server failed: %v", err)
+		}
+		return
+	}
+
 	kingpin.Parse()
 	log.Debug("Starting SCION SSH server...")
 
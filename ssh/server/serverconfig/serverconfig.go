@@ -22,6 +22,8 @@ type ServerConfig struct {
 	PubkeyAuthentication   string `regex:"(yes|no)"`
 	HostKey                string `regex:".*"`
 	MaxAuthTries           string `regex:"[1-9]\\d*"`
+	AllowTcpForwarding     string `regex:"(yes|no|local|remote)"`
+	GatewayPorts           string `regex:"(yes|no|clientspecified)"`
 }
 
 // Create creates a new ServerConfig with the default values.
@@ -32,5 +34,7 @@ func Create() *ServerConfig {
 		PasswordAuthentication: "yes",
 		PubkeyAuthentication:   "yes",
 		HostKey:                "/etc/ssh/ssh_host_key",
+		AllowTcpForwarding:     "yes",
+		GatewayPorts:           "no",
 	}
 }
@@ -0,0 +1,227 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"net"
+	"strconv"
+	"sync"
+
+	log "github.com/inconshreveable/log15"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
+)
+
+// tcpipForwardRequest is the payload of a "tcpip-forward" and a
+// "cancel-tcpip-forward" global request (RFC 4254, section 7.1).
+type tcpipForwardRequest struct {
+	BindAddr string
+	BindPort uint32
+}
+
+// tcpipForwardReply is the reply to a "tcpip-forward" request for port 0.
+type tcpipForwardReply struct {
+	BindPort uint32
+}
+
+// forwardedTCPIPData is the payload of a "forwarded-tcpip" channel
+// (RFC 4254, section 7.2).
+type forwardedTCPIPData struct {
+	Addr       string
+	Port       uint32
+	OriginAddr string
+	OriginPort uint32
+}
+
+// remoteForwards are the listeners for the remote forwards (ssh -R) requested
+// by the client of a connection.
+type remoteForwards struct {
+	conn         ssh.Conn
+	perms        *ssh.Permissions
+	allowed      bool
+	gatewayPorts string
+
+	mutex     sync.Mutex
+	listeners map[string]net.Listener // by bind address and bound port
+	closed    bool
+}
+
+// newRemoteForwards creates the remote forwards for a connection, with the
+// permissions of the authenticated user. If allowed is false, all forwarding
+// requests are rejected. gatewayPorts is as for Server.
+func newRemoteForwards(conn ssh.Conn, perms *ssh.Permissions, allowed bool, gatewayPorts string) *remoteForwards {
+	return &remoteForwards{
+		conn:         conn,
+		perms:        perms,
+		allowed:      allowed,
+		gatewayPorts: gatewayPorts,
+		listeners:    make(map[string]net.Listener),
+	}
+}
+
+// handleRequests handles the forwarding requests, if allowed, and rejects all
+// other global requests.
+func (f *remoteForwards) handleRequests(reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		switch {
+		case f.allowed && req.Type == "tcpip-forward":
+			f.handleForward(req)
+		case f.allowed && req.Type == "cancel-tcpip-forward":
+			f.handleCancel(req)
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+func (f *remoteForwards) handleForward(req *ssh.Request) {
+	var payload tcpipForwardRequest
+	if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+		log.Debug("Invalid tcpip-forward request", "err", err)
+		req.Reply(false, nil)
+		return
+	}
+	if !f.mayBind(payload.BindPort) {
+		log.Debug("Rejected remote forward on privileged port", "port", payload.BindPort)
+		req.Reply(false, nil)
+		return
+	}
+	listener, err := net.Listen("tcp", listenAddr(f.gatewayPorts, payload.BindAddr, payload.BindPort))
+	if err != nil {
+		log.Debug("Could not listen for remote forward", "err", err)
+		req.Reply(false, nil)
+		return
+	}
+	port := uint32(listener.Addr().(*net.TCPAddr).Port)
+	key := net.JoinHostPort(payload.BindAddr, strconv.Itoa(int(port)))
+
+	f.mutex.Lock()
+	if _, exists := f.listeners[key]; exists || f.closed {
+		f.mutex.Unlock()
+		listener.Close()
+		req.Reply(false, nil)
+		return
+	}
+	f.listeners[key] = listener
+	f.mutex.Unlock()
+
+	var reply []byte
+	if payload.BindPort == 0 {
+		reply = ssh.Marshal(&tcpipForwardReply{port})
+	}
+	req.Reply(true, reply)
+	log.Debug("Started remote forward", "listen", listener.Addr())
+
+	go f.serve(listener, payload.BindAddr, port)
+}
+
+// mayBind returns whether the user may listen on port. As for OpenSSH, only
+// root may listen on privileged ports, even though the listeners are opened
+// by the server process, which usually runs as root.
+func (f *remoteForwards) mayBind(port uint32) bool {
+	if port == 0 || port >= 1024 {
+		return true
+	}
+	usr, err := authenticatedUser(f.perms)
+	if err != nil {
+		log.Debug("Could not look up user", "err", err)
+		return false
+	}
+	return usr.Uid == "0"
+}
+
+func (f *remoteForwards) handleCancel(req *ssh.Request) {
+	var payload tcpipForwardRequest
+	if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+		req.Reply(false, nil)
+		return
+	}
+	key := net.JoinHostPort(payload.BindAddr, strconv.Itoa(int(payload.BindPort)))
+
+	f.mutex.Lock()
+	listener, exists := f.listeners[key]
+	delete(f.listeners, key)
+	f.mutex.Unlock()
+
+	if exists {
+		listener.Close()
+	}
+	req.Reply(exists, nil)
+}
+
+// serve forwards the connections accepted on listener to the client, until
+// the listener is closed.
+func (f *remoteForwards) serve(listener net.Listener, bindAddr string, port uint32) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			origin := conn.RemoteAddr().(*net.TCPAddr)
+			data := forwardedTCPIPData{
+				Addr:       bindAddr,
+				Port:       port,
+				OriginAddr: origin.IP.String(),
+				OriginPort: uint32(origin.Port),
+			}
+			channel, requests, err := f.conn.OpenChannel("forwarded-tcpip", ssh.Marshal(&data))
+			if err != nil {
+				log.Debug("Could not open forwarded-tcpip channel", "err", err)
+				conn.Close()
+				return
+			}
+			go ssh.DiscardRequests(requests)
+			appquic.Pipe(channel, conn)
+		}()
+	}
+}
+
+// close stops all remote forwards of the connection.
+func (f *remoteForwards) close() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.closed = true
+	for key, listener := range f.listeners {
+		listener.Close()
+		delete(f.listeners, key)
+	}
+}
+
+// listenAddr returns the address to listen on for a remote forward, as for
+// OpenSSH's GatewayPorts option: with "no", the loopback address is used
+// regardless of the requested address, with "yes" all addresses. With
+// "clientspecified", an empty address or "localhost" means the loopback address,
+// "*" all addresses, and any other address is used as requested.
+func listenAddr(gatewayPorts, bindAddr string, port uint32) string {
+	switch gatewayPorts {
+	case "yes":
+		bindAddr = ""
+	case "clientspecified":
+		switch bindAddr {
+		case "", "localhost":
+			bindAddr = "127.0.0.1"
+		case "*":
+			bindAddr = ""
+		}
+	default:
+		bindAddr = "127.0.0.1"
+	}
+	return net.JoinHostPort(bindAddr, strconv.Itoa(int(port)))
+}
@@ -0,0 +1,211 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build integration
+
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/scionproto/scion/go/lib/snet"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
+	"github.com/netsec-ethz/scion-apps/pkg/integration"
+	"github.com/netsec-ethz/scion-apps/ssh/client/clientconfig"
+	sshclient "github.com/netsec-ethz/scion-apps/ssh/client/ssh"
+	"github.com/netsec-ethz/scion-apps/ssh/quicconn"
+	"github.com/netsec-ethz/scion-apps/ssh/scionutils"
+)
+
+const testPassword = "forward"
+
+func TestIntegrationForward(t *testing.T) {
+	if err := integration.Init("ssh-forward"); err != nil {
+		t.Fatalf("Failed to init: %s\n", err)
+	}
+
+	serverAddr := startTestServer(t)
+	client := connectTestClient(t, serverAddr)
+	defer client.CloseSession()
+
+	t.Run("local", func(t *testing.T) {
+		echo := startEchoServer(t)
+		defer echo.Close()
+
+		port := freePort(t)
+		f := sshclient.Forward{BindAddr: "127.0.0.1", Port: port, Target: echo.Addr().String()}
+		if err := client.StartLocalForward(f); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			checkEcho(t, net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port))), fmt.Sprintf("local %d", i))
+		}
+	})
+
+	t.Run("remote", func(t *testing.T) {
+		echo := startEchoServer(t)
+		defer echo.Close()
+
+		port := freePort(t)
+		f := sshclient.Forward{Port: port, Target: echo.Addr().String()}
+		if err := client.StartRemoteForward(f); err != nil {
+			t.Fatal(err)
+		}
+		// The server is in-process, so its loopback listener is reachable here.
+		for i := 0; i < 3; i++ {
+			checkEcho(t, net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port))), fmt.Sprintf("remote %d", i))
+		}
+	})
+}
+
+// startTestServer starts a server accepting the testPassword for any user and
+// returns its address.
+func startTestServer(t *testing.T) string {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{
+		configuration: &ssh.ServerConfig{
+			PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+				if string(password) != testPassword {
+					return nil, fmt.Errorf("wrong password")
+				}
+				return &ssh.Permissions{}, nil
+			},
+		},
+		channelHandlers: map[string]ChannelHandlerFunction{
+			"session":      handleSession,
+			"direct-tcpip": handleTCPTunnel,
+		},
+		allowRemoteForwarding: true,
+		gatewayPorts:          "no",
+	}
+	server.configuration.AddHostKey(signer)
+
+	listener, err := appquic.ListenPort(0, &tls.Config{
+		Certificates: appquic.GetDummyTLSCerts(),
+		NextProtos:   []string{quicconn.ProtoSSH},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			sess, err := listener.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			stream, err := sess.AcceptStream(context.Background())
+			if err != nil {
+				continue
+			}
+			go server.HandleConnection(&quicconn.QuicConn{Session: sess, Stream: stream})
+		}
+	}()
+
+	local := listener.Addr().(*net.UDPAddr)
+	return appnet.FormatUDPAddr(&snet.UDPAddr{IA: appnet.DefNetwork().IA, Host: local})
+}
+
+func connectTestClient(t *testing.T, serverAddr string) *sshclient.Client {
+	conf := clientconfig.Create()
+	conf.PubkeyAuthentication = "no"
+	conf.StrictHostKeyChecking = "no"
+	appConf, err := scionutils.NewPathAppConf(nil, "arbitrary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	password := func() (string, error) { return testPassword, nil }
+	client, err := sshclient.Create("test", conf, password, nil, appConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(serverAddr); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// startEchoServer starts a TCP server on the loopback address, echoing all
+// data on each connection.
+func startEchoServer(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener
+}
+
+// freePort returns a TCP port that is currently not in use on the loopback
+// address.
+func freePort(t *testing.T) uint16 {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return uint16(l.Addr().(*net.TCPAddr).Port)
+}
+
+func checkEcho(t *testing.T, addr, message string) {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := fmt.Fprintln(conn, message); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != message+"\n" {
+		t.Errorf("expected %q, got %q", message+"\n", reply)
+	}
+}
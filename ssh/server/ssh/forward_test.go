@@ -0,0 +1,91 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"os/user"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestListenAddr(t *testing.T) {
+	cases := []struct {
+		gatewayPorts string
+		bindAddr     string
+		expected     string
+	}{
+		{"no", "", "127.0.0.1:8080"},
+		{"no", "*", "127.0.0.1:8080"},
+		{"no", "0.0.0.0", "127.0.0.1:8080"},
+		{"no", "10.0.0.1", "127.0.0.1:8080"},
+		{"", "*", "127.0.0.1:8080"},
+		{"yes", "", ":8080"},
+		{"yes", "localhost", ":8080"},
+		{"clientspecified", "", "127.0.0.1:8080"},
+		{"clientspecified", "localhost", "127.0.0.1:8080"},
+		{"clientspecified", "*", ":8080"},
+		{"clientspecified", "10.0.0.1", "10.0.0.1:8080"},
+		{"clientspecified", "::1", "[::1]:8080"},
+	}
+	for _, c := range cases {
+		actual := listenAddr(c.gatewayPorts, c.bindAddr, 8080)
+		if actual != c.expected {
+			t.Errorf("listenAddr(%q, %q): expected %q, got %q", c.gatewayPorts, c.bindAddr, c.expected, actual)
+		}
+	}
+}
+
+func TestRemoteForwardPrivilegedPort(t *testing.T) {
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("no unprivileged user to test with:", err)
+	}
+	permsFor := func(username string) *ssh.Permissions {
+		return &ssh.Permissions{CriticalOptions: map[string]string{"user": username}}
+	}
+	cases := []struct {
+		user     string
+		port     uint32
+		expected bool
+	}{
+		{"root", 80, true},
+		{"root", 1023, true},
+		{"root", 8080, true},
+		{nobody.Username, 0, true},
+		{nobody.Username, 1, false},
+		{nobody.Username, 80, false},
+		{nobody.Username, 1023, false},
+		{nobody.Username, 1024, true},
+		{"no-such-user-for-forward-test", 80, false},
+	}
+	for _, c := range cases {
+		f := newRemoteForwards(nil, permsFor(c.user), true, "no")
+		if actual := f.mayBind(c.port); actual != c.expected {
+			t.Errorf("mayBind(%d) for %s: expected %v, got %v", c.port, c.user, c.expected, actual)
+		}
+	}
+
+	// the request is rejected before listening
+	f := newRemoteForwards(nil, permsFor(nobody.Username), true, "yes")
+	f.handleForward(&ssh.Request{
+		Type:    "tcpip-forward",
+		Payload: ssh.Marshal(&tcpipForwardRequest{BindAddr: "", BindPort: 80}),
+	})
+	if len(f.listeners) != 0 {
+		f.close()
+		t.Errorf("expected forward on privileged port to be rejected")
+	}
+}
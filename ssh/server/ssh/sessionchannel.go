@@ -31,6 +31,28 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
+// execRequest is the payload of an "exec" request (RFC 4254, section 6.5).
+type execRequest struct {
+	Command string
+}
+
+// subsystemRequest is the payload of a "subsystem" request (RFC 4254,
+// section 6.5).
+type subsystemRequest struct {
+	Name string
+}
+
+// authenticatedUser returns the user authenticated for the connection with
+// perms, or the user running the server if no user was recorded.
+func authenticatedUser(perms *ssh.Permissions) (*user.User, error) {
+	if perms != nil {
+		if username, ok := perms.CriticalOptions["user"]; ok {
+			return user.Lookup(username)
+		}
+	}
+	return user.Current()
+}
+
 func handleSession(perms *ssh.Permissions, newChannel ssh.NewChannel) {
 	connection, requests, err := newChannel.Accept()
 	if err != nil {
@@ -55,19 +77,9 @@ func handleSession(perms *ssh.Permissions, newChannel ssh.NewChannel) {
 
 	execCmd := func(name string, arg ...string) error {
 		cmd := exec.Command(name, arg...)
-		username, ok := perms.CriticalOptions["user"]
-		var usr *user.User
-		if ok {
-			var err error
-			usr, err = user.Lookup(username)
-			if err != nil {
-				return err
-			}
-		} else {
-			usr, err = user.Current()
-			if err != nil {
-				return err
-			}
+		usr, err := authenticatedUser(perms)
+		if err != nil {
+			return err
 		}
 		uid, err := strconv.ParseUint(usr.Uid, 10, 32)
 		if err != nil {
@@ -77,12 +89,16 @@ func handleSession(perms *ssh.Permissions, newChannel ssh.NewChannel) {
 		if err != nil {
 			return err
 		}
-		if cmd.SysProcAttr == nil {
-			cmd.SysProcAttr = &syscall.SysProcAttr{}
-		}
-		cmd.SysProcAttr.Credential = &syscall.Credential{
-			Uid: uint32(uid),
-			Gid: uint32(gid),
+		// Switch to the user, unless the server already runs as this user (which
+		// does not require any privileges)
+		if int(uid) != os.Getuid() || int(gid) != os.Getgid() {
+			if cmd.SysProcAttr == nil {
+				cmd.SysProcAttr = &syscall.SysProcAttr{}
+			}
+			cmd.SysProcAttr.Credential = &syscall.Credential{
+				Uid: uint32(uid),
+				Gid: uint32(gid),
+			}
 		}
 		close := func() {
 			cmd.Process.Kill()
@@ -151,6 +167,8 @@ func handleSession(perms *ssh.Permissions, newChannel ssh.NewChannel) {
 			go func() {
 				_, err := io.Copy(stdin, connection)
 				log.Debug("Stdin copy ended", "error", err)
+				// forward EOF, e.g. for the SFTP subsystem to terminate
+				stdin.Close()
 			}()
 			go func() {
 				_, err := io.Copy(connection, stdout)
@@ -204,9 +222,15 @@ func handleSession(perms *ssh.Permissions, newChannel ssh.NewChannel) {
 					}
 				}
 			case "exec":
-				cmdStrLen := binary.BigEndian.Uint32(req.Payload[0:4])
-				cmdStr := string(req.Payload[4 : cmdStrLen+4])
-				err := execCmd("bash", "-c", cmdStr)
+				var payload execRequest
+				if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+					log.Debug("Invalid exec request", "error", err)
+					if req.WantReply {
+						req.Reply(false, nil)
+					}
+					continue
+				}
+				err := execCmd("bash", "-c", payload.Command)
 				if err != nil {
 					log.Error("Can't create shell!", "error", err)
 				}
@@ -214,6 +238,29 @@ func handleSession(perms *ssh.Permissions, newChannel ssh.NewChannel) {
 				if req.WantReply {
 					req.Reply(true, nil)
 				}
+			case "subsystem":
+				var payload subsystemRequest
+				if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+					log.Debug("Invalid subsystem request", "error", err)
+					if req.WantReply {
+						req.Reply(false, nil)
+					}
+					continue
+				}
+				ok := false
+				if payload.Name == "sftp" {
+					err := execSFTPServer(execCmd)
+					if err != nil {
+						log.Error("Can't start SFTP server!", "error", err)
+					}
+					ok = err == nil
+				} else {
+					log.Debug("Unknown subsystem", "name", payload.Name)
+				}
+
+				if req.WantReply {
+					req.Reply(ok, nil)
+				}
 			default:
 				log.Debug("Unknown session request type %s", req.Type)
 			}
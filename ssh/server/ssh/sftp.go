@@ -0,0 +1,82 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"io"
+	"os"
+	"os/user"
+
+	"github.com/pkg/sftp"
+)
+
+// SFTPServerFlag is the command line flag of the server binary, with which
+// the server binary is started to serve the "sftp" subsystem.
+//
+// Like OpenSSH's sftp-server, the SFTP server runs in a separate process as
+// the logged in user, so that the file system permissions apply.
+const SFTPServerFlag = "sftp-server"
+
+// execSFTPServer starts the SFTP server process for a session, by running
+// this executable with the SFTPServerFlag.
+func execSFTPServer(execCmd func(name string, arg ...string) error) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return execCmd(self, "--"+SFTPServerFlag)
+}
+
+// ServeSFTP serves SFTP on stdin/stdout, until the client closes the session.
+// Relative paths are resolved relative to the home directory of the current
+// user.
+func ServeSFTP() error {
+	usr, err := user.Current()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(usr.HomeDir); err != nil {
+		return err
+	}
+	return serveSFTP(stdio{})
+}
+
+func serveSFTP(rwc io.ReadWriteCloser) error {
+	server, err := sftp.NewServer(rwc)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+	err = server.Serve()
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// stdio is an io.ReadWriteCloser reading from stdin and writing to stdout.
+type stdio struct{}
+
+func (stdio) Read(b []byte) (int, error) {
+	return os.Stdin.Read(b)
+}
+
+func (stdio) Write(b []byte) (int, error) {
+	return os.Stdout.Write(b)
+}
+
+func (stdio) Close() error {
+	return os.Stdout.Close()
+}
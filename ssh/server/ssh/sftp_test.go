@@ -0,0 +1,195 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	sshclient "github.com/netsec-ethz/scion-apps/ssh/client/ssh"
+)
+
+// TestMain serves SFTP when the test binary is re-executed by the session
+// handler with the SFTPServerFlag, as the server binary does.
+func TestMain(m *testing.M) {
+	if len(os.Args) == 2 && os.Args[1] == "--"+SFTPServerFlag {
+		if err := ServeSFTP(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// TestSFTP transfers a file in both directions over the "sftp" subsystem of a
+// session handled by the server's session handler.
+func TestSFTP(t *testing.T) {
+	sshClient := startSessionTestServer(t)
+
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sftpClient.Close()
+
+	dir := t.TempDir()
+	content := make([]byte, 8<<20)
+	_, _ = rand.Read(content)
+	local := filepath.Join(dir, "local")
+	if err := ioutil.WriteFile(local, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// upload into a directory
+	remoteDir := filepath.Join(dir, "remote")
+	if err := sftpClient.Mkdir(remoteDir); err != nil {
+		t.Fatal(err)
+	}
+	n, err := sshclient.PutFile(sftpClient, local, remoteDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(content)) {
+		t.Fatalf("put: expected %d bytes, got %d", len(content), n)
+	}
+	uploaded, err := ioutil.ReadFile(filepath.Join(remoteDir, "local"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(uploaded, content) {
+		t.Fatal("put: content mismatch")
+	}
+
+	// and back again
+	downloaded := filepath.Join(dir, "downloaded")
+	n, err = sshclient.GetFile(sftpClient, filepath.Join(remoteDir, "local"), downloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(content)) {
+		t.Fatalf("get: expected %d bytes, got %d", len(content), n)
+	}
+	actual, err := ioutil.ReadFile(downloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, content) {
+		t.Fatal("get: content mismatch")
+	}
+
+	if _, err := sshclient.GetFile(sftpClient, filepath.Join(dir, "nonexisting"), downloaded); err == nil {
+		t.Error("get: expected error for nonexisting file")
+	}
+}
+
+// TestSessionInvalidPayload checks that malformed exec and subsystem requests
+// are rejected.
+func TestSessionInvalidPayload(t *testing.T) {
+	sshClient := startSessionTestServer(t)
+
+	payloads := [][]byte{
+		nil,
+		{0, 0},
+		{0, 0, 0, 100, 's', 'f', 't', 'p'},
+		{0xff, 0xff, 0xff, 0xff},
+	}
+	for _, reqType := range []string{"exec", "subsystem"} {
+		for _, payload := range payloads {
+			channel, _, err := sshClient.OpenChannel("session", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ok, err := channel.SendRequest(reqType, true, payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok {
+				t.Errorf("%s request with payload %v accepted", reqType, payload)
+			}
+			channel.Close()
+		}
+	}
+
+	// the server is still alive
+	channel, _, err := sshClient.OpenChannel("session", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := channel.SendRequest("subsystem", true, ssh.Marshal(&subsystemRequest{Name: "unknown"}))
+	if err != nil || ok {
+		t.Errorf("expected unknown subsystem to be rejected, got %v, %v", ok, err)
+	}
+	channel.Close()
+}
+
+// startSessionTestServer starts a server with the session handler on a
+// loopback TCP socket, and returns a client connected to it.
+func startSessionTestServer(t *testing.T) *ssh.Client {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{
+		configuration: &ssh.ServerConfig{
+			PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+				return &ssh.Permissions{}, nil
+			},
+		},
+		channelHandlers: map[string]ChannelHandlerFunction{
+			"session": handleSession,
+		},
+	}
+	server.configuration.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.HandleConnection(conn)
+		}
+	}()
+
+	sshClient, err := ssh.Dial("tcp", listener.Addr().String(), &ssh.ClientConfig{
+		Auth:            []ssh.AuthMethod{ssh.Password("")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sshClient.Close() })
+	return sshClient
+}
@@ -38,6 +38,15 @@ type Server struct {
 	configuration *ssh.ServerConfig
 
 	channelHandlers map[string]ChannelHandlerFunction
+
+	// allowRemoteForwarding enables remote forwards (ssh -R), see
+	// AllowTcpForwarding.
+	allowRemoteForwarding bool
+	// gatewayPorts determines the address on which remote forwards listen, as
+	// the GatewayPorts option of OpenSSH: "no" for the loopback address, "yes"
+	// for all addresses, "clientspecified" for the address requested by the
+	// client.
+	gatewayPorts string
 }
 
 // Create creates a new unconnected Server object.
@@ -66,8 +75,12 @@ func Create(config *serverconfig.ServerConfig, version string) (*Server, error)
 	server.configuration.AddHostKey(private)
 
 	server.channelHandlers["session"] = handleSession
-	server.channelHandlers["direct-tcpip"] = handleTCPTunnel
-	server.channelHandlers["direct-scionquic"] = handleSCIONQUICTunnel
+	if config.AllowTcpForwarding == "yes" || config.AllowTcpForwarding == "local" {
+		server.channelHandlers["direct-tcpip"] = handleTCPTunnel
+		server.channelHandlers["direct-scionquic"] = handleSCIONQUICTunnel
+	}
+	server.allowRemoteForwarding = config.AllowTcpForwarding == "yes" || config.AllowTcpForwarding == "remote"
+	server.gatewayPorts = config.GatewayPorts
 
 	return server, nil
 }
@@ -99,8 +112,10 @@ func (s *Server) HandleConnection(conn net.Conn) error {
 	}
 
 	log.Debug("New SSH connection", "remoteAddress", sshConn.RemoteAddr(), "clientVersion", sshConn.ClientVersion())
-	// Discard all global out-of-band Requests
-	go ssh.DiscardRequests(reqs)
+	// Handle remote forwarding requests, reject all other global requests
+	forwards := newRemoteForwards(sshConn, sshConn.Permissions, s.allowRemoteForwarding, s.gatewayPorts)
+	defer forwards.close()
+	go forwards.handleRequests(reqs)
 	// Accept all channels
 	s.handleChannels(sshConn.Permissions, chans)
 
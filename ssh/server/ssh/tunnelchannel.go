@@ -15,41 +15,36 @@
 package ssh
 
 import (
-	"encoding/binary"
-	"fmt"
-	"io"
 	"net"
-	"sync"
+	"strconv"
 
 	log "github.com/inconshreveable/log15"
 
+	"github.com/netsec-ethz/scion-apps/pkg/appnet/appquic"
 	"github.com/netsec-ethz/scion-apps/ssh/quicconn"
 	"golang.org/x/crypto/ssh"
 )
 
-func handleTunnelForRemoteConnection(connection ssh.Channel, remoteConnection net.Conn) {
-	// Prepare teardown function
-	close := func() {
-		connection.Close()
-		remoteConnection.Close()
-	}
+// directTCPIPData is the payload of a "direct-tcpip" channel (RFC 4254,
+// section 7.2).
+type directTCPIPData struct {
+	Addr       string
+	Port       uint32
+	OriginAddr string
+	OriginPort uint32
+}
 
-	var once sync.Once
-	go func() {
-		io.Copy(connection, remoteConnection)
-		once.Do(close)
-	}()
-	go func() {
-		io.Copy(remoteConnection, connection)
-		once.Do(close)
-	}()
+// directSCIONQUICData is the payload of a "direct-scionquic" channel.
+type directSCIONQUICData struct {
+	Addr string
 }
 
 func handleTCPTunnel(perms *ssh.Permissions, newChannel ssh.NewChannel) {
-	extraData := newChannel.ExtraData()
-	addressLen := binary.BigEndian.Uint32(extraData[0:4])
-	address := string(extraData[4 : addressLen+4])
-	port := binary.BigEndian.Uint32(extraData[addressLen+4 : addressLen+8])
+	var data directTCPIPData
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &data); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "invalid direct-tcpip payload")
+		return
+	}
 
 	connection, requests, err := newChannel.Accept()
 	if err != nil {
@@ -59,19 +54,22 @@ func handleTCPTunnel(perms *ssh.Permissions, newChannel ssh.NewChannel) {
 
 	go ssh.DiscardRequests(requests)
 
-	remoteConnection, err := net.Dial("tcp", fmt.Sprintf("%s:%v", address, port))
+	remoteConnection, err := net.Dial("tcp", net.JoinHostPort(data.Addr, strconv.Itoa(int(data.Port))))
 	if err != nil {
 		log.Debug("Could not open remote connection (%s)", err)
+		connection.Close()
 		return
 	}
 
-	handleTunnelForRemoteConnection(connection, remoteConnection)
+	appquic.Pipe(connection, remoteConnection)
 }
 
 func handleSCIONQUICTunnel(perms *ssh.Permissions, newChannel ssh.NewChannel) {
-	extraData := newChannel.ExtraData()
-	addressLen := binary.BigEndian.Uint32(extraData[0:4])
-	address := string(extraData[4 : addressLen+4])
+	var data directSCIONQUICData
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &data); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "invalid direct-scionquic payload")
+		return
+	}
 
 	connection, requests, err := newChannel.Accept()
 	if err != nil {
@@ -81,11 +79,12 @@ func handleSCIONQUICTunnel(perms *ssh.Permissions, newChannel ssh.NewChannel) {
 
 	go ssh.DiscardRequests(requests)
 
-	remoteConnection, err := quicconn.Dial(address)
+	remoteConnection, err := quicconn.Dial(data.Addr)
 	if err != nil {
 		log.Debug("Could not open remote connection (%s)", err)
+		connection.Close()
 		return
 	}
 
-	handleTunnelForRemoteConnection(connection, remoteConnection)
+	appquic.Pipe(connection, remoteConnection)
 }
@@ -27,10 +27,12 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/inconshreveable/log15"
 	. "github.com/netsec-ethz/scion-apps/webapp/util"
 	"github.com/pelletier/go-toml"
+	"github.com/scionproto/scion/go/lib/addr"
 	"github.com/scionproto/scion/go/lib/sciond"
 )
 
@@ -56,6 +58,9 @@ var CMD_WEB = "srvroot"
 var CMD_BRT = "r"
 var CMD_SCG = "sgen"
 var CMD_SCC = "sgenc"
+var CMD_PWL = "pathwatch"
+var CMD_PWI = "pathwatch-interval"
+var CMD_PWH = "pathwatch-webhook"
 
 // appsRoot is the root location of scionlab apps.
 var GOPATH = os.Getenv("GOPATH")
@@ -86,6 +91,11 @@ type CmdOptions struct {
 	BrowseRoot    string
 	ScionGen      string
 	ScionGenCache string
+	// PathWatchlist are the destinations to which paths are watched for
+	// changes, see PathWatcher.
+	PathWatchlist     []addr.IA
+	PathWatchInterval time.Duration
+	PathWatchWebhook  string
 }
 
 func (o *CmdOptions) AbsPathCmdOptions() {
@@ -133,6 +143,12 @@ func ParseFlags() CmdOptions {
 		"Path to read SCION gen directory of infrastructure config")
 	scionGenCache := flag.String(CMD_SCC, defaultScionGenCache(),
 		"Path to read SCION gen-cache directory of infrastructure run-time config")
+	pathWatchlist := flag.String(CMD_PWL, "",
+		"Comma-separated list of ISD-ASes to which paths are watched for changes")
+	pathWatchInterval := flag.Duration(CMD_PWI, defaultPathWatchInterval,
+		"Interval between path queries for the watched ISD-ASes")
+	pathWatchWebhook := flag.String(CMD_PWH, "",
+		"URL to which changes of the watched paths are POSTed as JSON")
 	flag.Parse()
 	// recompute root args to use the proper relative defaults if undefined
 	if !isFlagUsed(CMD_WEB) {
@@ -147,11 +163,34 @@ func ParseFlags() CmdOptions {
 	if !isFlagUsed(CMD_SCC) {
 		*scionGenCache = defaultScionGenCache()
 	}
-	options := CmdOptions{*addr, *port, *staticRoot, *browseRoot, *scionGen, *scionGenCache}
+	watchlist, err := parseIAList(*pathWatchlist)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -%s: %s\n", CMD_PWL, err)
+		os.Exit(2)
+	}
+	options := CmdOptions{*addr, *port, *staticRoot, *browseRoot, *scionGen, *scionGenCache,
+		watchlist, *pathWatchInterval, *pathWatchWebhook}
 	options.AbsPathCmdOptions()
 	return options
 }
 
+// parseIAList parses a comma-separated list of ISD-ASes.
+func parseIAList(list string) ([]addr.IA, error) {
+	var ias []addr.IA
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		ia, err := addr.IAFromString(s)
+		if err != nil {
+			return nil, err
+		}
+		ias = append(ias, ia)
+	}
+	return ias, nil
+}
+
 // ScanLocalSetting will load list of locally available IAs and their corresponding Scionds
 func ScanLocalSetting(options *CmdOptions) ASConfigs {
 	cfg := make(ASConfigs)
@@ -0,0 +1,357 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/inconshreveable/log15"
+	. "github.com/netsec-ethz/scion-apps/webapp/util"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/common"
+	"github.com/scionproto/scion/go/lib/sciond"
+	"github.com/scionproto/scion/go/lib/snet"
+	snetpath "github.com/scionproto/scion/go/lib/snet/path"
+
+	"github.com/netsec-ethz/scion-apps/pkg/appnet"
+)
+
+const (
+	defaultPathWatchInterval = 5 * time.Minute
+	pathWatchQueryTimeout    = 10 * time.Second
+	pathWatchWebhookTimeout  = 10 * time.Second
+)
+
+// WatchedPath is a path to a watched destination, as stored and reported by
+// the PathWatcher.
+type WatchedPath struct {
+	Fingerprint string   `json:"fingerprint"`
+	Hops        []string `json:"hops"` // interfaces, as ISD-AS#IFID
+}
+
+// PathChange is the difference between two successive sets of paths to a
+// watched destination. It is returned by PathWatchHandler and POSTed to the
+// webhook.
+type PathChange struct {
+	Destination string        `json:"destination"`
+	Time        time.Time     `json:"time"`
+	Added       []WatchedPath `json:"added"`
+	Removed     []WatchedPath `json:"removed"`
+	// NewASes are the ASes on the added paths that were not on any of the
+	// previous paths, e.g. a new transit AS.
+	NewASes []string `json:"new_ases"`
+}
+
+// pathWatchEntry is the state of a watched destination, stored in the state
+// file so that changes are detected across restarts.
+type pathWatchEntry struct {
+	Paths      []WatchedPath `json:"paths"`
+	Checked    time.Time     `json:"checked"`
+	Err        string        `json:"err,omitempty"`
+	LastChange *PathChange   `json:"last_change"`
+}
+
+// pathQuerier returns the paths to dst.
+type pathQuerier func(ctx context.Context, dst addr.IA) ([]snet.Path, error)
+
+// PathWatcher periodically queries the paths to the destinations on the
+// watchlist and reports changes to the previously seen set of paths.
+type PathWatcher struct {
+	Watchlist []addr.IA
+	Interval  time.Duration
+	// WebhookURL, if set, receives a POST request with a PathChange as JSON
+	// body for every change.
+	WebhookURL string
+	// StateFile stores the last seen paths.
+	StateFile string
+
+	query  pathQuerier
+	client *http.Client
+
+	mutex sync.Mutex
+	state map[string]*pathWatchEntry
+}
+
+// NewPathWatcher creates a PathWatcher querying paths from the scion daemon at
+// sciondAddress, and loads the previously seen paths from stateFile, if it
+// exists.
+func NewPathWatcher(watchlist []addr.IA, interval time.Duration,
+	webhookURL, stateFile, sciondAddress string) (*PathWatcher, error) {
+
+	sciondConn, err := connect(sciondAddress)
+	if err != nil {
+		return nil, err
+	}
+	query := func(ctx context.Context, dst addr.IA) ([]snet.Path, error) {
+		return sciondConn.Paths(ctx, dst, addr.IA{}, sciond.PathReqFlags{Refresh: true})
+	}
+	return newPathWatcher(watchlist, interval, webhookURL, stateFile, query)
+}
+
+func newPathWatcher(watchlist []addr.IA, interval time.Duration,
+	webhookURL, stateFile string, query pathQuerier) (*PathWatcher, error) {
+
+	if interval <= 0 {
+		interval = defaultPathWatchInterval
+	}
+	pw := &PathWatcher{
+		Watchlist:  watchlist,
+		Interval:   interval,
+		WebhookURL: webhookURL,
+		StateFile:  stateFile,
+		query:      query,
+		client:     &http.Client{Timeout: pathWatchWebhookTimeout},
+		state:      make(map[string]*pathWatchEntry),
+	}
+	if err := pw.load(); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// Run checks the paths every Interval, until the context is cancelled.
+func (pw *PathWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pw.Interval)
+	defer ticker.Stop()
+	for {
+		pw.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check queries the paths to all destinations on the watchlist once, records
+// and reports the changes and saves the state.
+// The first set of paths seen for a destination is not reported as a change.
+func (pw *PathWatcher) Check(ctx context.Context) {
+	for _, dst := range pw.Watchlist {
+		qctx, cancel := context.WithTimeout(ctx, pathWatchQueryTimeout)
+		paths, err := pw.query(qctx, dst)
+		cancel()
+		change := pw.update(dst, paths, err, time.Now())
+		if change != nil {
+			log.Info("Paths changed", "dst", dst,
+				"added", len(change.Added), "removed", len(change.Removed), "new_ases", change.NewASes)
+			pw.notify(change)
+		}
+	}
+	if err := pw.save(); err != nil {
+		log.Error("Saving path watch state failed", "file", pw.StateFile, "err", err)
+	}
+}
+
+// update records the result of a path query and returns the change, if any.
+func (pw *PathWatcher) update(dst addr.IA, paths []snet.Path, queryErr error, now time.Time) *PathChange {
+	pw.mutex.Lock()
+	defer pw.mutex.Unlock()
+
+	entry, seen := pw.state[dst.String()]
+	if !seen {
+		entry = &pathWatchEntry{}
+		pw.state[dst.String()] = entry
+	}
+	entry.Checked = now
+	if queryErr != nil {
+		// Keep the last known paths; a failed query is not a change of paths.
+		log.Warn("Querying watched paths failed", "dst", dst, "err", queryErr)
+		entry.Err = queryErr.Error()
+		return nil
+	}
+	entry.Err = ""
+	previous := make([]snet.Path, len(entry.Paths))
+	for i, p := range entry.Paths {
+		previous[i] = p.snetPath()
+	}
+	entry.Paths = make([]WatchedPath, len(paths))
+	for i, p := range paths {
+		entry.Paths[i] = newWatchedPath(p)
+	}
+	if !seen {
+		return nil
+	}
+
+	added, removed := appnet.DiffPaths(previous, paths)
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+	change := &PathChange{
+		Destination: dst.String(),
+		Time:        now,
+		Added:       make([]WatchedPath, len(added)),
+		Removed:     make([]WatchedPath, len(removed)),
+		NewASes:     newASes(previous, added),
+	}
+	for i, p := range added {
+		change.Added[i] = newWatchedPath(p)
+	}
+	for i, p := range removed {
+		change.Removed[i] = newWatchedPath(p)
+	}
+	entry.LastChange = change
+	return change
+}
+
+// notify POSTs the change to the webhook.
+func (pw *PathWatcher) notify(change *PathChange) {
+	if pw.WebhookURL == "" {
+		return
+	}
+	body, err := json.Marshal(change)
+	if err != nil {
+		log.Error("Encoding path change failed", "err", err)
+		return
+	}
+	resp, err := pw.client.Post(pw.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Error("Path change webhook failed", "url", pw.WebhookURL, "err", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Error("Path change webhook failed", "url", pw.WebhookURL, "status", resp.Status)
+	}
+}
+
+// PathWatchHandler returns the state of the watched destinations as JSON: the
+// current paths, the time of the last check and the last change. The form
+// value ia_ser restricts the result to a single destination.
+func (pw *PathWatcher) PathWatchHandler(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	dst := r.FormValue("ia_ser")
+
+	pw.mutex.Lock()
+	var result interface{} = pw.state
+	if dst != "" {
+		entry, ok := pw.state[dst]
+		if !ok {
+			pw.mutex.Unlock()
+			returnError(w, fmt.Errorf("%s is not watched", dst))
+			return
+		}
+		result = entry
+	}
+	body, err := json.Marshal(result)
+	pw.mutex.Unlock()
+	if CheckError(err) {
+		returnError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func (pw *PathWatcher) load() error {
+	if pw.StateFile == "" {
+		return nil
+	}
+	raw, err := ioutil.ReadFile(pw.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	pw.mutex.Lock()
+	defer pw.mutex.Unlock()
+	if err := json.Unmarshal(raw, &pw.state); err != nil {
+		return fmt.Errorf("error parsing %s: %w", pw.StateFile, err)
+	}
+	return nil
+}
+
+func (pw *PathWatcher) save() error {
+	if pw.StateFile == "" {
+		return nil
+	}
+	pw.mutex.Lock()
+	raw, err := json.MarshalIndent(pw.state, "", "  ")
+	pw.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(pw.StateFile, raw, 0644)
+}
+
+func newWatchedPath(p snet.Path) WatchedPath {
+	wp := WatchedPath{
+		Fingerprint: hex.EncodeToString([]byte(snet.Fingerprint(p))),
+		Hops:        []string{},
+	}
+	if p.Metadata() != nil {
+		for _, intf := range p.Metadata().Interfaces {
+			wp.Hops = append(wp.Hops, intf.String())
+		}
+	}
+	return wp
+}
+
+// snetPath returns a path with the interfaces of the stored path, which is
+// sufficient to compare it to other paths with appnet.DiffPaths.
+func (wp WatchedPath) snetPath() snet.Path {
+	var intfs []snet.PathInterface
+	for _, hop := range wp.Hops {
+		sep := strings.LastIndexByte(hop, '#')
+		if sep < 0 {
+			continue
+		}
+		ia, err := addr.IAFromString(hop[:sep])
+		if err != nil {
+			continue
+		}
+		id, err := strconv.ParseUint(hop[sep+1:], 10, 64)
+		if err != nil {
+			continue
+		}
+		intfs = append(intfs, snet.PathInterface{IA: ia, ID: common.IFIDType(id)})
+	}
+	return snetpath.Path{Meta: snet.PathMetadata{Interfaces: intfs}}
+}
+
+// newASes returns the ASes on the added paths that are not on any of the
+// previous paths, in the order of their first occurrence.
+func newASes(previous, added []snet.Path) []string {
+	known := make(map[addr.IA]bool)
+	for _, p := range previous {
+		for _, intf := range p.Metadata().Interfaces {
+			known[intf.IA] = true
+		}
+	}
+	ases := []string{}
+	for _, p := range added {
+		if p.Metadata() == nil {
+			continue
+		}
+		for _, intf := range p.Metadata().Interfaces {
+			if !known[intf.IA] {
+				known[intf.IA] = true
+				ases = append(ases, intf.IA.String())
+			}
+		}
+	}
+	return ases
+}
@@ -0,0 +1,187 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/common"
+	"github.com/scionproto/scion/go/lib/snet"
+	snetpath "github.com/scionproto/scion/go/lib/snet/path"
+)
+
+// watchTestPath returns a path with the given interfaces, given as pairs of
+// ISD-AS and interface ID.
+func watchTestPath(ifaces ...interface{}) snet.Path {
+	var intfs []snet.PathInterface
+	for i := 0; i < len(ifaces); i += 2 {
+		ia, err := addr.IAFromString(ifaces[i].(string))
+		if err != nil {
+			panic(err)
+		}
+		intfs = append(intfs, snet.PathInterface{IA: ia, ID: common.IFIDType(ifaces[i+1].(int))})
+	}
+	return snetpath.Path{Meta: snet.PathMetadata{Interfaces: intfs}}
+}
+
+func TestPathWatcher(t *testing.T) {
+	dst, _ := addr.IAFromString("1-ff00:0:112")
+	direct := watchTestPath("1-ff00:0:111", 1, "1-ff00:0:112", 1)
+	viaA := watchTestPath("1-ff00:0:111", 2, "1-ff00:0:110", 1, "1-ff00:0:110", 2, "1-ff00:0:112", 2)
+	viaB := watchTestPath("1-ff00:0:111", 3, "1-ff00:0:113", 1, "1-ff00:0:113", 2, "1-ff00:0:112", 3)
+
+	var current []snet.Path
+	query := func(ctx context.Context, ia addr.IA) ([]snet.Path, error) {
+		if ia != dst {
+			t.Errorf("unexpected query for %s", ia)
+		}
+		return current, nil
+	}
+	webhook := make(chan PathChange, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var change PathChange
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			t.Errorf("invalid webhook payload: %s", err)
+		}
+		webhook <- change
+	}))
+	defer server.Close()
+	stateFile := filepath.Join(t.TempDir(), "pathwatch.json")
+
+	pw, err := newPathWatcher([]addr.IA{dst}, time.Minute, server.URL, stateFile, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNoChange := func() {
+		t.Helper()
+		select {
+		case change := <-webhook:
+			t.Errorf("unexpected change %v", change)
+		default:
+		}
+	}
+	expectChange := func(added, removed []snet.Path, newASes []string) PathChange {
+		t.Helper()
+		select {
+		case change := <-webhook:
+			if change.Destination != dst.String() {
+				t.Errorf("unexpected destination %s", change.Destination)
+			}
+			if !reflect.DeepEqual(change.Added, watchedPaths(added)) {
+				t.Errorf("expected added %v, got %v", watchedPaths(added), change.Added)
+			}
+			if !reflect.DeepEqual(change.Removed, watchedPaths(removed)) {
+				t.Errorf("expected removed %v, got %v", watchedPaths(removed), change.Removed)
+			}
+			if !reflect.DeepEqual(change.NewASes, newASes) {
+				t.Errorf("expected new ASes %v, got %v", newASes, change.NewASes)
+			}
+			return change
+		default:
+			t.Fatalf("expected a change")
+			return PathChange{}
+		}
+	}
+
+	// first set of paths is not a change
+	current = []snet.Path{direct, viaA}
+	pw.Check(context.Background())
+	expectNoChange()
+
+	// same paths, in different order
+	current = []snet.Path{viaA, direct}
+	pw.Check(context.Background())
+	expectNoChange()
+
+	// new transit AS appears
+	current = []snet.Path{direct, viaA, viaB}
+	pw.Check(context.Background())
+	expectChange([]snet.Path{viaB}, []snet.Path{}, []string{"1-ff00:0:113"})
+
+	// path disappears
+	current = []snet.Path{direct, viaB}
+	pw.Check(context.Background())
+	last := expectChange([]snet.Path{}, []snet.Path{viaA}, []string{})
+
+	// the endpoint returns the last change
+	req := httptest.NewRequest("GET", "/pathwatch?ia_ser="+dst.String(), nil)
+	rec := httptest.NewRecorder()
+	pw.PathWatchHandler(rec, req)
+	var entry pathWatchEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid response %q: %s", rec.Body.String(), err)
+	}
+	if entry.LastChange == nil || !reflect.DeepEqual(entry.LastChange.Removed, last.Removed) {
+		t.Errorf("expected last change %v, got %v", last, entry.LastChange)
+	}
+	if !reflect.DeepEqual(entry.Paths, watchedPaths([]snet.Path{direct, viaB})) {
+		t.Errorf("unexpected current paths %v", entry.Paths)
+	}
+
+	// the last seen paths persist across restarts of the watcher
+	pw, err = newPathWatcher([]addr.IA{dst}, time.Minute, server.URL, stateFile, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current = []snet.Path{direct}
+	pw.Check(context.Background())
+	expectChange([]snet.Path{}, []snet.Path{viaB}, []string{})
+}
+
+func TestPathWatcherQueryError(t *testing.T) {
+	dst, _ := addr.IAFromString("1-ff00:0:112")
+	direct := watchTestPath("1-ff00:0:111", 1, "1-ff00:0:112", 1)
+	var queryErr error
+	query := func(ctx context.Context, ia addr.IA) ([]snet.Path, error) {
+		if queryErr != nil {
+			return nil, queryErr
+		}
+		return []snet.Path{direct}, nil
+	}
+	pw, err := newPathWatcher([]addr.IA{dst}, time.Minute, "", "", query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw.Check(context.Background())
+
+	// a failed query keeps the last known paths and is not a change
+	queryErr = context.DeadlineExceeded
+	pw.Check(context.Background())
+	entry := pw.state[dst.String()]
+	if entry.Err == "" || len(entry.Paths) != 1 || entry.LastChange != nil {
+		t.Errorf("unexpected state after failed query: %+v", entry)
+	}
+	queryErr = nil
+	pw.Check(context.Background())
+	if entry.Err != "" || entry.LastChange != nil {
+		t.Errorf("unexpected state after recovery: %+v", entry)
+	}
+}
+
+func watchedPaths(paths []snet.Path) []WatchedPath {
+	wps := make([]WatchedPath, len(paths))
+	for i, p := range paths {
+		wps[i] = newWatchedPath(p)
+	}
+	return wps
+}
@@ -18,7 +18,6 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
@@ -91,36 +90,15 @@ func handleHopData(line string, runTimeKey int64) {
 	// store current epoch in ms
 	d.Inserted = time.Now().UnixNano() / 1e6
 
-	match, _ := regexp.MatchString(reHop, line)
-	if match {
-		re := regexp.MustCompile(reHop)
-		order, _ := strconv.Atoi(re.FindStringSubmatch(line)[1])
-		d.Ord = int(order)
-		d.HopIa = re.FindStringSubmatch(line)[2]
-		d.HopAddr = re.FindStringSubmatch(line)[3]
-
-		matchIntf, _ := regexp.MatchString(reINTF, line)
-		if matchIntf {
-			reIF := regexp.MustCompile(reINTF)
-			intfID, _ := strconv.Atoi(reIF.FindStringSubmatch(line)[1])
-			d.IntfID = int(intfID)
-		} else {
-			d.IntfID = -1
-		}
-
-		var RespTime [3]float32
-		for i := 0; i < 3; i++ {
-			resp := re.FindStringSubmatch(line)[4+i]
-			t, err := time.ParseDuration(resp)
-			if err == nil {
-				RespTime[i] = float32(t.Nanoseconds()) / 1e6
-			} else {
-				RespTime[i] = -1
-			}
-		}
-		d.RespTime1 = RespTime[0]
-		d.RespTime2 = RespTime[1]
-		d.RespTime3 = RespTime[2]
+	hop, ok := parseTracerouteHop(line)
+	if ok && hop.IA != "" {
+		d.Ord = hop.Ord
+		d.HopIa = hop.IA
+		d.HopAddr = hop.Addr
+		d.IntfID = hop.IntfID
+		d.RespTime1 = hop.RTTs[0]
+		d.RespTime2 = hop.RTTs[1]
+		d.RespTime3 = hop.RTTs[2]
 
 		//store hop information in db
 		err := model.StoreTrHopItem(&d)
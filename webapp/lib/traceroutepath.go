@@ -0,0 +1,280 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/inconshreveable/log15"
+)
+
+// hop line without any response, e.g. when the address of the hop is unknown
+var reHopTimeout = `^\s*(\d+)\s.*\*\s+\*\s+\*\s*$`
+var reAvailPaths = `(?i:available paths to)`
+var reAnsiEscape = "[\u001B\u009B][[\\]()#;?]*(?:(?:(?:[a-zA-Z\\d]*(?:;[a-zA-Z\\d]*)*)?\u0007)|(?:(?:\\d{1,4}(?:;\\d{0,4})*)?[\\dA-PRZcf-ntqry=><~]))"
+
+const defaultTracerouteTimeout = 2 * time.Second
+
+// time to wait for the path list before giving up on finding the selected path
+var tracerouteChoiceTimeout = 1000 * time.Millisecond
+
+// upper bound for a traceroute run, after which scion traceroute is killed
+var tracerouteRunTimeout = 1 * time.Minute
+
+// TracerouteHop is the result of a traceroute for a single hop on the path.
+type TracerouteHop struct {
+	Ord     int       `json:"ord"`
+	IA      string    `json:"ia"`
+	Addr    string    `json:"addr"`
+	IntfID  int       `json:"intf_id"`   // -1 if unknown
+	RTTs    []float32 `json:"rtts_ms"`   // -1 for probes that timed out
+	Timeout bool      `json:"timed_out"` // true if no probe was answered
+}
+
+// TracerouteResult is the JSON response of TraceroutePathHandler.
+type TracerouteResult struct {
+	Path string          `json:"path"`
+	Hops []TracerouteHop `json:"hops"`
+}
+
+// tracerouteRunner runs a traceroute to remote along the path matching
+// pathStr and returns the output. The traceroute is aborted when ctx is done.
+type tracerouteRunner func(ctx context.Context, remote, pathStr, sciond string,
+	timeout time.Duration) (string, error)
+
+var runTraceroute tracerouteRunner = runScionTraceroute
+
+// TraceroutePathHandler runs a traceroute to the destination along the given
+// path and returns the RTT and interface ID of each hop as JSON.
+// The destination is given by the form values ia_ser and addr_ser, the path by
+// pathStr, as displayed in the path list.
+func TraceroutePathHandler(w http.ResponseWriter, r *http.Request, sciond string) {
+	r.ParseForm()
+	ia := r.FormValue("ia_ser")
+	address := r.FormValue("addr_ser")
+	pathStr := r.FormValue("pathStr")
+	if ia == "" || address == "" || pathStr == "" {
+		returnError(w, errors.New("ia_ser, addr_ser and pathStr are required"))
+		return
+	}
+	timeout := defaultTracerouteTimeout
+	if t := r.FormValue("timeout"); t != "" {
+		secs, err := strconv.ParseFloat(t, 64)
+		if err != nil || secs <= 0 {
+			returnError(w, fmt.Errorf("invalid timeout: %s", t))
+			return
+		}
+		timeout = time.Duration(secs * float64(time.Second))
+	}
+
+	remote := fmt.Sprintf("%s,[%s]", ia, address)
+	log.Info("Running traceroute", "remote", remote, "path", pathStr)
+	ctx, cancel := context.WithTimeout(r.Context(), tracerouteRunTimeout)
+	defer cancel()
+	output, err := runTraceroute(ctx, remote, pathStr, sciond, timeout)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	res := TracerouteResult{
+		Path: pathStr,
+		Hops: parseTracerouteHops(output),
+	}
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	fmt.Fprint(w, string(jsonRes))
+}
+
+// parseTracerouteHops extracts the hops from the output of scion traceroute.
+func parseTracerouteHops(output string) []TracerouteHop {
+	hops := []TracerouteHop{}
+	for _, line := range strings.Split(output, "\n") {
+		if hop, ok := parseTracerouteHop(line); ok {
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+func parseTracerouteHop(line string) (TracerouteHop, bool) {
+	re := regexp.MustCompile(reHop)
+	match := re.FindStringSubmatch(line)
+	if match == nil {
+		reTimeout := regexp.MustCompile(reHopTimeout)
+		match = reTimeout.FindStringSubmatch(line)
+		if match == nil {
+			return TracerouteHop{}, false
+		}
+		ord, _ := strconv.Atoi(match[1])
+		return TracerouteHop{
+			Ord:     ord,
+			IntfID:  -1,
+			RTTs:    []float32{-1, -1, -1},
+			Timeout: true,
+		}, true
+	}
+
+	hop := TracerouteHop{IntfID: -1, Timeout: true}
+	hop.Ord, _ = strconv.Atoi(match[1])
+	hop.IA = match[2]
+	hop.Addr = match[3]
+	reIF := regexp.MustCompile(reINTF)
+	if matchIntf := reIF.FindStringSubmatch(line); matchIntf != nil {
+		hop.IntfID, _ = strconv.Atoi(matchIntf[1])
+	}
+	for i := 0; i < 3; i++ {
+		t, err := time.ParseDuration(match[4+i])
+		if err == nil {
+			hop.RTTs = append(hop.RTTs, float32(t.Nanoseconds())/1e6)
+			hop.Timeout = false
+		} else {
+			hop.RTTs = append(hop.RTTs, -1)
+		}
+	}
+	return hop, true
+}
+
+// runScionTraceroute runs scion traceroute in interactive mode and selects the
+// path matching pathStr when prompted. The process is killed when ctx is done.
+func runScionTraceroute(ctx context.Context, remote, pathStr, sciond string,
+	timeout time.Duration) (string, error) {
+
+	cmd := exec.CommandContext(ctx, "scion", "traceroute", remote,
+		fmt.Sprintf("--timeout=%fs", timeout.Seconds()),
+		fmt.Sprintf("--sciond=%s", sciond), "-i")
+	cmd.Env = append(os.Environ(), "SCION_DAEMON_ADDRESS="+sciond)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	var output strings.Builder
+	pathFound, killed := selectPath(mergeLines(stdout, stderr), stdin, pathStr, &output,
+		func() { _ = cmd.Process.Kill() })
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("traceroute aborted: %v: %s", ctx.Err(), strings.TrimSpace(output.String()))
+	}
+	if killed {
+		return "", fmt.Errorf("path no longer available: %s", pathStr)
+	}
+	if !pathFound {
+		return "", fmt.Errorf("path selection failed: %s", strings.TrimSpace(output.String()))
+	}
+	if err != nil {
+		return "", fmt.Errorf("traceroute failed: %v: %s", err, strings.TrimSpace(output.String()))
+	}
+	return output.String(), nil
+}
+
+// mergeLines returns a reader returning the lines of all readers, which are
+// read concurrently, so that the process writing to one of them is not blocked
+// while another one is not closed yet. The lines of the different readers are
+// interleaved in the order in which they are read.
+func mergeLines(readers ...io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	var wg sync.WaitGroup
+	wg.Add(len(readers))
+	for _, r := range readers {
+		go func(r io.Reader) {
+			defer wg.Done()
+			scanner := bufio.NewScanner(r)
+			for scanner.Scan() {
+				if _, err := pw.Write([]byte(scanner.Text() + "\n")); err != nil {
+					break
+				}
+			}
+			// drain the reader, e.g. after a line that is too long
+			_, _ = io.Copy(ioutil.Discard, r)
+		}(r)
+	}
+	go func() {
+		wg.Wait()
+		pw.Close()
+	}()
+	return pr
+}
+
+// selectPath copies the lines from reader to output and answers the path
+// prompt with the index of the path matching pathStr. If the path is not
+// found in time after the path list is printed, kill is called.
+func selectPath(reader io.Reader, stdin io.Writer, pathStr string, output *strings.Builder,
+	kill func()) (pathFound, killed bool) {
+
+	reAvail := regexp.MustCompile(reAvailPaths)
+	rePath := regexp.MustCompile(`\[\s*(\d+)\].*` + regexp.QuoteMeta(pathStr))
+	reAnsi := regexp.MustCompile(reAnsiEscape)
+
+	var mutex sync.Mutex
+	var timer *time.Timer
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := reAnsi.ReplaceAllString(scanner.Text(), "")
+		output.WriteString(line + "\n")
+		if pathFound {
+			continue
+		}
+		if timer == nil && reAvail.MatchString(line) {
+			timer = time.AfterFunc(tracerouteChoiceTimeout, func() {
+				mutex.Lock()
+				defer mutex.Unlock()
+				if !pathFound {
+					killed = true
+					kill()
+				}
+			})
+		}
+		if match := rePath.FindStringSubmatch(line); match != nil {
+			mutex.Lock()
+			pathFound = true
+			mutex.Unlock()
+			if timer != nil {
+				timer.Stop()
+			}
+			fmt.Fprintf(stdin, "%s\n", match[1])
+		}
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	return pathFound, killed
+}
@@ -0,0 +1,169 @@
+// Copyright 2021 ETH Zurich
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPathStr = "Hops: [1-ff00:0:111 41>1 1-ff00:0:110 2>1 1-ff00:0:112] MTU: 1472 NextHop: 127.0.0.17:31024"
+
+// testTracerouteOutput is the output of scion traceroute, as it would be
+// produced with a responder on the path that answers all probes except for
+// those to the third hop, and some of those to the last hop.
+const testTracerouteOutput = `Available paths to 1-ff00:0:112
+[ 0] Hops: [1-ff00:0:111 42>1 1-ff00:0:113 2>3 1-ff00:0:112] MTU: 1472 NextHop: 127.0.0.17:31024
+[ 1] ` + testPathStr + `
+Choose path: Using path:
+  ` + testPathStr + `
+
+0 1-ff00:0:111,[127.0.0.17] IfID=41 1.123ms 0.987ms 1.01ms
+1 1-ff00:0:110,[127.0.0.25] IfID=1 2.5ms 2.25ms 2.75ms
+2 1-ff00:0:110,[127.0.0.25] IfID=2 * * *
+3 1-ff00:0:112,[127.0.0.33] IfID=1 4ms * 4.5ms
+`
+
+func TestTraceroutePathHandler(t *testing.T) {
+	var ranRemote, ranPath string
+	runTraceroute = func(ctx context.Context, remote, pathStr, sciond string,
+		timeout time.Duration) (string, error) {
+		ranRemote, ranPath = remote, pathStr
+		return testTracerouteOutput, nil
+	}
+	defer func() { runTraceroute = runScionTraceroute }()
+
+	form := url.Values{
+		"ia_ser":   {"1-ff00:0:112"},
+		"addr_ser": {"127.0.0.33"},
+		"pathStr":  {testPathStr},
+	}
+	req := httptest.NewRequest("POST", "/traceroutepath", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	TraceroutePathHandler(rec, req, "127.0.0.1:30255")
+
+	if ranRemote != "1-ff00:0:112,[127.0.0.33]" || ranPath != testPathStr {
+		t.Errorf("traceroute run with unexpected arguments: %s, %s", ranRemote, ranPath)
+	}
+
+	body, _ := ioutil.ReadAll(rec.Result().Body)
+	var res TracerouteResult
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatalf("invalid JSON response: %v, %s", err, body)
+	}
+	expected := TracerouteResult{
+		Path: testPathStr,
+		Hops: []TracerouteHop{
+			{Ord: 0, IA: "1-ff00:0:111", Addr: "127.0.0.17", IntfID: 41, RTTs: []float32{1.123, 0.987, 1.01}},
+			{Ord: 1, IA: "1-ff00:0:110", Addr: "127.0.0.25", IntfID: 1, RTTs: []float32{2.5, 2.25, 2.75}},
+			{Ord: 2, IA: "1-ff00:0:110", Addr: "127.0.0.25", IntfID: 2, RTTs: []float32{-1, -1, -1}, Timeout: true},
+			{Ord: 3, IA: "1-ff00:0:112", Addr: "127.0.0.33", IntfID: 1, RTTs: []float32{4, -1, 4.5}},
+		},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("unexpected result\nexpected: %+v\nactual:   %+v", expected, res)
+	}
+
+	// the hops must be a list, even if empty
+	var raw map[string]interface{}
+	_ = json.Unmarshal(body, &raw)
+	if _, ok := raw["hops"].([]interface{}); !ok {
+		t.Errorf("hops is not a JSON list: %s", body)
+	}
+}
+
+func TestTraceroutePathHandlerMissingPath(t *testing.T) {
+	req := httptest.NewRequest("GET", "/traceroutepath?ia_ser=1-ff00:0:112&addr_ser=127.0.0.33", nil)
+	rec := httptest.NewRecorder()
+	TraceroutePathHandler(rec, req, "127.0.0.1:30255")
+	body, _ := ioutil.ReadAll(rec.Result().Body)
+	if !strings.HasPrefix(string(body), `{"err":`) {
+		t.Errorf("expected error response, got %s", body)
+	}
+}
+
+func TestParseTracerouteHopUnknownAddress(t *testing.T) {
+	hop, ok := parseTracerouteHop("4 * * *")
+	if !ok || hop.Ord != 4 || !hop.Timeout || hop.IntfID != -1 {
+		t.Errorf("unexpected hop for unanswered probes: %+v, %v", hop, ok)
+	}
+	if _, ok := parseTracerouteHop("Using path:"); ok {
+		t.Error("non-hop line parsed as hop")
+	}
+}
+
+func TestSelectPath(t *testing.T) {
+	var stdin strings.Builder
+	var output strings.Builder
+	found, killed := selectPath(strings.NewReader(testTracerouteOutput), &stdin, testPathStr, &output,
+		func() { t.Error("unexpected kill") })
+	if !found || killed {
+		t.Errorf("path not selected: found=%v, killed=%v", found, killed)
+	}
+	if stdin.String() != "1\n" {
+		t.Errorf("wrong path index written: %q", stdin.String())
+	}
+	if output.String() != testTracerouteOutput {
+		t.Errorf("output not copied")
+	}
+}
+
+// TestMergeLines checks that the lines written to stderr are read while
+// stdout is still open, as the process blocks on writing to stderr otherwise.
+func TestMergeLines(t *testing.T) {
+	stdoutR, stdoutW := io.Pipe()
+	stderrR, stderrW := io.Pipe()
+	go func() {
+		_, _ = io.WriteString(stdoutW, "out 1\n")
+		_, _ = io.WriteString(stderrW, "err 1\nerr 2\n")
+		stderrW.Close()
+		_, _ = io.WriteString(stdoutW, "out 2\n")
+		stdoutW.Close()
+	}()
+
+	done := make(chan string)
+	go func() {
+		merged, _ := ioutil.ReadAll(mergeLines(stdoutR, stderrR))
+		done <- string(merged)
+	}()
+	select {
+	case merged := <-done:
+		// the lines of each reader are in order, but may be interleaved
+		// arbitrarily
+		var out, errs []string
+		for _, line := range strings.Split(strings.TrimSuffix(merged, "\n"), "\n") {
+			if strings.HasPrefix(line, "out") {
+				out = append(out, line)
+			} else {
+				errs = append(errs, line)
+			}
+		}
+		if !reflect.DeepEqual(out, []string{"out 1", "out 2"}) ||
+			!reflect.DeepEqual(errs, []string{"err 1", "err 2"}) {
+			t.Errorf("unexpected merged lines %q", merged)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("reading blocked")
+	}
+}
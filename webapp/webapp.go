@@ -18,6 +18,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -158,6 +159,7 @@ func main() {
 	appsBuildCheck("traceroute")
 
 	initServeHandlers()
+	initPathWatcher()
 	log.Info(fmt.Sprintf("Browser access: at http://%s:%d.", options.Addr, options.Port))
 	checkPath(options.BrowseRoot)
 	log.Info("File browser root:", "root", options.BrowseRoot)
@@ -208,6 +210,7 @@ func initServeHandlers() {
 	http.HandleFunc("/dirview", dirViewHandler)
 	http.HandleFunc("/getechobytime", getEchoByTimeHandler)
 	http.HandleFunc("/gettraceroutebytime", getTracerouteByTimeHandler)
+	http.HandleFunc("/traceroutepath", traceroutePathHandler)
 	http.HandleFunc("/getias", getIAsHandler)
 	http.HandleFunc("/setuseropt", setUserOptionsHandler)
 
@@ -221,6 +224,22 @@ func initServeHandlers() {
 	http.HandleFunc("/gettrc", getTrcInfoHandler)
 }
 
+// start watching the paths to the destinations given on the command line, if any
+func initPathWatcher() {
+	if len(options.PathWatchlist) == 0 {
+		return
+	}
+	stateFile := path.Join(options.StaticRoot, "pathwatch.json")
+	watcher, err := lib.NewPathWatcher(options.PathWatchlist, options.PathWatchInterval,
+		options.PathWatchWebhook, stateFile, asCfg[myIA].Sciond)
+	if CheckError(err) {
+		return
+	}
+	go watcher.Run(context.Background())
+	http.HandleFunc("/pathwatch", watcher.PathWatchHandler)
+	log.Info("Watching paths:", "dsts", options.PathWatchlist, "interval", options.PathWatchInterval)
+}
+
 func logRequestHandler(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Info(fmt.Sprintf("%s %s %s", r.RemoteAddr, r.Method, r.URL))
@@ -693,6 +712,10 @@ func getTracerouteByTimeHandler(w http.ResponseWriter, r *http.Request) {
 	lib.GetTracerouteByTimeHandler(w, r, contCmdActive)
 }
 
+func traceroutePathHandler(w http.ResponseWriter, r *http.Request) {
+	lib.TraceroutePathHandler(w, r, asCfg[myIA].Sciond)
+}
+
 // Handles locating most recent image formatting it for graphic display in response.
 func findImageHandler(w http.ResponseWriter, r *http.Request) {
 	lib.FindImageHandler(w, r, &options)